@@ -0,0 +1,4 @@
+// Package pb 用户服务 gRPC 接口的 protobuf 定义与生成代码
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
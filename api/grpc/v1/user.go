@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"my_user_system/api/grpc/pb"
+	"my_user_system/model"
+	"my_user_system/service"
+	"my_user_system/static"
+)
+
+// UserServer 用户服务的 gRPC 实现。会话、租户、来源IP等请求上下文以及登录态由 router 中的拦截器处理，
+// 需要登录态的接口从上下文中取当前用户
+type UserServer struct {
+	pb.UnimplementedUserServiceServer
+}
+
+// NewUserServer 创建用户服务
+func NewUserServer() *UserServer {
+	return &UserServer{}
+}
+
+// principalFromCtx 鉴权拦截器放入上下文的当前用户
+func principalFromCtx(ctx context.Context) (*model.User, error) {
+	user, ok := ctx.Value(static.PrincipalKey).(*model.User)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, service.ErrSessionInvalid.Error())
+	}
+	return user, nil
+}
+
+// toStatus 把 service 层的错误转换为 gRPC 状态，无法识别的错误使用接口默认的状态码，
+// 与 HTTP 接口按接口区分错误码的方式一致
+func toStatus(err error, fallback codes.Code) error {
+	var policyErr *service.PasswordPolicyError
+	code := fallback
+	switch {
+	case errors.Is(err, service.ErrSessionInvalid):
+		code = codes.Unauthenticated
+	case errors.Is(err, service.ErrChallengeRequired):
+		code = codes.FailedPrecondition
+	case errors.Is(err, service.ErrChallengeFailed), errors.As(err, &policyErr):
+		code = codes.InvalidArgument
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
+
+// Register 注册
+func (s *UserServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	err := service.Register(ctx, &service.RegisterRequest{
+		UserName:        req.GetUserName(),
+		Password:        req.GetPassword(),
+		Age:             int(req.GetAge()),
+		Gender:          req.GetGender(),
+		NickName:        req.GetNickName(),
+		InviteCode:      req.GetInviteCode(),
+		ChallengeID:     req.GetChallengeId(),
+		ChallengeAnswer: req.GetChallengeAnswer(),
+	})
+	if err != nil {
+		return nil, toStatus(err, codes.InvalidArgument)
+	}
+	return &pb.RegisterResponse{}, nil
+}
+
+// Login 登录，会话标识通过响应返回，之后的请求在元数据 authorization 中携带
+func (s *UserServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	session, ttl, err := service.Login(ctx, &service.LoginRequest{
+		UserName:        req.GetUserName(),
+		PassWord:        req.GetPassword(),
+		Remember:        req.GetRemember(),
+		ChallengeID:     req.GetChallengeId(),
+		ChallengeAnswer: req.GetChallengeAnswer(),
+	})
+	if err != nil {
+		return nil, toStatus(err, codes.Unauthenticated)
+	}
+	return &pb.LoginResponse{Session: session, ExpiresIn: int64(ttl.Seconds())}, nil
+}
+
+// Logout 退出登录
+func (s *UserServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	user, err := principalFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.Logout(ctx, &service.LogoutRequest{UserName: user.Name}); err != nil {
+		return nil, toStatus(err, codes.Internal)
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+// GetUserInfo 获取当前会话的用户信息
+func (s *UserServer) GetUserInfo(ctx context.Context, req *pb.GetUserInfoRequest) (*pb.GetUserInfoResponse, error) {
+	user, err := principalFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := service.GetUserInfo(ctx, &service.GetUserInfoRequest{UserName: user.Name})
+	if err != nil {
+		return nil, toStatus(err, codes.Internal)
+	}
+	return &pb.GetUserInfoResponse{
+		UserName:     info.UserName,
+		Age:          int32(info.Age),
+		Gender:       info.Gender,
+		NickName:     info.NickName,
+		Impersonated: info.Impersonated,
+		Impersonator: info.Impersonator,
+	}, nil
+}
+
+// UpdateProfile 修改当前会话用户的资料，目前只支持昵称
+func (s *UserServer) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
+	user, err := principalFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetNickName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "nick_name is empty")
+	}
+	err = service.UpdateUserNickName(ctx, &service.UpdateNickNameRequest{UserName: user.Name, NewNickName: req.GetNickName()})
+	if err != nil {
+		return nil, toStatus(err, codes.Internal)
+	}
+	return &pb.UpdateProfileResponse{}, nil
+}
+
+// ValidateSession 校验请求中的会话，会话无效时返回 valid=false 而不是错误
+func (s *UserServer) ValidateSession(ctx context.Context, req *pb.ValidateSessionRequest) (*pb.ValidateSessionResponse, error) {
+	rsp, err := service.ValidateSession(ctx, req.GetSession())
+	if err != nil {
+		return nil, toStatus(err, codes.Unavailable)
+	}
+	return &pb.ValidateSessionResponse{
+		Valid:        rsp.Valid,
+		UserName:     rsp.UserName,
+		ExpiresIn:    rsp.ExpiresIn,
+		Impersonated: rsp.Impersonated,
+		Impersonator: rsp.Impersonator,
+	}, nil
+}
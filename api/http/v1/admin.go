@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/model"
+	"my_user_system/service"
+)
+
+// AdminListUsers 查询用户列表
+func AdminListUsers(c *gin.Context) {
+	req := &service.ListUsersRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind list users request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.AdminListUsers(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeAdminUserErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// AdminGetUser 查询单个用户
+func AdminGetUser(c *gin.Context) {
+	req := &service.AdminUserRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind get user request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.AdminGetUser(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeAdminUserErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// AdminDisableUser 禁用账号
+func AdminDisableUser(c *gin.Context) {
+	adminSetUserStatus(c, model.UserStatusDisabled)
+}
+
+// AdminEnableUser 启用账号
+func AdminEnableUser(c *gin.Context) {
+	adminSetUserStatus(c, model.UserStatusNormal)
+}
+
+func adminSetUserStatus(c *gin.Context, status int) {
+	req := &service.AdminUserRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind set user status request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.AdminSetUserStatus(newAdminContext(c), req, status); err != nil {
+		rsp.ResponseWithError(c, CodeAdminUserErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// AdminForceResetPassword 要求用户重置密码
+func AdminForceResetPassword(c *gin.Context) {
+	req := &service.AdminUserRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind force reset password request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.AdminForceResetPassword(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeAdminUserErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// AdminUpdateUser 修改用户资料
+func AdminUpdateUser(c *gin.Context) {
+	req := &service.AdminUpdateUserRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind admin update user request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.AdminUpdateUser(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeAdminUserErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
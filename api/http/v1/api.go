@@ -3,6 +3,7 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -14,6 +15,14 @@ import (
 	"time"
 )
 
+// newRequestContext 构造请求上下文，携带会话、租户、User-Agent 和来源IP
+func newRequestContext(c *gin.Context) context.Context {
+	ctx := context.WithValue(context.Background(), static.SessionKey, GetSessionCookie(c))
+	ctx = context.WithValue(ctx, static.TenantKey, c.GetInt(static.TenantKey))
+	ctx = context.WithValue(ctx, static.UserAgentKey, c.Request.UserAgent())
+	return context.WithValue(ctx, static.ClientIPKey, c.ClientIP())
+}
+
 // Ping 函数处理 "/ping" 路由，返回应用信息
 func Ping(c *gin.Context) {
 	// 获取全局配置中的应用配置信息
@@ -29,6 +38,16 @@ func Ping(c *gin.Context) {
 	c.String(http.StatusOK, appInfo)
 }
 
+// Health 健康检查，Redis 不可用时仍返回 200 并标记为降级，数据库不可用时返回 503
+func Health(c *gin.Context) {
+	rsp := service.Health()
+	code := http.StatusOK
+	if rsp.Status == service.HealthDown {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, rsp)
+}
+
 // Register 是注册接口的处理函数
 func Register(c *gin.Context) {
 	// 创建请求和响应对象
@@ -43,8 +62,10 @@ func Register(c *gin.Context) {
 	}
 
 	// 调用服务层的注册函数进行注册
-	if err := service.Register(req); err != nil {
-		rsp.ResponseWithError(c, CodeRegisterErr, err.Error()) // 返回注册错误响应
+	uuid := utils.Md5String(req.UserName + time.Now().GoString())
+	ctx := context.WithValue(newRequestContext(c), "uuid", uuid)
+	if err := service.Register(ctx, req); err != nil {
+		rsp.ResponseWithError(c, challengeErrCode(err, passwordErrCode(err, CodeRegisterErr)), err.Error()) // 返回注册错误响应
 		return
 	}
 
@@ -68,29 +89,28 @@ func Login(c *gin.Context) {
 
 	// 生成用户唯一标识符
 	uuid := utils.Md5String(req.UserName + time.Now().GoString())
-	ctx := context.WithValue(context.Background(), "uuid", uuid)
+	ctx := context.WithValue(newRequestContext(c), "uuid", uuid)
 
 	// 记录登录开始信息
-	log.Infof("loggin start, user:%s, password:%s", req.UserName, req.PassWord)
+	log.Infof("loggin start, user:%s", req.UserName)
 
 	// 进行用户登录操作
-	session, err := service.Login(ctx, req)
+	session, ttl, err := service.Login(ctx, req)
 	if err != nil {
 		// 登录失败，返回错误响应
-		rsp.ResponseWithError(c, CodeLoginErr, err.Error())
+		rsp.ResponseWithError(c, challengeErrCode(err, CodeLoginErr), err.Error())
 		return
 	}
 
 	// 设置会话标识符到客户端 Cookie 中
-	c.SetCookie(static.SessionKey, session, static.CookieExpire, "/", "", false, true)
+	SetSessionCookie(c, session, ttl)
 
 	// 返回登录成功响应
 	rsp.ResponseSuccess(c)
 }
 
 func Logout(c *gin.Context) {
-	session, _ := c.Cookie(static.SessionKey)
-	ctx := context.WithValue(context.Background(), static.SessionKey, session)
+	ctx := newRequestContext(c)
 	req := &service.LogoutRequest{}
 	rsp := &HttpResponse{}
 	err := c.ShouldBindJSON(req)
@@ -105,7 +125,7 @@ func Logout(c *gin.Context) {
 		rsp.ResponseWithError(c, CodeLogoutErr, err.Error())
 		return
 	}
-	c.SetCookie(static.SessionKey, session, -1, "/", "", false, true)
+	ClearSessionCookie(c)
 	rsp.ResponseSuccess(c)
 
 }
@@ -113,8 +133,7 @@ func Logout(c *gin.Context) {
 // GetUserInfo 获取用户信息
 func GetUserInfo(c *gin.Context) {
 	userName := c.Query("username")
-	session, _ := c.Cookie(static.SessionKey)
-	ctx := context.WithValue(context.Background(), static.SessionKey, session)
+	ctx := newRequestContext(c)
 	req := &service.GetUserInfoRequest{
 		UserName: userName,
 	}
@@ -139,9 +158,9 @@ func UpdateNickName(c *gin.Context) {
 		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
 		return
 	}
-	session, _ := c.Cookie(static.SessionKey)
+	session := GetSessionCookie(c)
 	log.Infof("UpdateNickName|session=%s", session)
-	ctx := context.WithValue(context.Background(), static.SessionKey, session)
+	ctx := newRequestContext(c)
 	uuid := utils.Md5String(req.UserName + time.Now().GoString())
 	ctx = context.WithValue(ctx, "uuid", uuid)
 	if err := service.UpdateUserNickName(ctx, req); err != nil {
@@ -150,3 +169,53 @@ func UpdateNickName(c *gin.Context) {
 	}
 	rsp.ResponseSuccess(c)
 }
+
+// ChangePassword 修改密码
+func ChangePassword(c *gin.Context) {
+	req := &service.ChangePasswordRequest{}
+	rsp := &HttpResponse{}
+	err := c.ShouldBindJSON(req)
+	if err != nil {
+		log.Errorf("bind change password request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(req.UserName + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	if err := service.ChangePassword(ctx, req); err != nil {
+		rsp.ResponseWithError(c, challengeErrCode(err, passwordErrCode(err, CodeChangePasswordErr)), err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// passwordErrCode 密码不满足密码策略时使用单独的错误码
+func passwordErrCode(err error, code ErrCode) ErrCode {
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return CodePasswordPolicyErr
+	}
+	return code
+}
+
+// DeleteAccount 注销账号
+func DeleteAccount(c *gin.Context) {
+	req := &service.DeleteAccountRequest{}
+	rsp := &HttpResponse{}
+	err := c.ShouldBindJSON(req)
+	if err != nil {
+		log.Errorf("bind delete account request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(req.UserName + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	if err := service.DeleteAccount(ctx, req); err != nil {
+		rsp.ResponseWithError(c, CodeDeleteAccountErr, err.Error())
+		return
+	}
+	ClearSessionCookie(c)
+	rsp.ResponseSuccess(c)
+}
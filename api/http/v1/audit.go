@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+)
+
+// ListAuditLogs 按条件查询审计日志
+func ListAuditLogs(c *gin.Context) {
+	req := &service.ListAuditLogsRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind list audit logs request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.ListAuditLogs(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeAuditErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// VerifyAuditChain 校验当前租户审计日志的哈希链是否被篡改
+func VerifyAuditChain(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.VerifyAuditChain(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeAuditErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"time"
+)
+
+// GetChallenge 获取人机校验挑战，scene=register 时总是下发，scene=login 时登录失败次数过多才下发
+func GetChallenge(c *gin.Context) {
+	req := &service.ChallengeRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind get challenge request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	uuid := utils.Md5String(req.Scene + time.Now().GoString())
+	ctx := context.WithValue(newRequestContext(c), "uuid", uuid)
+	challenge, err := service.IssueChallenge(ctx, req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeChallengeErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, challenge)
+}
+
+// challengeErrCode 人机校验相关的错误使用单独的错误码，页面据此展示新的挑战
+func challengeErrCode(err error, code ErrCode) ErrCode {
+	if err == service.ErrChallengeRequired || err == service.ErrChallengeFailed {
+		return CodeChallengeErr
+	}
+	return code
+}
@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"my_user_system/conf"
+	"my_user_system/static"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sessionCookieName 会话 Cookie 名
+func sessionCookieName() string {
+	if name := conf.GetGlobalConfig().Cookie.Name; name != "" {
+		return name
+	}
+	return static.SessionKey
+}
+
+// cookieSameSite 把配置的 SameSite 转换为 http.SameSite，未配置或无法识别时为 Lax
+func cookieSameSite(sameSite string) http.SameSite {
+	switch strings.ToLower(sameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// setCookie 按配置的域名、路径、Secure 和 SameSite 写入 Cookie，maxAge 小于 0 时删除
+func setCookie(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	cookieConf := conf.GetGlobalConfig().Cookie
+	path := cookieConf.Path
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     path,
+		Domain:   cookieConf.Domain,
+		Secure:   cookieConf.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cookieSameSite(cookieConf.SameSite),
+	})
+}
+
+// GetSessionCookie 读取 Cookie 中的会话，不存在时返回空串
+func GetSessionCookie(c *gin.Context) string {
+	session, _ := c.Cookie(sessionCookieName())
+	return session
+}
+
+// SetSessionCookie 把会话写入 Cookie，Max-Age 与会话在服务端的剩余有效期一致。
+// 会话变化时同时重新签发 CSRF 令牌，原来的令牌绑定的是旧会话
+func SetSessionCookie(c *gin.Context, session string, ttl time.Duration) {
+	maxAge := int(ttl / time.Second)
+	if maxAge <= 0 {
+		ClearSessionCookie(c)
+		return
+	}
+	if GetSessionCookie(c) != session {
+		reissueCSRFCookie(c, session)
+	}
+	setCookie(c, sessionCookieName(), session, maxAge, true)
+}
+
+// ClearSessionCookie 删除 Cookie 中的会话和 CSRF 令牌
+func ClearSessionCookie(c *gin.Context) {
+	setCookie(c, sessionCookieName(), "", -1, true)
+	clearCSRFCookie(c)
+}
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"my_user_system/utils"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultCSRFCookieName = "csrf_token"
+	defaultCSRFHeaderName = "X-CSRF-Token"
+	// csrfTokenExpire CSRF 令牌 Cookie 的有效期，单位秒
+	csrfTokenExpire = 86400
+)
+
+var (
+	csrfKey     []byte
+	csrfKeyOnce sync.Once
+)
+
+// csrfSecret 令牌签名密钥，未配置时随机生成，此时令牌只在本实例内有效
+func csrfSecret() []byte {
+	csrfKeyOnce.Do(func() {
+		if secret := conf.GetGlobalConfig().CSRF.Secret; secret != "" {
+			csrfKey = []byte(secret)
+			return
+		}
+		log.Warnf("csrf secret not configured, use a random one, tokens are only valid on this instance")
+		secret, err := utils.RandomToken(32)
+		if err != nil {
+			panic("generate csrf secret err:" + err.Error())
+		}
+		csrfKey = []byte(secret)
+	})
+	return csrfKey
+}
+
+func csrfCookieName() string {
+	if name := conf.GetGlobalConfig().CSRF.CookieName; name != "" {
+		return name
+	}
+	return defaultCSRFCookieName
+}
+
+func csrfHeaderName() string {
+	if name := conf.GetGlobalConfig().CSRF.HeaderName; name != "" {
+		return name
+	}
+	return defaultCSRFHeaderName
+}
+
+// signCSRF 计算随机串的签名，签名内容带上会话标识，令牌只能和签发时的会话一起使用
+func signCSRF(session, nonce string) string {
+	mac := hmac.New(sha256.New, csrfSecret())
+	mac.Write([]byte(session))
+	mac.Write([]byte{0})
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newCSRFToken 为会话生成 随机串.签名 形式的令牌，未登录时 session 为空串
+func newCSRFToken(session string) (string, error) {
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return nonce + "." + signCSRF(session, nonce), nil
+}
+
+// validCSRFToken 校验令牌是本服务为该会话签发的
+func validCSRFToken(token, session string) bool {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signCSRF(session, nonce)))
+}
+
+// reissueCSRFCookie 登录、切换会话后按新会话重新签发令牌，页面需要重新读取 Cookie 或调用 /user/csrf_token
+func reissueCSRFCookie(c *gin.Context, session string) {
+	if !conf.GetGlobalConfig().CSRF.Enabled {
+		return
+	}
+	token, err := newCSRFToken(session)
+	if err != nil {
+		log.Errorf("reissueCSRFCookie|generate token err:%v", err)
+		clearCSRFCookie(c)
+		return
+	}
+	setCookie(c, csrfCookieName(), token, csrfTokenExpire, false)
+}
+
+// clearCSRFCookie 删除 Cookie 中的令牌，登出时调用
+func clearCSRFCookie(c *gin.Context) {
+	setCookie(c, csrfCookieName(), "", -1, false)
+}
+
+// CheckCSRF 双重提交校验：请求头中的令牌必须与 Cookie 中的一致，且是为当前会话签发的。
+// 第三方页面无法读取本站 Cookie，也就无法在请求头中带上正确的令牌；能写入子域名 Cookie 的攻击者也无法伪造别人会话的令牌
+func CheckCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(csrfCookieName())
+	if err != nil || cookie == "" {
+		return false
+	}
+	header := c.GetHeader(csrfHeaderName())
+	if subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return false
+	}
+	return validCSRFToken(cookie, GetSessionCookie(c))
+}
+
+// CSRFToken 为当前会话签发 CSRF 令牌，Cookie 中已有该会话的有效令牌时直接返回。
+// 页面加载时调用一次，之后的写请求在 header_name 指定的请求头中带上 token
+func CSRFToken(c *gin.Context) {
+	rsp := &HttpResponse{}
+	session := GetSessionCookie(c)
+	token, err := c.Cookie(csrfCookieName())
+	if err != nil || !validCSRFToken(token, session) {
+		if token, err = newCSRFToken(session); err != nil {
+			log.Errorf("CSRFToken|generate token err:%v", err)
+			rsp.ResponseWithError(c, CodeCSRFErr, err.Error())
+			return
+		}
+	}
+	// 页面脚本需要读取令牌，不能设置 HttpOnly
+	setCookie(c, csrfCookieName(), token, csrfTokenExpire, false)
+	rsp.ResponseWithData(c, gin.H{
+		"header_name": csrfHeaderName(),
+		"token":       token,
+	})
+}
@@ -15,6 +15,21 @@ const (
 	CodeLogoutErr         ErrCode = 10004 // 登出错误
 	CodeGetUserInfoErr    ErrCode = 10005 // 获取用户信息错误
 	CodeUpdateUserInfoErr ErrCode = 10006 // 更新用户信息错误
+	CodeExportErr         ErrCode = 10007 // 数据导出错误
+	CodeRBACErr           ErrCode = 10008 // 角色权限管理错误
+	CodeChangePasswordErr ErrCode = 10009 // 修改密码错误
+	CodeAdminUserErr      ErrCode = 10010 // 管理后台用户管理错误
+	CodeDeleteAccountErr  ErrCode = 10011 // 注销账号错误
+	CodeImpersonateErr    ErrCode = 10012 // 模拟登录错误
+	CodeTenantErr         ErrCode = 10013 // 租户管理错误
+	CodeInviteErr         ErrCode = 10014 // 邀请码管理错误
+	CodeCSRFErr           ErrCode = 10015 // CSRF 令牌错误
+	CodeChallengeErr      ErrCode = 10016 // 需要人机校验或校验未通过，页面需要获取新的挑战
+	CodePasswordPolicyErr ErrCode = 10017 // 密码不满足密码策略
+	CodeLoginHistoryErr   ErrCode = 10018 // 查询登录记录错误
+	CodeAuditErr          ErrCode = 10019 // 审计日志查询或校验错误
+	CodeWebhookErr        ErrCode = 10020 // Webhook 订阅或投递错误
+	CodeOAuthErr          ErrCode = 10021 // OAuth 授权或客户端管理错误
 )
 
 // DebugType 表示调试类型的自定义整型
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"net/http"
+	"time"
+)
+
+// CreateExport 申请导出个人数据
+func CreateExport(c *gin.Context) {
+	rsp := &HttpResponse{}
+	session := GetSessionCookie(c)
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(session + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	data, err := service.CreateExport(ctx)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeExportErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// GetExportStatus 查询导出任务状态
+func GetExportStatus(c *gin.Context) {
+	req := &service.GetExportStatusRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind get export status request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	session := GetSessionCookie(c)
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(session + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	data, err := service.GetExportStatus(ctx, req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeExportErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// DownloadExport 下载导出包，链接只能使用一次
+func DownloadExport(c *gin.Context) {
+	rsp := &HttpResponse{}
+	session := GetSessionCookie(c)
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(session + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	content, err := service.DownloadExport(ctx, c.Query("token"))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeExportErr, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=export_%s.zip", time.Now().Format("20060102150405")))
+	c.Data(http.StatusOK, "application/zip", content)
+}
+
+// GetExportPublicKey 获取校验导出包签名的公钥
+func GetExportPublicKey(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.GetExportPublicKey()
+	if err != nil {
+		rsp.ResponseWithError(c, CodeExportErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
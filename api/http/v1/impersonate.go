@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"time"
+)
+
+// ImpersonateStart 管理员开始模拟用户登录，成功后 Cookie 中的会话替换为模拟登录会话
+func ImpersonateStart(c *gin.Context) {
+	req := &service.ImpersonateRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind impersonate request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	session, ttl, err := service.ImpersonateStart(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeImpersonateErr, err.Error())
+		return
+	}
+	SetSessionCookie(c, session, ttl)
+	rsp.ResponseSuccess(c)
+}
+
+// ImpersonateStop 结束模拟登录，恢复管理员原来的会话
+func ImpersonateStop(c *gin.Context) {
+	rsp := &HttpResponse{}
+	session := GetSessionCookie(c)
+	ctx := newRequestContext(c)
+	uuid := utils.Md5String(session + time.Now().GoString())
+	ctx = context.WithValue(ctx, "uuid", uuid)
+	actorSession, ttl, err := service.ImpersonateStop(ctx)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeImpersonateErr, err.Error())
+		return
+	}
+	if actorSession != "" {
+		SetSessionCookie(c, actorSession, ttl)
+	} else {
+		ClearSessionCookie(c)
+	}
+	rsp.ResponseSuccess(c)
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"net/http"
+	"time"
+)
+
+// Introspect 令牌内省，RFC 7662 风格：调用方通过 HTTP Basic 认证，表单参数 token 为待校验的会话。
+// 响应不使用 HttpResponse 包装，直接返回内省结果
+func Introspect(c *gin.Context) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok || !service.AuthenticateIntrospectClient(clientID, secret) {
+		log.Warnf("Introspect|invalid client credentials, client_id=%s|ip=%s", clientID, c.ClientIP())
+		c.Header("WWW-Authenticate", `Basic realm="introspect"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	uuid := utils.Md5String(clientID + time.Now().GoString())
+	ctx := context.WithValue(newRequestContext(c), "uuid", uuid)
+	rsp, err := service.Introspect(ctx, clientID, token)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+)
+
+// CreateInvitation 生成邀请码
+func CreateInvitation(c *gin.Context) {
+	req := &service.CreateInvitationRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind create invitation request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.CreateInvitation(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeInviteErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// ListInvitations 列出当前租户的邀请码
+func ListInvitations(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.ListInvitations(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeInviteErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// RevokeInvitation 撤销邀请码
+func RevokeInvitation(c *gin.Context) {
+	req := &service.RevokeInvitationRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind revoke invitation request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.RevokeInvitation(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeInviteErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"time"
+)
+
+// ListLoginHistory 查询当前登录用户的登录记录
+func ListLoginHistory(c *gin.Context) {
+	req := &service.LoginHistoryRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind list login history request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	session := GetSessionCookie(c)
+	uuid := utils.Md5String(session + time.Now().GoString())
+	ctx := context.WithValue(newRequestContext(c), "uuid", uuid)
+	data, err := service.ListLoginHistory(ctx, req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeLoginHistoryErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// AdminListLoginHistory 管理后台查询用户的登录记录
+func AdminListLoginHistory(c *gin.Context) {
+	req := &service.AdminLoginHistoryRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind admin list login history request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.AdminListLoginHistory(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeLoginHistoryErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+	"my_user_system/utils"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func newOAuthContext(c *gin.Context, key string) context.Context {
+	uuid := utils.Md5String(key + time.Now().GoString())
+	return context.WithValue(newRequestContext(c), "uuid", uuid)
+}
+
+// oauthClientCredentials 客户端凭证优先取 HTTP Basic，按 RFC 6749 2.3.1 用户名和密码需要先做表单解码
+func oauthClientCredentials(c *gin.Context, clientID, secret string) (string, string, bool) {
+	user, pass, ok := c.Request.BasicAuth()
+	if !ok {
+		return clientID, secret, false
+	}
+	if decoded, err := url.QueryUnescape(user); err == nil {
+		user = decoded
+	}
+	if decoded, err := url.QueryUnescape(pass); err == nil {
+		pass = decoded
+	}
+	return user, pass, true
+}
+
+// oauthErrorResponse 按 RFC 6749 返回错误，客户端认证失败为 401，其他协议错误为 400
+func oauthErrorResponse(c *gin.Context, err error, basicAuth bool) {
+	c.Header("Cache-Control", "no-store")
+	oauthErr, ok := err.(*service.OAuthError)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case service.OAuthErrInvalidClient:
+		status = http.StatusUnauthorized
+		if basicAuth {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	case service.OAuthErrInvalidToken:
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
+
+// OAuthAuthorize 授权地址，未登录时跳转登录页，需要用户确认时跳转授权确认页，否则带着授权码或错误跳转回应用。
+// 客户端或回调地址无效时不能跳转回应用，直接返回错误
+func OAuthAuthorize(c *gin.Context) {
+	req := &service.OAuthAuthorizeRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		oauthErrorResponse(c, &service.OAuthError{Code: service.OAuthErrInvalidRequest, Description: err.Error()}, false)
+		return
+	}
+	result, err := service.Authorize(newOAuthContext(c, req.ClientID), req)
+	if err != nil {
+		oauthErrorResponse(c, err, false)
+		return
+	}
+	switch {
+	case result.NeedLogin:
+		c.Redirect(http.StatusFound, service.OAuthLoginPage()+"?redirect="+url.QueryEscape(c.Request.URL.RequestURI()))
+	case result.NeedConsent:
+		// 确认页是静态页面，通过 prefix 得知租户路径，接口调用时带上
+		prefix := strings.TrimSuffix(c.Request.URL.Path, "/oauth/authorize")
+		c.Redirect(http.StatusFound, service.OAuthConsentPage()+"?"+c.Request.URL.RawQuery+"&prefix="+url.QueryEscape(prefix))
+	default:
+		c.Redirect(http.StatusFound, result.RedirectURL)
+	}
+}
+
+// OAuthConsentInfo 授权确认页展示的应用和申请的 scope
+func OAuthConsentInfo(c *gin.Context) {
+	req := &service.OAuthAuthorizeRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind oauth consent info request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.GetOAuthConsentInfo(newOAuthContext(c, req.ClientID), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeOAuthErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// OAuthConsent 用户同意或拒绝授权，返回跳转回应用的地址
+func OAuthConsent(c *gin.Context) {
+	req := &service.OAuthConsentRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind oauth consent request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.OAuthConsent(newOAuthContext(c, req.ClientID), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeOAuthErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// OAuthToken 令牌接口，表单参数，客户端通过 HTTP Basic 或表单中的 client_id、client_secret 认证。
+// 响应不使用 HttpResponse 包装，字段与 RFC 6749 一致
+func OAuthToken(c *gin.Context) {
+	req := &service.OAuthTokenRequest{}
+	if err := c.ShouldBindWith(req, binding.Form); err != nil {
+		oauthErrorResponse(c, &service.OAuthError{Code: service.OAuthErrInvalidRequest, Description: err.Error()}, false)
+		return
+	}
+	var basicAuth bool
+	req.ClientID, req.ClientSecret, basicAuth = oauthClientCredentials(c, req.ClientID, req.ClientSecret)
+	rsp, err := service.Token(newOAuthContext(c, req.ClientID), req)
+	if err != nil {
+		oauthErrorResponse(c, err, basicAuth)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, rsp)
+}
+
+// OAuthRevoke 吊销令牌，RFC 7009 风格，令牌无效时同样返回 200
+func OAuthRevoke(c *gin.Context) {
+	req := &service.OAuthRevokeRequest{}
+	if err := c.ShouldBindWith(req, binding.Form); err != nil {
+		oauthErrorResponse(c, &service.OAuthError{Code: service.OAuthErrInvalidRequest, Description: err.Error()}, false)
+		return
+	}
+	var basicAuth bool
+	req.ClientID, req.ClientSecret, basicAuth = oauthClientCredentials(c, req.ClientID, req.ClientSecret)
+	if err := service.RevokeOAuthToken(newOAuthContext(c, req.ClientID), req); err != nil {
+		oauthErrorResponse(c, err, basicAuth)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// OAuthUserInfo 用户信息接口，访问令牌放在 Authorization: Bearer 请求头中
+func OAuthUserInfo(c *gin.Context) {
+	const bearer = "bearer "
+	token := ""
+	if auth := c.GetHeader("Authorization"); len(auth) > len(bearer) && strings.EqualFold(auth[:len(bearer)], bearer) {
+		token = strings.TrimSpace(auth[len(bearer):])
+	}
+	if token == "" {
+		oauthErrorResponse(c, &service.OAuthError{Code: service.OAuthErrInvalidToken, Description: "缺少访问令牌"}, false)
+		return
+	}
+	rsp, err := service.GetOAuthUserInfo(newOAuthContext(c, token), token)
+	if err != nil {
+		oauthErrorResponse(c, err, false)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, rsp)
+}
+
+// CreateOAuthClient 注册 OAuth 客户端
+func CreateOAuthClient(c *gin.Context) {
+	req := &service.CreateOAuthClientRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind create oauth client request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.CreateOAuthClient(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeOAuthErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// ListOAuthClients 列出当前租户的 OAuth 客户端
+func ListOAuthClients(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.ListOAuthClients(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeOAuthErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// UpdateOAuthClient 修改 OAuth 客户端
+func UpdateOAuthClient(c *gin.Context) {
+	req := &service.UpdateOAuthClientRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind update oauth client request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.UpdateOAuthClient(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeOAuthErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
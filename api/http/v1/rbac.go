@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/model"
+	"my_user_system/service"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+// newAdminContext 构造管理接口的上下文，在请求上下文的基础上携带请求标识和鉴权中间件解析出的操作人
+func newAdminContext(c *gin.Context) context.Context {
+	operator := ""
+	if principal, ok := c.Get(static.PrincipalKey); ok {
+		operator = principal.(*model.User).Name
+	}
+	ctx := context.WithValue(newRequestContext(c), static.OperatorKey, operator)
+	uuid := utils.Md5String(operator + time.Now().GoString())
+	return context.WithValue(ctx, "uuid", uuid)
+}
+
+// ListRoles 列出当前租户的全部角色
+func ListRoles(c *gin.Context) {
+	rsp := &HttpResponse{}
+	roles, err := service.ListRoles(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, roles)
+}
+
+// CreateRole 创建角色
+func CreateRole(c *gin.Context) {
+	req := &service.CreateRoleRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind create role request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.CreateRole(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// GrantPermission 给角色授予权限
+func GrantPermission(c *gin.Context) {
+	req := &service.RolePermissionRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind grant permission request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.GrantPermission(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// RevokePermission 收回角色的权限
+func RevokePermission(c *gin.Context) {
+	req := &service.RolePermissionRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind revoke permission request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.RevokePermission(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// AssignRole 给用户分配角色
+func AssignRole(c *gin.Context) {
+	req := &service.UserRoleRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind assign role request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.AssignRole(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// UnassignRole 取消用户的角色
+func UnassignRole(c *gin.Context) {
+	req := &service.UserRoleRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind unassign role request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.UnassignRole(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// GetUserRoles 查询用户的角色与权限
+func GetUserRoles(c *gin.Context) {
+	req := &service.GetUserRolesRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind get user roles request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.GetUserRoles(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeRBACErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
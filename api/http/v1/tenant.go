@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+)
+
+// CreateOrganization 创建组织
+func CreateOrganization(c *gin.Context) {
+	req := &service.CreateOrgRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind create org request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.CreateOrganization(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeTenantErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// ListOrganizations 列出全部组织
+func ListOrganizations(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.ListOrganizations(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeTenantErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// ListOrgMembers 列出组织成员
+func ListOrgMembers(c *gin.Context) {
+	req := &service.OrgRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind list org members request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.ListOrgMembers(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeTenantErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// SetOrgMemberRole 设置组织成员角色
+func SetOrgMemberRole(c *gin.Context) {
+	req := &service.SetOrgMemberRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind set org member request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.SetOrgMemberRole(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeTenantErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// FlushTenantCache 清理租户缓存
+func FlushTenantCache(c *gin.Context) {
+	req := &service.OrgRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind flush tenant cache request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.FlushTenantCache(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeTenantErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/service"
+)
+
+// CreateWebhook 创建 Webhook 订阅
+func CreateWebhook(c *gin.Context) {
+	req := &service.CreateWebhookRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind create webhook request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.CreateWebhook(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// ListWebhooks 列出当前租户的 Webhook 订阅
+func ListWebhooks(c *gin.Context) {
+	rsp := &HttpResponse{}
+	data, err := service.ListWebhooks(newAdminContext(c))
+	if err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// UpdateWebhook 修改 Webhook 订阅
+func UpdateWebhook(c *gin.Context) {
+	req := &service.UpdateWebhookRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind update webhook request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.UpdateWebhook(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// DeleteWebhook 删除 Webhook 订阅
+func DeleteWebhook(c *gin.Context) {
+	req := &service.DeleteWebhookRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind delete webhook request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.DeleteWebhook(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
+
+// ListWebhookDeliveries 按条件查询 Webhook 投递日志
+func ListWebhookDeliveries(c *gin.Context) {
+	req := &service.ListWebhookDeliveriesRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Errorf("bind list webhook deliveries request err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	data, err := service.ListWebhookDeliveries(newAdminContext(c), req)
+	if err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseWithData(c, data)
+}
+
+// RedeliverWebhook 手动重新投递一条 Webhook
+func RedeliverWebhook(c *gin.Context) {
+	req := &service.RedeliverWebhookRequest{}
+	rsp := &HttpResponse{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Errorf("bind redeliver webhook request json err %v", err)
+		rsp.ResponseWithError(c, CodeBodyBindErr, err.Error())
+		return
+	}
+	if err := service.RedeliverWebhook(newAdminContext(c), req); err != nil {
+		rsp.ResponseWithError(c, CodeWebhookErr, err.Error())
+		return
+	}
+	rsp.ResponseSuccess(c)
+}
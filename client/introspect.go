@@ -0,0 +1,257 @@
+// Package client 供其他 Go 服务调用用户系统的客户端，只依赖标准库。
+//
+// 收到用户的 user_session Cookie 或 Bearer 令牌后，通过 Introspector 调用用户系统的内省接口
+// POST /internal/introspect 校验，不要直接读取用户系统的 Redis：
+//
+//	introspector := client.NewIntrospector("http://user-system:8080/internal/introspect", "order_service", secret)
+//	result, err := introspector.Introspect(ctx, client.SessionFromRequest(r, ""))
+//	if err != nil || !result.Active {
+//		// 返回 401
+//	}
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSessionCookie 用户系统默认的会话 Cookie 名
+	DefaultSessionCookie = "user_session"
+	defaultTimeout       = 3 * time.Second
+	defaultCacheTTL      = 5 * time.Second
+	defaultCacheSize     = 10000
+	// maxResponseBytes 内省响应体的最大长度
+	maxResponseBytes = 1 << 20
+)
+
+// ErrInvalidClient 服务凭证无效或未在用户系统中配置
+var ErrInvalidClient = errors.New("introspect: invalid client credentials")
+
+// Introspection 内省结果，Active 为 false 时其余字段为空
+type Introspection struct {
+	Active       bool     `json:"active"`
+	TokenType    string   `json:"token_type,omitempty"`
+	Sub          string   `json:"sub,omitempty"`
+	UserID       int      `json:"user_id,omitempty"`
+	UserName     string   `json:"username,omitempty"`
+	TenantID     int      `json:"tenant_id,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	Exp          int64    `json:"exp,omitempty"`
+	Impersonator string   `json:"impersonator,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"` // OAuth 访问令牌所属的客户端
+	Scope        string   `json:"scope,omitempty"`     // OAuth 访问令牌的 scope，空格分隔
+}
+
+// HasScope OAuth 访问令牌是否包含某个 scope
+func (i *Introspection) HasScope(scope string) bool {
+	for _, s := range strings.Fields(i.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiresAt 会话的过期时间，会话续期后会延后
+func (i *Introspection) ExpiresAt() time.Time {
+	return time.Unix(i.Exp, 0)
+}
+
+// HasRole 判断用户是否拥有某个角色
+func (i *Introspection) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Option Introspector 的可选配置
+type Option func(*Introspector)
+
+// WithHTTPClient 使用自定义的 http.Client，默认超时 3 秒
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(i *Introspector) {
+		i.httpClient = httpClient
+	}
+}
+
+// WithCacheTTL 内省结果的缓存时间，默认 5 秒，不超过会话的过期时间。为 0 时不缓存
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(i *Introspector) {
+		i.cacheTTL = ttl
+	}
+}
+
+// WithCacheSize 缓存的最大条目数，默认 10000
+func WithCacheSize(size int) Option {
+	return func(i *Introspector) {
+		if size > 0 {
+			i.cacheSize = size
+		}
+	}
+}
+
+// WithTenant 通过请求头指定租户，header 与用户系统配置的 tenant.header 一致。
+// 也可以直接使用 /t/<tenant>/internal/introspect 形式的地址
+func WithTenant(header, code string) Option {
+	return func(i *Introspector) {
+		i.tenantHeader, i.tenantCode = header, code
+	}
+}
+
+type cacheEntry struct {
+	result   *Introspection
+	expireAt time.Time
+}
+
+// Introspector 内省接口客户端，并发安全
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	secret       string
+	tenantHeader string
+	tenantCode   string
+	httpClient   *http.Client
+	cacheTTL     time.Duration
+	cacheSize    int
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+// NewIntrospector 创建内省客户端，endpoint 为内省接口的完整地址，clientID 和 secret 为用户系统中配置的服务凭证
+func NewIntrospector(endpoint, clientID, secret string, opts ...Option) *Introspector {
+	i := &Introspector{
+		endpoint:   endpoint,
+		clientID:   clientID,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		cacheTTL:   defaultCacheTTL,
+		cacheSize:  defaultCacheSize,
+		cache:      make(map[string]*cacheEntry),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Introspect 校验令牌，令牌为空时直接返回无效。结果按 WithCacheTTL 缓存，无效的结果同样缓存
+func (i *Introspector) Introspect(ctx context.Context, token string) (*Introspection, error) {
+	if token == "" {
+		return &Introspection{}, nil
+	}
+	if result, ok := i.getCache(token); ok {
+		return result, nil
+	}
+	result, err := i.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	i.setCache(token, result)
+	return result, nil
+}
+
+func (i *Introspector) introspect(ctx context.Context, token string) (*Introspection, error) {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(i.clientID, i.secret)
+	if i.tenantHeader != "" && i.tenantCode != "" {
+		req.Header.Set(i.tenantHeader, i.tenantCode)
+	}
+	rsp, err := i.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspect: %w", err)
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("introspect: read response: %w", err)
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrInvalidClient
+	default:
+		return nil, fmt.Errorf("introspect: unexpected status %d: %s", rsp.StatusCode, body)
+	}
+	result := &Introspection{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("introspect: decode response: %w", err)
+	}
+	return result, nil
+}
+
+// getCache 获取未过期的缓存结果，有效的会话到达过期时间后视为无效
+func (i *Introspector) getCache(token string) (*Introspection, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry, ok := i.cache[token]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(i.cache, token)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// setCache 缓存结果，缓存时间不超过会话的过期时间。缓存已满时先清理过期条目，仍然满时随机淘汰一条
+func (i *Introspector) setCache(token string, result *Introspection) {
+	if i.cacheTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	expireAt := now.Add(i.cacheTTL)
+	if result.Active && result.Exp > 0 && result.ExpiresAt().Before(expireAt) {
+		expireAt = result.ExpiresAt()
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.cache[token]; !ok && len(i.cache) >= i.cacheSize {
+		for key, entry := range i.cache {
+			if now.After(entry.expireAt) {
+				delete(i.cache, key)
+			}
+		}
+		for key := range i.cache {
+			if len(i.cache) < i.cacheSize {
+				break
+			}
+			delete(i.cache, key)
+		}
+	}
+	i.cache[token] = &cacheEntry{result: result, expireAt: expireAt}
+}
+
+// SessionFromRequest 从请求中取会话：优先取 Authorization: Bearer，其次取会话 Cookie。
+// cookieName 为空时为 user_session
+func SessionFromRequest(r *http.Request, cookieName string) string {
+	const bearer = "bearer "
+	if auth := r.Header.Get("Authorization"); len(auth) > len(bearer) && strings.EqualFold(auth[:len(bearer)], bearer) {
+		return strings.TrimSpace(auth[len(bearer):])
+	}
+	if cookieName == "" {
+		cookieName = DefaultSessionCookie
+	}
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
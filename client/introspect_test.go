@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newIntrospectServer(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "svc" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Tenant") != "acme" {
+			t.Errorf("tenant header = %q", r.Header.Get("X-Tenant"))
+		}
+		result := &Introspection{}
+		if r.PostFormValue("token") == "good" {
+			result = &Introspection{Active: true, UserID: 7, UserName: "alice", Roles: []string{"admin"},
+				Exp: time.Now().Add(time.Hour).Unix()}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestIntrospect(t *testing.T) {
+	var calls int32
+	server := newIntrospectServer(t, &calls)
+	defer server.Close()
+	introspector := NewIntrospector(server.URL, "svc", "s3cret", WithTenant("X-Tenant", "acme"))
+
+	for n := 0; n < 2; n++ {
+		result, err := introspector.Introspect(context.Background(), "good")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Active || result.UserName != "alice" || !result.HasRole("admin") {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+	result, err := introspector.Introspect(context.Background(), "bad")
+	if err != nil || result.Active {
+		t.Errorf("bad token: result=%+v err=%v", result, err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (second lookup of good token should hit cache)", calls)
+	}
+
+	bad := NewIntrospector(server.URL, "svc", "wrong", WithTenant("X-Tenant", "acme"))
+	if _, err := bad.Introspect(context.Background(), "good"); err != ErrInvalidClient {
+		t.Errorf("err = %v, want ErrInvalidClient", err)
+	}
+}
+
+func TestSessionFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: DefaultSessionCookie, Value: "from-cookie"})
+	if got := SessionFromRequest(r, ""); got != "from-cookie" {
+		t.Errorf("got %q, want from-cookie", got)
+	}
+	r.Header.Set("Authorization", "Bearer from-header")
+	if got := SessionFromRequest(r, ""); got != "from-header" {
+		t.Errorf("got %q, want from-header", got)
+	}
+}
@@ -2,14 +2,29 @@ package main
 
 import (
 	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
 	"my_user_system/router"
+	"my_user_system/service"
 )
 
 func Init() {
 	conf.InitConfig()
+	cache.InitResilience()
+	cache.InitLocalCache()
+	cache.InitIntrospectCache()
+	cache.InitSessionStore()
+	dao.InitTables()
+	service.InitTenants()
+	service.InitRBAC()
+	service.InitPasswordPolicy()
+	service.InitExport()
+	service.InitOutboxRelay()
+	service.InitWebhookDispatcher()
 }
 
 func main() {
 	Init()
+	router.InitGRPCServer()
 	router.InitRouterAndServe()
 }
@@ -1,10 +1,12 @@
 package conf
 
 import (
+	"fmt"
 	rlog "github.com/lestrrat-go/file-rotatelogs"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,6 +16,9 @@ var (
 	once   sync.Once
 )
 
+// placeholderSecretPrefix 示例配置中密钥占位值的前缀，必须替换后才能启动
+const placeholderSecretPrefix = "change_me"
+
 type LogConf struct {
 	LogPattern string `yaml:"log_pattern" mapstructure:"log_pattern"` // 日志输出标准， 终端输出/文件输出
 	LogPath    string `yaml:"log_path" mapstructure:"log_path"`       // 日志路径
@@ -37,11 +42,232 @@ type RedisConf struct {
 	DB       int    `yaml:"rdb" mapstructure:"rdb"`
 	PassWord string `yaml:"passwd" mapstructure:"passwd"`
 	PoolSize int    `yaml:"poolsize" mapstructure:"poolsize"`
+
+	Mode             string       `yaml:"mode" mapstructure:"mode"`                       // 部署模式，single/sentinel/cluster，为空按 single 处理
+	Addrs            []string     `yaml:"addrs" mapstructure:"addrs"`                     // 哨兵或集群节点地址，单节点模式下为空时使用 rhost:rport
+	MasterName       string       `yaml:"master_name" mapstructure:"master_name"`         // 哨兵模式下的主节点名
+	SentinelPassword string       `yaml:"sentinel_passwd" mapstructure:"sentinel_passwd"` // 哨兵节点密码
+	DialTimeout      int          `yaml:"dial_timeout" mapstructure:"dial_timeout"`       // 连接超时，单位毫秒，0 为默认值
+	ReadTimeout      int          `yaml:"read_timeout" mapstructure:"read_timeout"`       // 读超时，单位毫秒，0 为默认值
+	WriteTimeout     int          `yaml:"write_timeout" mapstructure:"write_timeout"`     // 写超时，单位毫秒，0 为默认值
+	MaxRetries       int          `yaml:"max_retries" mapstructure:"max_retries"`         // 命令失败的最大重试次数，-1 表示不重试
+	StartupRetries   int          `yaml:"startup_retries" mapstructure:"startup_retries"` // 启动时连接失败的最大重试次数
+	TLS              RedisTLSConf `yaml:"tls" mapstructure:"tls"`
+}
+
+// RedisTLSConf Redis TLS 配置
+type RedisTLSConf struct {
+	Enabled            bool   `yaml:"enabled" mapstructure:"enabled"`                           // 是否开启
+	ServerName         string `yaml:"server_name" mapstructure:"server_name"`                   // 校验证书使用的服务名
+	CAFile             string `yaml:"ca_file" mapstructure:"ca_file"`                           // CA 证书，为空使用系统证书
+	CertFile           string `yaml:"cert_file" mapstructure:"cert_file"`                       // 客户端证书
+	KeyFile            string `yaml:"key_file" mapstructure:"key_file"`                         // 客户端私钥
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" mapstructure:"insecure_skip_verify"` // 跳过证书校验，仅用于测试环境
 }
 
 type Cache struct {
-	SessionExpired int `yaml:"session_expired" mapstructure:"session_expired"`
-	UserExpired    int `yaml:"user_expired" mapstructure:"user_expired"`
+	SessionExpired  int `yaml:"session_expired" mapstructure:"session_expired"` // 会话空闲超时，有请求时滑动续期，单位秒
+	UserExpired     int `yaml:"user_expired" mapstructure:"user_expired"`
+	NegativeExpired int `yaml:"negative_expired" mapstructure:"negative_expired"` // 用户不存在时负缓存的过期时间，单位秒
+	JitterPercent   int `yaml:"jitter_percent" mapstructure:"jitter_percent"`     // 用户缓存过期时间的随机抖动百分比
+	// DoubleDeleteDelay 用户数据更新后延迟二次删除缓存的间隔，单位毫秒，应大于一次回源读库的耗时
+	DoubleDeleteDelay int            `yaml:"double_delete_delay" mapstructure:"double_delete_delay"`
+	InvalidateRetry   int            `yaml:"invalidate_retry" mapstructure:"invalidate_retry"` // 删除缓存失败的最大重试次数
+	Local             LocalCacheConf `yaml:"local" mapstructure:"local"`
+	Breaker           BreakerConf    `yaml:"breaker" mapstructure:"breaker"`
+	// SessionStore 会话存储模式，redis/db/hybrid，为空按 redis 处理。hybrid 模式下会话持久化到 t_session，
+	// Redis 作为写穿透缓存，Redis 被清空或不可用时仍能从数据库恢复会话
+	SessionStore           string `yaml:"session_store" mapstructure:"session_store"`
+	SessionMaxLifetime     int    `yaml:"session_max_lifetime" mapstructure:"session_max_lifetime"`         // 会话绝对有效期，续期不会超过登录时间加该值，单位秒
+	RememberExpired        int    `yaml:"remember_expired" mapstructure:"remember_expired"`                 // 勾选"记住我"登录的会话有效期，单位秒，为 0 时不支持记住我
+	SessionCleanupInterval int    `yaml:"session_cleanup_interval" mapstructure:"session_cleanup_interval"` // 清理数据库中过期会话的间隔，单位秒
+}
+
+// BreakerConf Redis 熔断配置
+type BreakerConf struct {
+	Threshold int `yaml:"threshold" mapstructure:"threshold"` // 连续失败多少次后熔断
+	OpenTime  int `yaml:"open_time" mapstructure:"open_time"` // 熔断持续时间，单位秒，到期后放行一个探测请求
+}
+
+// LocalCacheConf 进程内一级缓存配置，多实例之间通过 Redis pub/sub 广播失效
+type LocalCacheConf struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"` // 是否开启
+	Size    int    `yaml:"size" mapstructure:"size"`       // 最大条目数
+	TTL     int    `yaml:"ttl" mapstructure:"ttl"`         // 过期时间，单位秒，应远小于 Redis 中的过期时间
+	Channel string `yaml:"channel" mapstructure:"channel"` // 失效消息广播频道
+}
+
+// ExportConf 个人数据导出配置
+type ExportConf struct {
+	Dir         string `yaml:"dir" mapstructure:"dir"`                   // 导出文件存放目录
+	SignKey     string `yaml:"sign_key" mapstructure:"sign_key"`         // 导出包 Ed25519 签名私钥，base64 编码的 32 字节种子，未配置时不签名
+	Interval    int    `yaml:"interval" mapstructure:"interval"`         // 两次申请导出的最小间隔，单位秒
+	LinkExpired int    `yaml:"link_expired" mapstructure:"link_expired"` // 下载链接有效期，单位秒
+}
+
+// RBACConf 权限配置
+type RBACConf struct {
+	AdminUsers []string `yaml:"admin_users" mapstructure:"admin_users"` // 默认租户初始化管理员的用户名，只在还没有任何用户拥有该租户的 admin 角色时授予
+}
+
+// TenantConf 多租户配置
+type TenantConf struct {
+	Header     string `yaml:"header" mapstructure:"header"`           // 携带租户标识的请求头
+	BaseDomain string `yaml:"base_domain" mapstructure:"base_domain"` // 租户子域名的根域名，如 code.example.com 中的 example.com
+}
+
+// RegisterConf 注册配置
+type RegisterConf struct {
+	Mode string `yaml:"mode" mapstructure:"mode"` // 注册模式，open/invite_only/closed，为空按 open 处理
+}
+
+// CorsConf 跨域配置，允许的来源见 GlobalConfig.CorsOrigin
+type CorsConf struct {
+	AllowMethods     []string        `yaml:"allow_methods" mapstructure:"allow_methods"`         // 允许的方法
+	AllowHeaders     []string        `yaml:"allow_headers" mapstructure:"allow_headers"`         // 允许的请求头，租户请求头和 CSRF 请求头会自动加入
+	ExposeHeaders    []string        `yaml:"expose_headers" mapstructure:"expose_headers"`       // 允许页面读取的响应头
+	AllowCredentials bool            `yaml:"allow_credentials" mapstructure:"allow_credentials"` // 允许携带 Cookie
+	MaxAge           int             `yaml:"max_age" mapstructure:"max_age"`                     // 预检结果缓存时间，单位秒
+	Routes           []CorsRouteConf `yaml:"routes" mapstructure:"routes"`                       // 按路径前缀覆盖
+}
+
+// CorsRouteConf 按路径前缀覆盖跨域配置，未配置的字段沿用全局配置。路径不含 /t/:tenant 前缀
+type CorsRouteConf struct {
+	Path             string   `yaml:"path" mapstructure:"path"`                           // 路径前缀，多个前缀匹配时取最长的
+	Disabled         bool     `yaml:"disabled" mapstructure:"disabled"`                   // 不允许跨域访问
+	Origins          []string `yaml:"origins" mapstructure:"origins"`                     // 允许的来源
+	AllowMethods     []string `yaml:"allow_methods" mapstructure:"allow_methods"`         // 允许的方法
+	AllowHeaders     []string `yaml:"allow_headers" mapstructure:"allow_headers"`         // 允许的请求头
+	AllowCredentials *bool    `yaml:"allow_credentials" mapstructure:"allow_credentials"` // 允许携带 Cookie
+	MaxAge           int      `yaml:"max_age" mapstructure:"max_age"`                     // 预检结果缓存时间，单位秒
+}
+
+// RateLimitConf 限流配置，滑动窗口计数
+type RateLimitConf struct {
+	Enabled   bool                 `yaml:"enabled" mapstructure:"enabled"`     // 是否开启
+	Backend   string               `yaml:"backend" mapstructure:"backend"`     // redis/memory，为空按 redis 处理，Redis 不可用时退化为 memory
+	Allowlist []string             `yaml:"allowlist" mapstructure:"allowlist"` // 不限流的内部调用方，IP 或 CIDR，按连接地址匹配
+	PerIP     RateLimitRule        `yaml:"per_ip" mapstructure:"per_ip"`       // 所有公开接口共用的按 IP 限制
+	Routes    []RateLimitRouteConf `yaml:"routes" mapstructure:"routes"`       // 按接口的附加限制
+}
+
+// RateLimitRule 窗口内最多允许 Limit 次请求，Limit 为 0 表示不限制
+type RateLimitRule struct {
+	Limit  int `yaml:"limit" mapstructure:"limit"`   // 最大请求数
+	Window int `yaml:"window" mapstructure:"window"` // 窗口长度，单位秒
+}
+
+// RateLimitRouteConf 单个接口的限流配置，路径不含 /t/:tenant 前缀
+type RateLimitRouteConf struct {
+	Path    string        `yaml:"path" mapstructure:"path"`         // 路由路径，如 /user/login
+	PerIP   RateLimitRule `yaml:"per_ip" mapstructure:"per_ip"`     // 按来源 IP 限制
+	PerUser RateLimitRule `yaml:"per_user" mapstructure:"per_user"` // 按用户名限制，已登录取会话中的用户，否则取请求体中的 user_name
+}
+
+// ChallengeConf 人机校验配置，注册时总是需要，登录失败次数过多后需要
+type ChallengeConf struct {
+	Enabled            bool   `yaml:"enabled" mapstructure:"enabled"`                           // 是否开启
+	Type               string `yaml:"type" mapstructure:"type"`                                 // captcha/pow，为空按 captcha 处理
+	Expired            int    `yaml:"expired" mapstructure:"expired"`                           // 挑战有效期，单位秒
+	LoginFailThreshold int    `yaml:"login_fail_threshold" mapstructure:"login_fail_threshold"` // 同一账号或 IP 登录失败多少次后需要校验
+	LoginFailWindow    int    `yaml:"login_fail_window" mapstructure:"login_fail_window"`       // 登录失败次数的统计窗口，单位秒
+	PowDifficulty      int    `yaml:"pow_difficulty" mapstructure:"pow_difficulty"`             // 工作量证明要求哈希值前导零的比特数
+}
+
+// PasswordConf 密码策略，注册、修改密码和重置密码时校验
+type PasswordConf struct {
+	MinLength        int    `yaml:"min_length" mapstructure:"min_length"`                 // 最小长度，为 0 时为 8
+	MaxLength        int    `yaml:"max_length" mapstructure:"max_length"`                 // 最大长度，为 0 或超过 72 时为 72
+	MinClasses       int    `yaml:"min_classes" mapstructure:"min_classes"`               // 至少包含几类字符：大写字母、小写字母、数字、特殊字符
+	DisallowUserInfo bool   `yaml:"disallow_user_info" mapstructure:"disallow_user_info"` // 密码中不能包含用户名或昵称
+	HistorySize      int    `yaml:"history_size" mapstructure:"history_size"`             // 不能与最近几次使用过的密码相同，为 0 时只校验当前密码
+	BreachedFile     string `yaml:"breached_file" mapstructure:"breached_file"`           // 泄露密码库，每行一个大写 SHA-1，可带 :出现次数，为空时不校验
+}
+
+// LoginHistoryConf 登录记录配置
+type LoginHistoryConf struct {
+	NotifyNewDevice bool   `yaml:"notify_new_device" mapstructure:"notify_new_device"` // 从未出现过的设备登录成功时通知用户
+	Notifier        string `yaml:"notifier" mapstructure:"notifier"`                   // 通知方式，为空按 log 处理
+}
+
+// OutboxConf 领域事件发件箱配置，事件与业务数据在同一事务中写入 t_outbox，由后台任务投递
+type OutboxConf struct {
+	Publisher     string `yaml:"publisher" mapstructure:"publisher"`           // 投递方式，redis/memory，为空按 redis 处理
+	Stream        string `yaml:"stream" mapstructure:"stream"`                 // Redis Stream 名，为空时为 user_events
+	StreamMaxLen  int64  `yaml:"stream_max_len" mapstructure:"stream_max_len"` // Stream 保留的大致条数，为 0 时不裁剪
+	PollInterval  int    `yaml:"poll_interval" mapstructure:"poll_interval"`   // 扫描待投递事件的间隔，单位毫秒
+	BatchSize     int    `yaml:"batch_size" mapstructure:"batch_size"`         // 每次扫描的最大条数
+	SentRetention int    `yaml:"sent_retention" mapstructure:"sent_retention"` // 已投递事件的保留时间，单位秒，为 0 时不清理
+}
+
+// WebhookConf Webhook 回调配置，发件箱中的事件投递成功后按订阅生成投递记录，由后台任务回调
+type WebhookConf struct {
+	Enabled      bool `yaml:"enabled" mapstructure:"enabled"`             // 是否开启 Webhook 回调
+	Timeout      int  `yaml:"timeout" mapstructure:"timeout"`             // 单次回调的超时时间，单位毫秒
+	MaxAttempts  int  `yaml:"max_attempts" mapstructure:"max_attempts"`   // 最多投递次数，用完后进入死信状态
+	PollInterval int  `yaml:"poll_interval" mapstructure:"poll_interval"` // 扫描待投递记录的间隔，单位毫秒
+	BatchSize    int  `yaml:"batch_size" mapstructure:"batch_size"`       // 每次扫描的最大条数
+
+	AllowedNetworks []string `yaml:"allowed_networks" mapstructure:"allowed_networks"` // 允许回调的内网地址，支持 IP 和 CIDR，仅用于本地测试
+}
+
+// GRPCConf gRPC 服务配置，与 HTTP 服务共用 service 层，监听单独的端口
+type GRPCConf struct {
+	Enabled        bool `yaml:"enabled" mapstructure:"enabled"`                 // 是否启动 gRPC 服务
+	Port           int  `yaml:"port" mapstructure:"port"`                       // 监听端口
+	Reflection     bool `yaml:"reflection" mapstructure:"reflection"`           // 是否开启服务反射，便于 grpcurl 等工具调试
+	HealthInterval int  `yaml:"health_interval" mapstructure:"health_interval"` // 刷新健康检查状态的间隔，单位秒
+
+	CertFile string `yaml:"cert_file" mapstructure:"cert_file"` // TLS 证书文件，与 key_file 同时配置时开启 TLS，否则为明文连接
+	KeyFile  string `yaml:"key_file" mapstructure:"key_file"`   // TLS 私钥文件
+}
+
+// IntrospectConf 令牌内省配置，其他服务通过内省接口校验收到的会话，不直接读取 Redis
+type IntrospectConf struct {
+	Enabled   bool                   `yaml:"enabled" mapstructure:"enabled"`       // 是否开启内省接口
+	CacheTTL  int                    `yaml:"cache_ttl" mapstructure:"cache_ttl"`   // 内省结果在本实例缓存的时间，单位秒，为 0 时不缓存
+	CacheSize int                    `yaml:"cache_size" mapstructure:"cache_size"` // 缓存的最大条目数
+	Clients   []IntrospectClientConf `yaml:"clients" mapstructure:"clients"`       // 允许调用内省接口的服务
+}
+
+// IntrospectClientConf 调用内省接口的服务凭证，通过 HTTP Basic 认证传递
+type IntrospectClientConf struct {
+	ClientID string `yaml:"client_id" mapstructure:"client_id"` // 服务标识
+	Secret   string `yaml:"secret" mapstructure:"secret"`       // 服务密钥
+}
+
+// OAuthConf OAuth 2.0 授权服务器配置，客户端在管理后台注册
+type OAuthConf struct {
+	Enabled         bool             `yaml:"enabled" mapstructure:"enabled"`                     // 是否开启
+	CodeTTL         int              `yaml:"code_ttl" mapstructure:"code_ttl"`                   // 授权码有效期，单位秒
+	AccessTokenTTL  int              `yaml:"access_token_ttl" mapstructure:"access_token_ttl"`   // 访问令牌有效期，单位秒
+	RefreshTokenTTL int              `yaml:"refresh_token_ttl" mapstructure:"refresh_token_ttl"` // 刷新令牌有效期，单位秒，每次刷新重新计算
+	LoginPage       string           `yaml:"login_page" mapstructure:"login_page"`               // 未登录时跳转的登录页，登录后通过 redirect 参数回到授权地址
+	ConsentPage     string           `yaml:"consent_page" mapstructure:"consent_page"`           // 授权确认页
+	Scopes          []OAuthScopeConf `yaml:"scopes" mapstructure:"scopes"`                       // 支持的 scope
+}
+
+// OAuthScopeConf 一个 scope 及其在授权确认页上的说明
+type OAuthScopeConf struct {
+	Name        string `yaml:"name" mapstructure:"name"`               // scope 名
+	Description string `yaml:"description" mapstructure:"description"` // 展示给用户的说明
+}
+
+// CookieConf 会话 Cookie 配置
+type CookieConf struct {
+	Name     string `yaml:"name" mapstructure:"name"`           // Cookie 名，为空时为 user_session
+	Domain   string `yaml:"domain" mapstructure:"domain"`       // 作用域名，为空时只对当前域名生效
+	Path     string `yaml:"path" mapstructure:"path"`           // 作用路径，为空时为 /
+	Secure   bool   `yaml:"secure" mapstructure:"secure"`       // 只通过 HTTPS 发送
+	SameSite string `yaml:"same_site" mapstructure:"same_site"` // lax/strict/none，为空时为 lax，none 需要同时开启 secure
+}
+
+// CSRFConf CSRF 防护配置，使用签名的双重提交 Cookie
+type CSRFConf struct {
+	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`         // 是否开启
+	CookieName string `yaml:"cookie_name" mapstructure:"cookie_name"` // 存放令牌的 Cookie 名，为空时为 csrf_token
+	HeaderName string `yaml:"header_name" mapstructure:"header_name"` // 提交令牌的请求头，为空时为 X-CSRF-Token
+	Secret     string `yaml:"secret" mapstructure:"secret"`           // 令牌签名密钥，多实例部署时必须相同
 }
 
 type Appconf struct {
@@ -49,15 +275,33 @@ type Appconf struct {
 	Version string `yaml:"version" mapstructure:"version"`   // 版本
 	Port    int    `yaml:"port" mapstructure:"port"`         // 端口
 	RunMode string `yaml:"run_mode" mapstructure:"run_mode"` // 运行模式
+	// TrustedProxies 可信的反向代理，IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才会被采信，为空时不采信
+	TrustedProxies []string `yaml:"trusted_proxies" mapstructure:"trusted_proxies"`
 }
 
 type GlobalConfig struct {
-	CorsOrigin  []string  `yaml:"cors_origin" mapstructure:"cors_origin"`
-	LogConfig   LogConf   `yaml:"log" mapstructure:"log"`
-	AppConfig   Appconf   `yaml:"app" mapstructure:"app"`
-	DbConfig    DbConf    `yaml:"db" mapstructure:"db"`
-	RedisConfig RedisConf `yaml:"redis" mapstructure:"redis"`
-	Cache       Cache     `yaml:"cache" mapstructure:"cache"`
+	CorsOrigin   []string         `yaml:"cors_origin" mapstructure:"cors_origin"`
+	Cors         CorsConf         `yaml:"cors" mapstructure:"cors"`
+	LogConfig    LogConf          `yaml:"log" mapstructure:"log"`
+	AppConfig    Appconf          `yaml:"app" mapstructure:"app"`
+	DbConfig     DbConf           `yaml:"db" mapstructure:"db"`
+	RedisConfig  RedisConf        `yaml:"redis" mapstructure:"redis"`
+	Cache        Cache            `yaml:"cache" mapstructure:"cache"`
+	Export       ExportConf       `yaml:"export" mapstructure:"export"`
+	RBAC         RBACConf         `yaml:"rbac" mapstructure:"rbac"`
+	Tenant       TenantConf       `yaml:"tenant" mapstructure:"tenant"`
+	Register     RegisterConf     `yaml:"register" mapstructure:"register"`
+	Cookie       CookieConf       `yaml:"cookie" mapstructure:"cookie"`
+	CSRF         CSRFConf         `yaml:"csrf" mapstructure:"csrf"`
+	RateLimit    RateLimitConf    `yaml:"rate_limit" mapstructure:"rate_limit"`
+	Challenge    ChallengeConf    `yaml:"challenge" mapstructure:"challenge"`
+	Password     PasswordConf     `yaml:"password" mapstructure:"password"`
+	LoginHistory LoginHistoryConf `yaml:"login_history" mapstructure:"login_history"`
+	Outbox       OutboxConf       `yaml:"outbox" mapstructure:"outbox"`
+	Webhook      WebhookConf      `yaml:"webhook" mapstructure:"webhook"`
+	GRPC         GRPCConf         `yaml:"grpc" mapstructure:"grpc"`
+	Introspect   IntrospectConf   `yaml:"introspect" mapstructure:"introspect"`
+	OAuth        OAuthConf        `yaml:"oauth" mapstructure:"oauth"`
 }
 
 func GetGlobalConfig() *GlobalConfig {
@@ -93,8 +337,27 @@ func readConf() {
 	log.Infof("config=== %+v1", config)
 }
 
+// checkSecrets 检查密钥是否还是示例配置中的占位值，占位值人人可见，用于签名等同于没有密钥
+func checkSecrets(c *GlobalConfig) error {
+	secrets := make(map[string]string, len(c.Introspect.Clients))
+	for _, client := range c.Introspect.Clients {
+		secrets["introspect.clients["+client.ClientID+"].secret"] = client.Secret
+	}
+	secrets["export.sign_key"] = c.Export.SignKey
+	secrets["csrf.secret"] = c.CSRF.Secret
+	for name, secret := range secrets {
+		if strings.HasPrefix(secret, placeholderSecretPrefix) {
+			return fmt.Errorf("%s is a placeholder, replace it with a random secret", name)
+		}
+	}
+	return nil
+}
+
 func InitConfig() {
 	globalConf := GetGlobalConfig()
+	if err := checkSecrets(globalConf); err != nil {
+		panic("config secret err:" + err.Error())
+	}
 	level, err := log.ParseLevel(globalConf.LogConfig.Level)
 	if err != nil {
 		panic("log level parse err:" + err.Error())
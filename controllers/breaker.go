@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"my_user_system/conf"
+	"my_user_system/utils"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开，Redis 调用被直接拒绝
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+const (
+	defaultBreakerThreshold = 5  // 未配置时触发熔断的连续失败次数
+	defaultBreakerOpenTime  = 10 // 未配置时熔断持续时间，单位秒
+)
+
+// 熔断器状态
+const (
+	breakerClosed   = "closed"    // 正常
+	breakerOpen     = "open"      // 熔断中，直接拒绝
+	breakerHalfOpen = "half_open" // 熔断到期，放行一个探测请求
+)
+
+// breaker 连续失败计数的熔断器
+type breaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	threshold int
+	openTime  time.Duration
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有探测请求在执行
+}
+
+func newBreaker(threshold int, openTime time.Duration) *breaker {
+	return &breaker{state: breakerClosed, threshold: threshold, openTime: openTime}
+}
+
+// allow 判断是否放行本次调用
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTime {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record 记录调用结果
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isOutage(err) {
+		if b.state != breakerClosed {
+			log.Infof("redis circuit breaker closed")
+		}
+		b.state, b.failures, b.probing = breakerClosed, 0, false
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			log.Errorf("redis circuit breaker open after %d failures, last err:%v", b.failures, err)
+		}
+		b.state, b.openedAt, b.probing = breakerOpen, time.Now(), false
+	}
+}
+
+// State 当前状态
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// isOutage 判断错误是否意味着 Redis 不可用，键不存在和服务端返回的命令错误不计入
+func isOutage(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, redis.ErrClosed)
+}
+
+// breakerHook 把熔断器挂到 Redis 客户端上，所有命令和 pipeline 都经过熔断判断
+type breakerHook struct {
+	b *breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.b.allow() {
+			cmd.SetErr(ErrCircuitOpen)
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmd)
+		h.b.record(err)
+		return err
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.b.allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmds)
+		h.b.record(err)
+		return err
+	}
+}
+
+// redisBreaker Redis 熔断器，InitResilience 之前为 nil
+var redisBreaker *breaker
+
+// InitResilience 给 Redis 客户端加上熔断器，Redis 不可用时快速失败并由调用方降级
+func InitResilience() {
+	breakerConf := conf.GetGlobalConfig().Cache.Breaker
+	threshold, openTime := breakerConf.Threshold, breakerConf.OpenTime
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if openTime <= 0 {
+		openTime = defaultBreakerOpenTime
+	}
+	redisBreaker = newBreaker(threshold, time.Duration(openTime)*time.Second)
+	utils.GetRedisCli().AddHook(breakerHook{b: redisBreaker})
+	go pendingDeletes.flushLoop()
+}
+
+// Degraded Redis 是否处于熔断降级状态
+func Degraded() bool {
+	return redisBreaker != nil && redisBreaker.State() != breakerClosed
+}
+
+// BreakerState 熔断器状态，用于健康检查
+func BreakerState() string {
+	if redisBreaker == nil {
+		return breakerClosed
+	}
+	return redisBreaker.State()
+}
+
+// IsMiss 判断是否是缓存中不存在，用于和 Redis 不可用区分开
+func IsMiss(err error) bool {
+	return err == redis.Nil || err == errCacheMiss
+}
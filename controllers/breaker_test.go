@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(3, 20*time.Millisecond)
+	outage := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	// 键不存在和命令错误不计入失败
+	b.record(redis.Nil)
+	b.record(redis.TxFailedErr)
+	for i := 0; i < 2; i++ {
+		b.record(outage)
+	}
+	if !b.allow() || b.State() != breakerClosed {
+		t.Fatalf("expected closed before threshold, got %s", b.State())
+	}
+	b.record(outage)
+	if b.allow() || b.State() != breakerOpen {
+		t.Fatalf("expected open after threshold, got %s", b.State())
+	}
+
+	// 熔断到期后只放行一个探测请求，探测失败重新熔断
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	if b.allow() {
+		t.Fatal("expected only one probe in half open")
+	}
+	b.record(outage)
+	if b.State() != breakerOpen {
+		t.Fatalf("expected open after failed probe, got %s", b.State())
+	}
+
+	// 探测成功恢复
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	b.record(nil)
+	if !b.allow() || b.State() != breakerClosed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
@@ -4,80 +4,82 @@ import (
 	"context"
 	"encoding/json"
 	"my_user_system/conf"
-	"my_user_system/model"
 	"my_user_system/static"
 	"my_user_system/utils"
 	"time"
 )
 
-// GetUserInfoFromCache 函数用于从Redis缓存中获取用户信息。
-func GetUserInfoFromCache(username string) (*model.User, error) {
-	// 构建用户在Redis中的键名
-	redisKey := static.UserInfoPrefix + username
-
-	// 使用Redis客户端从缓存中获取用户信息
+// GetPermissionCache 获取缓存的用户有效权限
+func GetPermissionCache(tenantID int, username string) ([]string, error) {
+	redisKey := tenantKey(tenantID, static.PermissionPrefix+username)
 	val, err := utils.GetRedisCli().Get(context.Background(), redisKey).Result()
 	if err != nil {
-		return nil, err // 如果获取过程中出现错误，返回nil和错误信息
+		return nil, err
 	}
-
-	// 将JSON格式的用户信息解码为User对象
-	user := &model.User{}
-	err = json.Unmarshal([]byte(val), user)
-	return user, err // 返回解码后的User对象和可能出现的错误
+	var perms []string
+	err = json.Unmarshal([]byte(val), &perms)
+	return perms, err
 }
 
-// SetUserCacheInfo 函数用于将用户信息存储到Redis缓存中。
-func SetUserCacheInfo(user *model.User) error {
-	// 构建用户在Redis中的键名
-	redisKey := static.UserInfoPrefix + user.Name
-
-	// 将用户对象转换为JSON格式
-	val, err := json.Marshal(user)
+// SetPermissionCache 缓存用户有效权限，过期时间与会话一致
+func SetPermissionCache(tenantID int, username string, perms []string) error {
+	redisKey := tenantKey(tenantID, static.PermissionPrefix+username)
+	val, err := json.Marshal(perms)
 	if err != nil {
-		return err // JSON编码失败时返回错误
+		return err
 	}
+	expired := time.Second * time.Duration(conf.GetGlobalConfig().Cache.SessionExpired)
+	return utils.GetRedisCli().Set(context.Background(), redisKey, val, expired).Err()
+}
 
-	// 获取用户缓存的过期时间
-	expired := time.Second * time.Duration(conf.GetGlobalConfig().Cache.UserExpired)
+// DelPermissionCache 删除用户权限缓存，角色或权限变更后调用
+func DelPermissionCache(tenantID int, usernames ...string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(usernames))
+	for _, name := range usernames {
+		keys = append(keys, tenantKey(tenantID, static.PermissionPrefix+name))
+	}
+	_, err := delKeys(context.Background(), utils.GetRedisCli(), keys...)
+	return err
+}
 
-	// 使用Redis客户端将用户信息存储到缓存中，并设置过期时间
-	_, err = utils.GetRedisCli().Set(context.Background(), redisKey, val, expired*time.Second).Result()
-	return err // 返回可能出现的错误
+// ImpersonationInfo 模拟登录会话的附加信息
+type ImpersonationInfo struct {
+	Actor        string    `json:"actor"`         // 发起模拟的管理员
+	ActorSession string    `json:"actor_session"` // 管理员自己的会话，结束模拟后恢复
+	Target       string    `json:"target"`        // 被模拟的用户
+	StartTime    time.Time `json:"start_time"`    // 开始时间
 }
-func GetSessionInfo(session string) (*model.User, error) {
-	redisKey := static.SessionKeyPrefix + session
-	val, err := utils.GetRedisCli().Get(context.Background(), redisKey).Result()
+
+// GetImpersonation 获取会话的模拟登录信息，普通会话或会话不存在时返回 IsMiss 为 true 的错误。
+// 模拟登录信息保存在会话记录中，升级前创建的模拟登录会话仍从单独的 Redis 键读取
+func GetImpersonation(tenantID int, session string) (*ImpersonationInfo, error) {
+	data, err := getSessionData(tenantID, session)
 	if err != nil {
 		return nil, err
 	}
-	user := &model.User{}
-	err = json.Unmarshal([]byte(val), &user)
-	return user, err
-}
-
-func SetSessionInfo(user *model.User, session string) error {
-	redisKey := static.SessionKeyPrefix + session
-	val, err := json.Marshal(&user)
-	if err != nil {
-		return err
+	if data.Impersonation != nil {
+		return data.Impersonation, nil
 	}
-	expired := time.Second * time.Duration(conf.GetGlobalConfig().Cache.SessionExpired)
-	_, err = utils.GetRedisCli().Set(context.Background(), redisKey, val, expired*time.Second).Result()
-	return err
-
+	return getLegacyImpersonation(tenantID, session)
 }
-func UpdateCachedUserInfo(user *model.User) error {
-	err := SetUserCacheInfo(user)
+
+// getLegacyImpersonation 读取升级前单独保存在 Redis 中的模拟登录标记，这些键过期后可以删除这段兼容逻辑
+func getLegacyImpersonation(tenantID int, session string) (*ImpersonationInfo, error) {
+	redisKey := tenantKey(tenantID, static.ImpersonatePrefix+session)
+	val, err := utils.GetRedisCli().Get(context.Background(), redisKey).Result()
 	if err != nil {
-		redisKey := static.UserInfoPrefix + user.Name
-		utils.GetRedisCli().Del(context.Background(), redisKey).Result()
+		return nil, err
 	}
-	return err
+	info := &ImpersonationInfo{}
+	err = json.Unmarshal([]byte(val), info)
+	return info, err
 }
 
-func DelSessionInfo(session string) error {
-	redisKey := static.SessionKeyPrefix + session
-	_, err := utils.GetRedisCli().Del(context.Background(), redisKey).Result()
-	return err
+// DelImpersonation 删除升级前单独保存的模拟登录标记，新的模拟登录信息随会话一起删除
+func DelImpersonation(tenantID int, session string) error {
+	redisKey := tenantKey(tenantID, static.ImpersonatePrefix+session)
+	return utils.GetRedisCli().Del(context.Background(), redisKey).Err()
 }
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+// SetChallenge 保存挑战的答案
+func SetChallenge(id, val string, expired time.Duration) error {
+	return utils.GetRedisCli().Set(context.Background(), static.ChallengePrefix+id, val, expired).Err()
+}
+
+// TakeChallenge 取出并删除挑战的答案，同一挑战只能校验一次，不存在时返回 redis.Nil
+func TakeChallenge(id string) (string, error) {
+	redisKey := static.ChallengePrefix + id
+	pipe := utils.GetRedisCli().TxPipeline()
+	get := pipe.Get(context.Background(), redisKey)
+	pipe.Del(context.Background(), redisKey)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
+
+// loginFailKey 登录失败计数的缓存键，subject 为用户名或 IP
+func loginFailKey(tenantID int, subject string) string {
+	return tenantKey(tenantID, static.LoginFailPrefix+subject)
+}
+
+// IncrLoginFail 登录失败次数加一，计数从第一次失败开始在 window 后过期
+func IncrLoginFail(tenantID int, subject string, window time.Duration) error {
+	redisKey := loginFailKey(tenantID, subject)
+	pipe := utils.GetRedisCli().TxPipeline()
+	pipe.Incr(context.Background(), redisKey)
+	ttl := pipe.TTL(context.Background(), redisKey)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return err
+	}
+	// 只在没有过期时间时设置，后续失败不延长窗口
+	if ttl.Val() < 0 {
+		return utils.GetRedisCli().Expire(context.Background(), redisKey, window).Err()
+	}
+	return nil
+}
+
+// GetLoginFail 获取登录失败次数
+func GetLoginFail(tenantID int, subject string) (int, error) {
+	n, err := utils.GetRedisCli().Get(context.Background(), loginFailKey(tenantID, subject)).Int()
+	if IsMiss(err) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// ResetLoginFail 登录成功后清零失败次数
+func ResetLoginFail(tenantID int, subject string) error {
+	return utils.GetRedisCli().Del(context.Background(), loginFailKey(tenantID, subject)).Err()
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/utils"
+	"sync"
+	"time"
+)
+
+// tombstoneFlushInterval 补删 Redis 中残留会话的间隔
+const tombstoneFlushInterval = 2 * time.Second
+
+// tombstones Redis 不可用期间删除失败的会话键。补删成功前本实例视其为已删除，
+// 避免 Redis 恢复后登出的会话重新生效
+type tombstones struct {
+	mu   sync.Mutex
+	keys map[string]time.Time // 键 -> 会话原本的过期时间，过期后无需补删
+}
+
+var pendingDeletes = &tombstones{keys: make(map[string]time.Time)}
+
+func (t *tombstones) add(keys ...string) {
+	expireAt := time.Now().Add(sessionLongestLifetime())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		t.keys[key] = expireAt
+	}
+}
+
+// remove 会话被重新写入时不再需要补删
+func (t *tombstones) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.keys, key)
+}
+
+func (t *tombstones) has(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.keys[key]
+	return ok
+}
+
+// flush 补删残留的键，成功或已过期的移出列表
+func (t *tombstones) flush() {
+	t.mu.Lock()
+	keys := make([]string, 0, len(t.keys))
+	for key, expireAt := range t.keys {
+		if time.Now().After(expireAt) {
+			delete(t.keys, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	t.mu.Unlock()
+	if len(keys) == 0 || Degraded() {
+		return
+	}
+	if _, err := delKeys(context.Background(), utils.GetRedisCli(), keys...); err != nil {
+		log.Warnf("tombstones|flush %d keys failed, err:%v", len(keys), err)
+		return
+	}
+	t.mu.Lock()
+	for _, key := range keys {
+		delete(t.keys, key)
+	}
+	t.mu.Unlock()
+	log.Infof("tombstones|flush %d keys success", len(keys))
+}
+
+func (t *tombstones) flushLoop() {
+	ticker := time.NewTicker(tombstoneFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.flush()
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"time"
+)
+
+// defaultIntrospectCacheSize 未配置时内省结果缓存的最大条目数
+const defaultIntrospectCacheSize = 10000
+
+// introspectCache 内省结果的进程内缓存，键与会话在 Redis 中的键相同，未开启时为 nil
+var introspectCache *lruCache
+
+// InitIntrospectCache 按配置开启内省结果缓存
+func InitIntrospectCache() {
+	introspectConf := conf.GetGlobalConfig().Introspect
+	if !introspectConf.Enabled || introspectConf.CacheTTL <= 0 {
+		return
+	}
+	size := introspectConf.CacheSize
+	if size <= 0 {
+		size = defaultIntrospectCacheSize
+	}
+	introspectCache = newLRUCache(size, time.Duration(introspectConf.CacheTTL)*time.Second)
+	log.Infof("InitIntrospectCache success, size=%d|ttl=%ds", size, introspectConf.CacheTTL)
+}
+
+// GetIntrospection 获取缓存的内省结果
+func GetIntrospection(tenantID int, session string) (string, bool) {
+	if introspectCache == nil {
+		return "", false
+	}
+	return introspectCache.Get(sessionKey(tenantID, session))
+}
+
+// SetIntrospection 缓存内省结果
+func SetIntrospection(tenantID int, session, val string) {
+	if introspectCache == nil {
+		return
+	}
+	introspectCache.Set(sessionKey(tenantID, session), val)
+}
+
+// evictIntrospection 会话被删除时清除本实例缓存的内省结果，其他实例收到本地缓存失效广播时清除，
+// 未开启本地缓存时依赖内省缓存的短过期时间兜底
+func evictIntrospection(keys ...string) {
+	if introspectCache == nil {
+		return
+	}
+	introspectCache.Del(keys...)
+}
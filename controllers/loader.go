@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"math/rand"
+	"my_user_system/conf"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUserNotFound 用户不存在，可能来自数据库也可能来自负缓存
+	ErrUserNotFound = errors.New("user not found")
+	// errCacheMiss 缓存未命中
+	errCacheMiss = errors.New("cache miss")
+)
+
+const (
+	// negativeValue 负缓存的占位值，表示用户不存在
+	negativeValue = "-"
+	// defaultNegativeExpired 未配置时负缓存的过期时间，单位秒
+	defaultNegativeExpired = 30
+	// defaultDoubleDeleteDelay 未配置时延迟二次删除的间隔，单位毫秒
+	defaultDoubleDeleteDelay = 500
+	// defaultInvalidateRetry 未配置时失效失败的最大重试次数
+	defaultInvalidateRetry = 5
+	// retryQueueSize 失效重试队列长度，队列满时丢弃并依赖缓存过期时间兜底
+	retryQueueSize = 1024
+	// retryBackoff 首次重试的等待时间，之后每次翻倍
+	retryBackoff = 100 * time.Millisecond
+)
+
+// kvStore 用户缓存依赖的键值存储，线上为 Redis，测试中可替换为内存实现
+type kvStore interface {
+	Get(key string) (string, error) // 未命中时返回 errCacheMiss
+	Set(key, val string, expired time.Duration) error
+	Del(keys ...string) error
+}
+
+// redisStore 基于 Redis 的存储，读写时同步维护进程内一级缓存
+type redisStore struct{}
+
+func (redisStore) Get(key string) (string, error) {
+	val, err := getThroughLocal(key)
+	if err == redis.Nil {
+		return "", errCacheMiss
+	}
+	return val, err
+}
+
+func (redisStore) Set(key, val string, expired time.Duration) error {
+	err := utils.GetRedisCli().Set(context.Background(), key, val, expired).Err()
+	invalidateLocal(key)
+	return err
+}
+
+func (redisStore) Del(keys ...string) error {
+	_, err := delKeys(context.Background(), utils.GetRedisCli(), keys...)
+	invalidateLocal(keys...)
+	return err
+}
+
+// UserLoaderFunc 缓存未命中时的回源函数，用户不存在时返回 nil, nil
+type UserLoaderFunc func() (*model.User, error)
+
+// retryTask 待重试的缓存删除
+type retryTask struct {
+	key     string
+	attempt int
+}
+
+// userCache 读穿透的用户信息缓存。
+// 一致性策略：数据更新后先删除缓存，再延迟一段时间二次删除，清掉并发读请求在更新前读到旧数据、
+// 更新后才回填的脏缓存；删除失败的键进入重试队列，重试耗尽后依赖缓存过期时间兜底
+type userCache struct {
+	store       kvStore
+	group       singleflight.Group // 合并同一个 key 上并发的回源请求
+	delay       time.Duration      // 延迟二次删除的间隔
+	maxRetry    int                // 删除失败的最大重试次数
+	backoff     time.Duration      // 首次重试的等待时间
+	retries     chan *retryTask
+	userTTL     func() time.Duration
+	negativeTTL func() time.Duration
+}
+
+func newUserCache(store kvStore, delay time.Duration, maxRetry int) *userCache {
+	c := &userCache{
+		store:       store,
+		delay:       delay,
+		maxRetry:    maxRetry,
+		backoff:     retryBackoff,
+		retries:     make(chan *retryTask, retryQueueSize),
+		userTTL:     userExpired,
+		negativeTTL: negativeExpired,
+	}
+	go c.retryLoop()
+	return c
+}
+
+var (
+	users     *userCache
+	usersOnce sync.Once
+)
+
+// getUserCache 获取基于 Redis 的默认用户缓存
+func getUserCache() *userCache {
+	usersOnce.Do(func() {
+		cacheConf := conf.GetGlobalConfig().Cache
+		delay, maxRetry := cacheConf.DoubleDeleteDelay, cacheConf.InvalidateRetry
+		if delay <= 0 {
+			delay = defaultDoubleDeleteDelay
+		}
+		if maxRetry <= 0 {
+			maxRetry = defaultInvalidateRetry
+		}
+		users = newUserCache(redisStore{}, time.Duration(delay)*time.Millisecond, maxRetry)
+	})
+	return users
+}
+
+// withJitter 在过期时间上增加随机抖动，避免同一批缓存同时过期
+func withJitter(expired time.Duration) time.Duration {
+	percent := conf.GetGlobalConfig().Cache.JitterPercent
+	if percent <= 0 || expired <= 0 {
+		return expired
+	}
+	max := int64(expired) * int64(percent) / 100
+	if max <= 0 {
+		return expired
+	}
+	return expired + time.Duration(rand.Int63n(max))
+}
+
+// userExpired 用户缓存的过期时间，已包含抖动
+func userExpired() time.Duration {
+	return withJitter(time.Duration(conf.GetGlobalConfig().Cache.UserExpired) * time.Second)
+}
+
+// negativeExpired 负缓存的过期时间，已包含抖动
+func negativeExpired() time.Duration {
+	expired := conf.GetGlobalConfig().Cache.NegativeExpired
+	if expired <= 0 {
+		expired = defaultNegativeExpired
+	}
+	return withJitter(time.Duration(expired) * time.Second)
+}
+
+// userKey 用户信息的缓存键
+func userKey(tenantID int, username string) string {
+	return tenantKey(tenantID, static.UserInfoPrefix+username)
+}
+
+// get 读取缓存的用户信息，命中负缓存时返回 ErrUserNotFound
+func (c *userCache) get(tenantID int, username string) (*model.User, error) {
+	val, err := c.store.Get(userKey(tenantID, username))
+	if err != nil {
+		return nil, err
+	}
+	if val == negativeValue {
+		return nil, ErrUserNotFound
+	}
+	user := &model.User{}
+	err = json.Unmarshal([]byte(val), user)
+	return user, err
+}
+
+// set 写入用户信息缓存
+func (c *userCache) set(user *model.User) error {
+	val, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(userKey(user.TenantID, user.Name), string(val), c.userTTL())
+}
+
+// load 先读缓存，未命中时同一个 key 只有一个请求回源，其余请求等待共享结果；
+// 回源发现用户不存在时写入短期负缓存，返回 ErrUserNotFound
+func (c *userCache) load(tenantID int, username string, loader UserLoaderFunc) (*model.User, error) {
+	user, err := c.get(tenantID, username)
+	if err == nil || err == ErrUserNotFound {
+		return user, err
+	}
+
+	key := fmt.Sprintf("%d:%s", tenantID, username)
+	val, err, _ := c.group.Do(key, func() (interface{}, error) {
+		user, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			if err := c.store.Set(userKey(tenantID, username), negativeValue, c.negativeTTL()); err != nil {
+				log.Errorf("LoadUserInfo|set negative cache failed for user:%s with err:%v", username, err)
+			}
+			return nil, ErrUserNotFound
+		}
+		if err := c.set(user); err != nil {
+			log.Errorf("LoadUserInfo|cache userinfo failed for user:%s with err:%v", username, err)
+		}
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 共享的结果复制一份再返回，避免调用方之间互相修改
+	shared := *val.(*model.User)
+	return &shared, nil
+}
+
+// invalidate 删除用户缓存并安排延迟二次删除，删除失败时进入重试队列
+func (c *userCache) invalidate(tenantID int, username string) {
+	key := userKey(tenantID, username)
+	c.del(&retryTask{key: key})
+	time.AfterFunc(c.delay, func() {
+		c.del(&retryTask{key: key})
+	})
+}
+
+// del 删除缓存，失败时放入重试队列
+func (c *userCache) del(task *retryTask) {
+	err := c.store.Del(task.key)
+	if err == nil {
+		return
+	}
+	task.attempt++
+	if task.attempt > c.maxRetry {
+		log.Errorf("invalidate|give up deleting %s after %d attempts, err:%v", task.key, task.attempt, err)
+		return
+	}
+	select {
+	case c.retries <- task:
+		log.Warnf("invalidate|delete %s failed, queued for retry, attempt=%d|err=%v", task.key, task.attempt, err)
+	default:
+		log.Errorf("invalidate|retry queue full, drop %s, err:%v", task.key, err)
+	}
+}
+
+// retryLoop 按指数退避重试失败的删除
+func (c *userCache) retryLoop() {
+	for task := range c.retries {
+		task := task
+		wait := c.backoff << uint(task.attempt-1)
+		time.AfterFunc(wait, func() {
+			c.del(task)
+		})
+	}
+}
+
+// GetUserInfoFromCache 函数用于从缓存中获取用户信息，命中负缓存时返回 ErrUserNotFound
+func GetUserInfoFromCache(tenantID int, username string) (*model.User, error) {
+	return getUserCache().get(tenantID, username)
+}
+
+// SetUserCacheInfo 函数用于将用户信息存储到缓存中，过期时间带随机抖动
+func SetUserCacheInfo(user *model.User) error {
+	return getUserCache().set(user)
+}
+
+// LoadUserInfo 读穿透方式获取用户信息，用户不存在时返回 ErrUserNotFound
+func LoadUserInfo(tenantID int, username string, loader UserLoaderFunc) (*model.User, error) {
+	return getUserCache().load(tenantID, username, loader)
+}
+
+// InvalidateUserInfo 用户数据变更后调用：立即删除缓存并延迟二次删除，失败的删除会自动重试
+func InvalidateUserInfo(tenantID int, username string) {
+	getUserCache().invalidate(tenantID, username)
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"fmt"
+	"my_user_system/model"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memStore 内存版 kvStore，可注入删除失败
+type memStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	delFails int // 接下来的多少次删除返回失败
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string)}
+}
+
+func (s *memStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.data[key]
+	if !ok {
+		return "", errCacheMiss
+	}
+	return val, nil
+}
+
+func (s *memStore) Set(key, val string, expired time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = val
+	return nil
+}
+
+func (s *memStore) Del(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.delFails > 0 {
+		s.delFails--
+		return fmt.Errorf("injected del failure")
+	}
+	for _, key := range keys {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+// memDB 内存版用户表，记录回源次数
+type memDB struct {
+	mu    sync.Mutex
+	users map[string]model.User
+	loads int32
+}
+
+func (db *memDB) get(name string) (*model.User, error) {
+	atomic.AddInt32(&db.loads, 1)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user, ok := db.users[name]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (db *memDB) setNickName(name, nick string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user := db.users[name]
+	user.NickName = nick
+	db.users[name] = user
+}
+
+func newTestUserCache(store kvStore, delay time.Duration) *userCache {
+	c := newUserCache(store, delay, 3)
+	c.backoff = 5 * time.Millisecond
+	c.userTTL = func() time.Duration { return time.Minute }
+	c.negativeTTL = func() time.Duration { return time.Minute }
+	return c
+}
+
+func newTestDB() *memDB {
+	return &memDB{users: map[string]model.User{
+		"alice": {TenantID: 1, Name: "alice", NickName: "v0"},
+	}}
+}
+
+// waitFor 等待条件成立，超时则失败
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("condition not met within %v", timeout)
+}
+
+func cachedNickName(c *userCache) string {
+	user, err := c.get(1, "alice")
+	if err != nil {
+		return ""
+	}
+	return user.NickName
+}
+
+func TestLoadSingleflight(t *testing.T) {
+	db := newTestDB()
+	c := newTestUserCache(newMemStore(), 10*time.Millisecond)
+	release := make(chan struct{})
+	loader := func() (*model.User, error) {
+		<-release
+		return db.get("alice")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := c.load(1, "alice", loader)
+			if err != nil || user.NickName != "v0" {
+				t.Errorf("load got %+v, err=%v", user, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads := atomic.LoadInt32(&db.loads); loads != 1 {
+		t.Errorf("expected 1 db load, got %d", loads)
+	}
+}
+
+func TestLoadNegativeCache(t *testing.T) {
+	db := newTestDB()
+	c := newTestUserCache(newMemStore(), 10*time.Millisecond)
+	loader := func() (*model.User, error) { return db.get("bob") }
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.load(1, "bob", loader); err != ErrUserNotFound {
+			t.Fatalf("expected ErrUserNotFound, got %v", err)
+		}
+	}
+	if loads := atomic.LoadInt32(&db.loads); loads != 1 {
+		t.Errorf("expected 1 db load, got %d", loads)
+	}
+
+	// 注册后失效负缓存，下一次读能看到新用户
+	db.mu.Lock()
+	db.users["bob"] = model.User{TenantID: 1, Name: "bob"}
+	db.mu.Unlock()
+	c.invalidate(1, "bob")
+	if user, err := c.load(1, "bob", loader); err != nil || user.Name != "bob" {
+		t.Fatalf("expected bob after invalidate, got %+v, err=%v", user, err)
+	}
+}
+
+// TestStaleReadRepairedByDoubleDelete 读请求在更新前读到旧数据、更新并删除缓存后才回填，
+// 延迟二次删除应清掉这份脏缓存
+func TestStaleReadRepairedByDoubleDelete(t *testing.T) {
+	db := newTestDB()
+	c := newTestUserCache(newMemStore(), 30*time.Millisecond)
+
+	readDone := make(chan struct{})
+	loaded := make(chan struct{})
+	resume := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		c.load(1, "alice", func() (*model.User, error) {
+			user, err := db.get("alice") // 读到 v0
+			close(loaded)
+			<-resume // 在更新完成后才回填缓存
+			return user, err
+		})
+	}()
+
+	<-loaded
+	db.setNickName("alice", "v1")
+	c.invalidate(1, "alice")
+	close(resume)
+	<-readDone
+
+	if got := cachedNickName(c); got != "v0" {
+		t.Fatalf("expected stale v0 before second delete, got %q", got)
+	}
+	waitFor(t, time.Second, func() bool {
+		_, err := c.get(1, "alice")
+		return err == errCacheMiss
+	})
+	user, err := c.load(1, "alice", func() (*model.User, error) { return db.get("alice") })
+	if err != nil || user.NickName != "v1" {
+		t.Fatalf("expected v1 after double delete, got %+v, err=%v", user, err)
+	}
+}
+
+func TestInvalidateRetry(t *testing.T) {
+	db := newTestDB()
+	store := newMemStore()
+	c := newTestUserCache(store, time.Hour)
+	loader := func() (*model.User, error) { return db.get("alice") }
+
+	if _, err := c.load(1, "alice", loader); err != nil {
+		t.Fatal(err)
+	}
+	db.setNickName("alice", "v1")
+	store.mu.Lock()
+	store.delFails = 2
+	store.mu.Unlock()
+	c.invalidate(1, "alice")
+
+	waitFor(t, time.Second, func() bool { return cachedNickName(c) == "" })
+	user, err := c.load(1, "alice", loader)
+	if err != nil || user.NickName != "v1" {
+		t.Fatalf("expected v1 after retried delete, got %+v, err=%v", user, err)
+	}
+}
+
+func TestInvalidateGiveUp(t *testing.T) {
+	store := newMemStore()
+	c := newTestUserCache(store, time.Hour)
+	store.Set(userKey(1, "alice"), `{"Name":"alice"}`, time.Minute)
+	store.mu.Lock()
+	store.delFails = 100
+	store.mu.Unlock()
+	c.invalidate(1, "alice")
+
+	// 首次删除加 3 次重试后放弃
+	waitFor(t, time.Second, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.delFails == 96
+	})
+	time.Sleep(100 * time.Millisecond)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.delFails != 96 {
+		t.Errorf("expected retries to stop after 4 attempts, remaining fails=%d", store.delFails)
+	}
+}
+
+// TestConcurrentReadUpdate 并发读写交错后，缓存最终与数据库一致
+func TestConcurrentReadUpdate(t *testing.T) {
+	db := newTestDB()
+	c := newTestUserCache(newMemStore(), 20*time.Millisecond)
+	loader := func() (*model.User, error) {
+		user, err := db.get("alice")
+		time.Sleep(time.Millisecond) // 放大读库与回填之间的窗口
+		return user, err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				c.load(1, "alice", loader)
+			}
+		}()
+	}
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(v int) {
+			defer wg.Done()
+			db.setNickName("alice", fmt.Sprintf("v%d", v))
+			c.invalidate(1, "alice")
+		}(i)
+		time.Sleep(2 * time.Millisecond)
+	}
+	wg.Wait()
+
+	db.mu.Lock()
+	want := db.users["alice"].NickName
+	db.mu.Unlock()
+	waitFor(t, time.Second, func() bool {
+		user, err := c.load(1, "alice", loader)
+		return err == nil && user.NickName == want
+	})
+}
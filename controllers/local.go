@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"my_user_system/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultInvalidateChannel 未配置时广播本地缓存失效消息的频道
+	defaultInvalidateChannel = "user_system:cache:invalidate"
+	defaultLocalSize         = 10000 // 未配置时本地缓存的最大条目数
+	defaultLocalTTL          = 5     // 未配置时本地缓存的过期时间，单位秒
+)
+
+// local 进程内的一级缓存，未开启时为 nil，所有操作直接落到 Redis
+var local *lruCache
+
+type lruEntry struct {
+	key      string
+	val      string
+	expireAt time.Time
+}
+
+// lruCache 带过期时间的定长 LRU，线程安全
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get 获取未过期的缓存值
+func (c *lruCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expireAt) {
+		c.removeElement(elem)
+		return "", false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.val, true
+}
+
+// Set 写入缓存，超出容量时淘汰最久未使用的条目
+func (c *lruCache) Set(key, val string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expireAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.val, entry.expireAt = val, expireAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, val: val, expireAt: expireAt})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Del 删除指定的键
+func (c *lruCache) Del(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// DelPrefix 删除指定前缀的全部键
+func (c *lruCache) DelPrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *lruCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
+
+// invalidateChannel 本地缓存失效消息的广播频道
+func invalidateChannel() string {
+	if channel := conf.GetGlobalConfig().Cache.Local.Channel; channel != "" {
+		return channel
+	}
+	return defaultInvalidateChannel
+}
+
+// InitLocalCache 按配置开启进程内一级缓存，并订阅其他实例广播的失效消息
+func InitLocalCache() {
+	localConf := conf.GetGlobalConfig().Cache.Local
+	if !localConf.Enabled {
+		return
+	}
+	size, ttl := localConf.Size, localConf.TTL
+	if size <= 0 {
+		size = defaultLocalSize
+	}
+	if ttl <= 0 {
+		ttl = defaultLocalTTL
+	}
+	local = newLRUCache(size, time.Duration(ttl)*time.Second)
+
+	pubsub := utils.GetRedisCli().Subscribe(context.Background(), invalidateChannel())
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		// Redis 暂不可用时不阻塞启动，pubsub 会在后台自动重连并重新订阅
+		log.Errorf("InitLocalCache|subscribe cache invalidate channel err:%v", err)
+	}
+	go func() {
+		// 连接断开时 go-redis 会自动重连并重新订阅
+		for msg := range pubsub.Channel() {
+			var keys []string
+			if err := json.Unmarshal([]byte(msg.Payload), &keys); err != nil {
+				log.Errorf("InitLocalCache|invalid invalidate message %s, err:%v", msg.Payload, err)
+				continue
+			}
+			evictLocal(keys)
+		}
+	}()
+	log.Infof("InitLocalCache success, size=%d|ttl=%ds", size, ttl)
+}
+
+// evictLocal 删除本地缓存，以 * 结尾的键按前缀删除。会话的键同时清除缓存的内省结果
+func evictLocal(keys []string) {
+	for _, key := range keys {
+		if strings.HasSuffix(key, "*") {
+			local.DelPrefix(strings.TrimSuffix(key, "*"))
+			continue
+		}
+		local.Del(key)
+		evictIntrospection(key)
+	}
+}
+
+// getThroughLocal 先读本地缓存，未命中再读 Redis 并回填本地缓存
+func getThroughLocal(redisKey string) (string, error) {
+	if local != nil {
+		if val, ok := local.Get(redisKey); ok {
+			return val, nil
+		}
+	}
+	val, err := utils.GetRedisCli().Get(context.Background(), redisKey).Result()
+	if err != nil {
+		return "", err
+	}
+	if local != nil {
+		local.Set(redisKey, val)
+	}
+	return val, nil
+}
+
+// invalidateLocal 删除本实例的本地缓存并广播给其他实例，广播失败时依赖本地缓存的短过期时间兜底
+func invalidateLocal(keys ...string) {
+	if local == nil || len(keys) == 0 {
+		return
+	}
+	evictLocal(keys)
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return
+	}
+	if err := utils.GetRedisCli().Publish(context.Background(), invalidateChannel(), payload).Err(); err != nil {
+		log.Errorf("invalidateLocal|publish failed, keys=%v|err=%v", keys, err)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+func oauthCodeKey(tenantID int, codeHash string) string {
+	return tenantKey(tenantID, static.OAuthCodePrefix+codeHash)
+}
+
+func oauthTokenKey(tenantID int, tokenHash string) string {
+	return tenantKey(tenantID, static.OAuthTokenPrefix+tokenHash)
+}
+
+func oauthGrantKey(tenantID int, grantID string) string {
+	return tenantKey(tenantID, static.OAuthGrantPrefix+grantID)
+}
+
+// SetOAuthCode 保存授权码对应的授权信息
+func SetOAuthCode(tenantID int, codeHash, val string, expired time.Duration) error {
+	return utils.GetRedisCli().Set(context.Background(), oauthCodeKey(tenantID, codeHash), val, expired).Err()
+}
+
+// TakeOAuthCode 取出并删除授权码，同一授权码只能使用一次，不存在时返回 redis.Nil
+func TakeOAuthCode(tenantID int, codeHash string) (string, error) {
+	redisKey := oauthCodeKey(tenantID, codeHash)
+	pipe := utils.GetRedisCli().TxPipeline()
+	get := pipe.Get(context.Background(), redisKey)
+	pipe.Del(context.Background(), redisKey)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
+
+// SetOAuthAccessToken 保存访问令牌，并记入所属授权的索引。索引的过期时间随每次签发延长，
+// 不会早于其中任何一个访问令牌过期。两个键在集群模式下可能不在同一个槽，不使用事务
+func SetOAuthAccessToken(tenantID int, tokenHash, grantID, val string, expired time.Duration) error {
+	ctx := context.Background()
+	_, err := utils.GetRedisCli().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, oauthTokenKey(tenantID, tokenHash), val, expired)
+		if grantID != "" {
+			pipe.SAdd(ctx, oauthGrantKey(tenantID, grantID), tokenHash)
+			pipe.Expire(ctx, oauthGrantKey(tenantID, grantID), expired)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetOAuthAccessToken 获取访问令牌对应的授权信息，不存在或已过期时返回 redis.Nil
+func GetOAuthAccessToken(tenantID int, tokenHash string) (string, error) {
+	return utils.GetRedisCli().Get(context.Background(), oauthTokenKey(tenantID, tokenHash)).Result()
+}
+
+// DelOAuthAccessToken 吊销访问令牌。访问令牌的内省结果不缓存，吊销后立即失效
+func DelOAuthAccessToken(tenantID int, tokenHash string) error {
+	return utils.GetRedisCli().Del(context.Background(), oauthTokenKey(tenantID, tokenHash)).Err()
+}
+
+// DelOAuthGrant 吊销同一次授权签发的全部访问令牌，索引中只有令牌的哈希，因此访问令牌的内省结果不能缓存
+func DelOAuthGrant(tenantID int, grantID string) error {
+	ctx := context.Background()
+	grantKey := oauthGrantKey(tenantID, grantID)
+	hashes, err := utils.GetRedisCli().SMembers(ctx, grantKey).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(hashes)+1)
+	for _, hash := range hashes {
+		keys = append(keys, oauthTokenKey(tenantID, hash))
+	}
+	keys = append(keys, grantKey)
+	_, err = delKeys(ctx, utils.GetRedisCli(), keys...)
+	return err
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"sync"
+	"time"
+)
+
+// 限流计数的存储
+const (
+	RateLimitBackendRedis  = "redis"  // 多实例共享计数
+	RateLimitBackendMemory = "memory" // 进程内计数，单实例或开发环境使用
+)
+
+// rateLimitSweepInterval 清理进程内过期计数的间隔
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed   bool          // 是否放行
+	Limit     int           // 窗口内最大请求数
+	Remaining int           // 窗口内剩余请求数
+	Reset     time.Duration // 多久之后窗口内最早的一次请求过期，拒绝时即需要等待的时间
+}
+
+// slidingWindowScript 滑动窗口日志：有序集合记录窗口内每次请求的时间，超过上限时拒绝。
+// 返回 {是否放行, 剩余次数, 最早一次请求过期的毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// allowRedis 基于 Redis 的滑动窗口限流，多实例共享计数
+func allowRedis(key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	token, err := utils.RandomToken(4)
+	if err != nil {
+		return nil, err
+	}
+	// 同一毫秒内的多次请求需要不同的成员
+	member := fmt.Sprintf("%d-%s", now, token)
+	res, err := slidingWindowScript.Run(context.Background(), utils.GetRedisCli(), []string{key},
+		now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Limit:     limit,
+		Remaining: int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// windowHits 进程内一个限流键的请求记录
+type windowHits struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+// memoryLimiter 进程内的滑动窗口限流，与 Redis 版本行为一致
+type memoryLimiter struct {
+	mu        sync.Mutex
+	keys      map[string]*windowHits
+	lastSweep time.Time
+}
+
+var localLimiter = &memoryLimiter{keys: make(map[string]*windowHits)}
+
+func (l *memoryLimiter) allow(key string, limit int, window time.Duration) *RateLimitResult {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	entry, ok := l.keys[key]
+	if !ok {
+		entry = &windowHits{}
+		l.keys[key] = entry
+	}
+	entry.window = window
+	entry.hits = pruneHits(entry.hits, now.Add(-window))
+
+	result := &RateLimitResult{Limit: limit}
+	if len(entry.hits) < limit {
+		entry.hits = append(entry.hits, now)
+		result.Allowed = true
+	}
+	result.Remaining = limit - len(entry.hits)
+	result.Reset = entry.hits[0].Add(window).Sub(now)
+	return result
+}
+
+// sweep 删除窗口内已没有请求的键，避免内存无限增长
+func (l *memoryLimiter) sweep(now time.Time) {
+	for key, entry := range l.keys {
+		if entry.hits = pruneHits(entry.hits, now.Add(-entry.window)); len(entry.hits) == 0 {
+			delete(l.keys, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// pruneHits 去掉 since 之前的请求记录，记录按时间递增
+func pruneHits(hits []time.Time, since time.Time) []time.Time {
+	idx := 0
+	for idx < len(hits) && !hits[idx].After(since) {
+		idx++
+	}
+	return hits[idx:]
+}
+
+// AllowRequest 滑动窗口限流：key 在任意 window 长度的时间内最多放行 limit 次请求，被拒绝的请求不计数。
+// 配置为 redis 时 Redis 不可用会退化为进程内计数，此时多实例之间的限制会放宽
+func AllowRequest(key string, limit int, window time.Duration) *RateLimitResult {
+	key = static.RateLimitPrefix + ":" + key
+	if conf.GetGlobalConfig().RateLimit.Backend != RateLimitBackendMemory && !Degraded() {
+		result, err := allowRedis(key, limit, window)
+		if err == nil {
+			return result
+		}
+		log.Warnf("AllowRequest|redis rate limit failed, fallback to memory, key=%s|err=%v", key, err)
+	}
+	return localLimiter.allow(key, limit, window)
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterSlidingWindow(t *testing.T) {
+	l := &memoryLimiter{keys: make(map[string]*windowHits)}
+	window := 50 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		result := l.allow("k", 3, window)
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: got %+v", i, result)
+		}
+	}
+	result := l.allow("k", 3, window)
+	if result.Allowed || result.Remaining != 0 || result.Reset <= 0 || result.Reset > window {
+		t.Fatalf("expected rejection with reset within window, got %+v", result)
+	}
+	if other := l.allow("other", 3, window); !other.Allowed {
+		t.Fatalf("keys should be counted separately, got %+v", other)
+	}
+
+	time.Sleep(window + 10*time.Millisecond)
+	if result := l.allow("k", 3, window); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("expected window to slide, got %+v", result)
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	l := &memoryLimiter{keys: make(map[string]*windowHits)}
+	l.allow("k", 1, time.Millisecond)
+	l.sweep(time.Now().Add(time.Second))
+	if len(l.keys) != 0 {
+		t.Fatalf("expected expired keys to be swept, got %d", len(l.keys))
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"my_user_system/utils"
+)
+
+// delKeys 删除多个键。集群模式下多键 DEL 要求所有键在同一个槽位，因此逐个删除并通过 pipeline 批量发送
+func delKeys(ctx context.Context, client redis.Cmdable, keys ...string) (int64, error) {
+	switch len(keys) {
+	case 0:
+		return 0, nil
+	case 1:
+		return client.Del(ctx, keys[0]).Result()
+	}
+	cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.(*redis.IntCmd).Val()
+	}
+	return deleted, err
+}
+
+// forEachNode 在每个主节点上执行 fn，非集群模式下只执行一次。SCAN 等命令在集群中只作用于单个节点，需要逐个节点执行
+func forEachNode(ctx context.Context, fn func(ctx context.Context, client redis.Cmdable) error) error {
+	if cluster, ok := utils.GetRedisCli().(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return fn(ctx, client)
+		})
+	}
+	return fn(ctx, utils.GetRedisCli())
+}
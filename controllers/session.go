@@ -0,0 +1,518 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+// 会话存储模式
+const (
+	SessionStoreRedis  = "redis"  // 只存 Redis，Redis 清空后全部用户需要重新登录
+	SessionStoreDB     = "db"     // 只存数据库 t_session
+	SessionStoreHybrid = "hybrid" // 数据库持久化，Redis 作为写穿透缓存
+)
+
+const (
+	// defaultSessionCleanupInterval 未配置时清理过期会话的间隔，单位秒
+	defaultSessionCleanupInterval = 600
+	// sessionCleanupBatch 每批删除的过期会话数量，避免长事务
+	sessionCleanupBatch = 1000
+	// defaultSessionMaxLifetime 未配置时会话的绝对有效期，单位秒
+	defaultSessionMaxLifetime = 86400
+	// sessionRenewInterval 滑动续期的最小间隔，避免每个请求都写一次存储
+	sessionRenewInterval = time.Minute
+)
+
+// ErrSessionExpired 会话超过了绝对有效期
+var ErrSessionExpired = errors.New("session expired")
+
+// ErrSessionUserMismatch 用其他用户的信息刷新会话
+var ErrSessionUserMismatch = errors.New("session belongs to another user")
+
+// sessionData 会话中保存的内容：用户信息副本加会话元数据。
+// 元数据字段和用户字段平铺在同一个 JSON 对象里，旧格式的会话仍能正常解析；密码哈希不写入会话，见 marshalSession
+type sessionData struct {
+	model.User
+	LoginTime int64 `json:"login_time,omitempty"` // 登录时间戳，绝对有效期从这里算起，为 0 表示旧会话，不再续期
+	RenewTime int64 `json:"renew_time,omitempty"` // 最近一次续期的时间戳，为 0 表示登录后还没有续期
+	Remember  bool  `json:"remember,omitempty"`   // 是否勾选了"记住我"
+	// Impersonation 模拟登录信息，与会话一起持久化和续期，普通会话为空
+	Impersonation *ImpersonationInfo `json:"impersonation,omitempty"`
+}
+
+// lifetime 会话的空闲超时和绝对有效期
+func (d *sessionData) lifetime() (idle, max time.Duration) {
+	cacheConf := conf.GetGlobalConfig().Cache
+	if d.Remember && cacheConf.RememberExpired > 0 {
+		// 记住我的会话有效期固定，续期不会超过登录时间加 RememberExpired
+		remember := time.Duration(cacheConf.RememberExpired) * time.Second
+		return remember, remember
+	}
+	idle = time.Duration(cacheConf.SessionExpired) * time.Second
+	maxLifetime := cacheConf.SessionMaxLifetime
+	if maxLifetime <= 0 {
+		maxLifetime = defaultSessionMaxLifetime
+	}
+	max = time.Duration(maxLifetime) * time.Second
+	if max < idle {
+		max = idle
+	}
+	return idle, max
+}
+
+// deadline 会话的绝对过期时间
+func (d *sessionData) deadline() time.Time {
+	_, max := d.lifetime()
+	return time.Unix(d.LoginTime, 0).Add(max)
+}
+
+// renewTTL 续期后的有效期：空闲超时，但不超过绝对过期时间
+func (d *sessionData) renewTTL(now time.Time) time.Duration {
+	idle, _ := d.lifetime()
+	if left := d.deadline().Sub(now); left < idle {
+		return left
+	}
+	return idle
+}
+
+// ttl 会话的剩余有效期，由最近一次续期(未续期时为登录时间)加空闲超时算出，不超过绝对过期时间，不需要查询存储
+func (d *sessionData) ttl(now time.Time) time.Duration {
+	idle, _ := d.lifetime()
+	last := d.RenewTime
+	if last == 0 {
+		last = d.LoginTime
+	}
+	expire := time.Unix(last, 0).Add(idle)
+	if deadline := d.deadline(); expire.After(deadline) {
+		expire = deadline
+	}
+	return expire.Sub(now)
+}
+
+// sessionStoreMode 会话存储模式，未配置时为 redis
+func sessionStoreMode() string {
+	switch mode := conf.GetGlobalConfig().Cache.SessionStore; mode {
+	case SessionStoreDB, SessionStoreHybrid:
+		return mode
+	}
+	return SessionStoreRedis
+}
+
+// sessionInDB 会话是否持久化到数据库
+func sessionInDB() bool {
+	return sessionStoreMode() != SessionStoreRedis
+}
+
+// sessionInRedis 会话是否写入 Redis
+func sessionInRedis() bool {
+	return sessionStoreMode() != SessionStoreDB
+}
+
+// sessionLongestLifetime 会话可能存活的最长时间，用于会话索引和待补删记录的过期时间
+func sessionLongestLifetime() time.Duration {
+	_, max := (&sessionData{}).lifetime()
+	_, remember := (&sessionData{Remember: true}).lifetime()
+	if remember > max {
+		return remember
+	}
+	return max
+}
+
+// sessionKey 会话的缓存键
+func sessionKey(tenantID int, session string) string {
+	return tenantKey(tenantID, static.SessionKeyPrefix+session)
+}
+
+// InitSessionStore 数据库存储会话时定期清理过期的会话
+func InitSessionStore() {
+	if !sessionInDB() {
+		return
+	}
+	interval := conf.GetGlobalConfig().Cache.SessionCleanupInterval
+	if interval <= 0 {
+		interval = defaultSessionCleanupInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleanupExpiredSessions()
+		}
+	}()
+	log.Infof("InitSessionStore success, mode=%s|cleanup_interval=%ds", sessionStoreMode(), interval)
+}
+
+// cleanupExpiredSessions 分批删除过期会话
+func cleanupExpiredSessions() {
+	var total int64
+	for {
+		n, err := dao.DeleteExpiredSessions(time.Now(), sessionCleanupBatch)
+		if err != nil {
+			log.Errorf("cleanupExpiredSessions|err:%v", err)
+			return
+		}
+		total += n
+		if n < sessionCleanupBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Infof("cleanupExpiredSessions|deleted %d expired sessions", total)
+	}
+}
+
+// getSessionFromDB 从数据库读取未过期的会话，不存在时返回 errCacheMiss
+func getSessionFromDB(tenantID int, session string) (*model.Session, error) {
+	record, err := dao.GetSession(tenantID, session)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errCacheMiss
+	}
+	return record, nil
+}
+
+// setSessionCache 把会话写入 Redis 并记录到用户的会话索引
+func setSessionCache(tenantID int, userName, session string, val []byte, expired time.Duration) error {
+	redisKey := sessionKey(tenantID, session)
+	if err := utils.GetRedisCli().Set(context.Background(), redisKey, val, expired).Err(); err != nil {
+		return err
+	}
+	invalidateLocal(redisKey)
+	// 记录用户名下的会话，便于禁用账号等场景下踢掉该用户的全部会话
+	indexKey := tenantKey(tenantID, static.UserSessionsPrefix+userName)
+	pipe := utils.GetRedisCli().TxPipeline()
+	pipe.SAdd(context.Background(), indexKey, session)
+	// 同一用户的会话有效期可能不同，索引按最长的保留，过期的会话在 ListUserSessions 中清理
+	pipe.Expire(context.Background(), indexKey, sessionLongestLifetime())
+	_, err := pipe.Exec(context.Background())
+	return err
+}
+
+// getSessionData 读取会话。hybrid 模式下先读 Redis，未命中或 Redis 不可用时读数据库并回填 Redis；
+// 超过绝对有效期的会话返回 ErrSessionExpired
+func getSessionData(tenantID int, session string) (*sessionData, error) {
+	redisKey := sessionKey(tenantID, session)
+	if pendingDeletes.has(redisKey) {
+		return nil, errCacheMiss
+	}
+	var (
+		val string
+		err error
+	)
+	if sessionInRedis() {
+		val, err = getThroughLocal(redisKey)
+	}
+	if !sessionInRedis() || (err != nil && sessionInDB()) {
+		if err != nil && !IsMiss(err) {
+			log.Warnf("getSessionData|redis unavailable, fallback to db, err:%v", err)
+		}
+		record, dbErr := getSessionFromDB(tenantID, session)
+		if dbErr != nil {
+			return nil, dbErr
+		}
+		val, err = record.Data, nil
+		if sessionInRedis() && !Degraded() {
+			if err := setSessionCache(tenantID, record.UserName, session, []byte(val), time.Until(record.ExpireTime)); err != nil {
+				log.Warnf("getSessionData|refill session cache failed, session=%s|err=%v", session, err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &sessionData{}
+	if err := json.Unmarshal([]byte(val), data); err != nil {
+		return nil, err
+	}
+	// 旧会话中可能带有密码哈希，读取后丢弃，刷新会话时写回的记录中不再包含
+	data.PassWord = ""
+	// 配置调小后，已存在的会话也按新的绝对有效期失效
+	if data.LoginTime > 0 && time.Now().After(data.deadline()) {
+		return nil, ErrSessionExpired
+	}
+	return data, nil
+}
+
+// GetSessionInfo 获取会话中的用户信息
+func GetSessionInfo(tenantID int, session string) (*model.User, error) {
+	data, err := getSessionData(tenantID, session)
+	if err != nil {
+		return nil, err
+	}
+	return &data.User, nil
+}
+
+// marshalSession 序列化会话记录，不包含密码哈希。会话记录会写入 Redis 和数据库，校验密码时总是从用户表读取
+func marshalSession(data *sessionData) ([]byte, error) {
+	stored := *data
+	stored.PassWord = ""
+	return json.Marshal(&stored)
+}
+
+// saveSession 保存会话。持久化到数据库的模式下以数据库为准，Redis 写入失败只记录日志
+func saveSession(data *sessionData, session string, expired time.Duration) error {
+	redisKey := sessionKey(data.TenantID, session)
+	val, err := marshalSession(data)
+	if err != nil {
+		return err
+	}
+	pendingDeletes.remove(redisKey)
+	if sessionInDB() {
+		err := dao.SaveSession(&model.Session{
+			TenantID:   data.TenantID,
+			SessionID:  session,
+			UserName:   data.Name,
+			Data:       string(val),
+			ExpireTime: time.Now().Add(expired),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !sessionInRedis() {
+		return nil
+	}
+	err = setSessionCache(data.TenantID, data.Name, session, val, expired)
+	if err != nil && sessionInDB() {
+		// 数据库已保存，删掉可能残留的旧缓存，下次读取时从数据库回填
+		log.Warnf("saveSession|cache session failed, session=%s|err=%v", session, err)
+		delSessionCache(data.TenantID, session)
+		return nil
+	}
+	return err
+}
+
+// CreateSession 登录后创建会话，remember 为 true 时使用"记住我"的有效期。返回会话有效期，用于设置 Cookie
+func CreateSession(user *model.User, session string, remember bool) (time.Duration, error) {
+	return createSession(&sessionData{User: *user, LoginTime: time.Now().Unix(), Remember: remember}, session)
+}
+
+// CreateImpersonationSession 创建模拟登录会话，模拟登录信息写在会话记录中，不会与会话分开丢失或过期
+func CreateImpersonationSession(user *model.User, session string, info *ImpersonationInfo) (time.Duration, error) {
+	return createSession(&sessionData{User: *user, LoginTime: time.Now().Unix(), Impersonation: info}, session)
+}
+
+func createSession(data *sessionData, session string) (time.Duration, error) {
+	idle, _ := data.lifetime()
+	if err := saveSession(data, session, idle); err != nil {
+		return 0, err
+	}
+	return idle, nil
+}
+
+// SetSessionInfo 用最新的用户信息刷新已有会话，保留登录时间和剩余有效期。
+// 只能用会话所属用户的信息刷新，否则返回 ErrSessionUserMismatch
+func SetSessionInfo(user *model.User, session string) error {
+	data, err := getSessionData(user.TenantID, session)
+	if err != nil {
+		return err
+	}
+	if data.Name != user.Name || data.TenantID != user.TenantID {
+		return ErrSessionUserMismatch
+	}
+	ttl, err := GetSessionTTL(user.TenantID, session)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return errCacheMiss
+	}
+	data.User = *user
+	return saveSession(data, session, ttl)
+}
+
+// TouchSession 滑动续期：把会话有效期重新延长到空闲超时，但不超过绝对有效期。
+// 剩余有效期由会话记录算出，距上次续期不足 sessionRenewInterval 时不访问存储。返回会话剩余有效期
+func TouchSession(tenantID int, session string) (time.Duration, error) {
+	data, err := getSessionData(tenantID, session)
+	if err == ErrSessionExpired {
+		DelSessionInfo(tenantID, session)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if data.LoginTime == 0 {
+		// 旧会话没有登录时间，无法算出有效期，从存储读取，不再续期
+		ttl, err := GetSessionTTL(tenantID, session)
+		if err != nil {
+			return 0, err
+		}
+		if ttl <= 0 {
+			return 0, errCacheMiss
+		}
+		return ttl, nil
+	}
+	now := time.Now()
+	ttl := data.ttl(now)
+	if ttl <= 0 {
+		return 0, errCacheMiss
+	}
+	renewed := data.renewTTL(now)
+	if renewed-ttl < sessionRenewInterval {
+		return ttl, nil
+	}
+	if err := renewSession(data, session, now, renewed); err != nil {
+		return ttl, err
+	}
+	return renewed, nil
+}
+
+// renewSession 续期会话，连同续期时间一起写回会话记录，会话在此期间已被删除时不会重新写入。
+// 模拟登录信息在会话记录中，随会话一起续期；升级前单独保存在 Redis 中的模拟登录标记在任何存储模式下都一起续期，
+// 避免先于会话过期后变成普通会话
+func renewSession(data *sessionData, session string, now time.Time, expired time.Duration) error {
+	data.RenewTime = now.Unix()
+	val, err := marshalSession(data)
+	if err != nil {
+		return err
+	}
+	legacyKey := tenantKey(data.TenantID, static.ImpersonatePrefix+session)
+	if sessionInDB() {
+		if err := dao.RenewSession(data.TenantID, session, string(val), now.Add(expired)); err != nil {
+			return err
+		}
+	}
+	if !sessionInRedis() {
+		// 标记不存在时 EXPIRE 不做任何事
+		if err := utils.GetRedisCli().Expire(context.Background(), legacyKey, expired).Err(); err != nil {
+			log.Warnf("renewSession|renew impersonation marker failed, session=%s|err=%v", session, err)
+		}
+		return nil
+	}
+	redisKey := sessionKey(data.TenantID, session)
+	pipe := utils.GetRedisCli().Pipeline()
+	pipe.SetXX(context.Background(), redisKey, val, expired)
+	pipe.Expire(context.Background(), legacyKey, expired)
+	_, err = pipe.Exec(context.Background())
+	if err != nil && sessionInDB() {
+		// 数据库已续期，删掉缓存，下次读取时按数据库中的内容回填
+		log.Warnf("renewSession|renew session cache failed, session=%s|err=%v", session, err)
+		delSessionCache(data.TenantID, session)
+		return nil
+	}
+	invalidateLocal(redisKey)
+	return err
+}
+
+// delSessionCache 删除 Redis 中的会话，Redis 不可用时记录待补删
+func delSessionCache(tenantID int, sessions ...string) error {
+	keys := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		keys = append(keys, sessionKey(tenantID, session))
+	}
+	_, err := delKeys(context.Background(), utils.GetRedisCli(), keys...)
+	invalidateLocal(keys...)
+	evictIntrospection(keys...)
+	if err != nil && !IsMiss(err) && sessionInDB() {
+		pendingDeletes.add(keys...)
+		log.Warnf("delSessionCache|redis unavailable, %d sessions will be deleted later, err:%v", len(keys), err)
+		return nil
+	}
+	return err
+}
+
+// DelSessionInfo 删除会话
+func DelSessionInfo(tenantID int, session string) error {
+	if sessionInDB() {
+		if err := dao.DeleteSession(tenantID, session); err != nil {
+			return err
+		}
+	}
+	if !sessionInRedis() {
+		evictIntrospection(sessionKey(tenantID, session))
+		return nil
+	}
+	if user, err := GetSessionInfo(tenantID, session); err == nil {
+		utils.GetRedisCli().SRem(context.Background(), tenantKey(tenantID, static.UserSessionsPrefix+user.Name), session)
+	}
+	return delSessionCache(tenantID, session)
+}
+
+// ListUserSessions 获取用户名下仍然有效的会话
+func ListUserSessions(tenantID int, username string) ([]string, error) {
+	if sessionInDB() {
+		return dao.ListUserSessionIDs(tenantID, username)
+	}
+	indexKey := tenantKey(tenantID, static.UserSessionsPrefix+username)
+	sessions, err := utils.GetRedisCli().SMembers(context.Background(), indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	alive := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		n, err := utils.GetRedisCli().Exists(context.Background(), sessionKey(tenantID, session)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			// 会话已过期，顺手清理索引
+			utils.GetRedisCli().SRem(context.Background(), indexKey, session)
+			continue
+		}
+		alive = append(alive, session)
+	}
+	return alive, nil
+}
+
+// DelUserSessions 删除用户名下的全部会话
+func DelUserSessions(tenantID int, username string) error {
+	var sessions []string
+	if sessionInDB() {
+		var err error
+		if sessions, err = dao.ListUserSessionIDs(tenantID, username); err != nil {
+			return err
+		}
+		if err := dao.DeleteUserSessions(tenantID, username); err != nil {
+			return err
+		}
+	}
+	if !sessionInRedis() {
+		for _, session := range sessions {
+			evictIntrospection(sessionKey(tenantID, session))
+		}
+		return nil
+	}
+	indexKey := tenantKey(tenantID, static.UserSessionsPrefix+username)
+	indexed, err := utils.GetRedisCli().SMembers(context.Background(), indexKey).Result()
+	if err != nil && !sessionInDB() {
+		return err
+	}
+	sessions = append(sessions, indexed...)
+	utils.GetRedisCli().Del(context.Background(), indexKey)
+	if len(sessions) == 0 {
+		return nil
+	}
+	return delSessionCache(tenantID, sessions...)
+}
+
+// GetSessionTTL 获取会话剩余有效期。hybrid 模式下先读 Redis，未命中或 Redis 不可用时再读数据库，
+// Redis 中会话的过期时间与数据库一致
+func GetSessionTTL(tenantID int, session string) (time.Duration, error) {
+	if sessionInRedis() {
+		ttl, err := utils.GetRedisCli().TTL(context.Background(), sessionKey(tenantID, session)).Result()
+		if !sessionInDB() || (err == nil && ttl > 0) {
+			return ttl, err
+		}
+		if err != nil {
+			log.Warnf("GetSessionTTL|redis unavailable, fallback to db, err:%v", err)
+		}
+	}
+	record, err := getSessionFromDB(tenantID, session)
+	if IsMiss(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Until(record.ExpireTime), nil
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"encoding/json"
+	"my_user_system/conf"
+	"my_user_system/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetSessionInfoRejectsOtherUser(t *testing.T) {
+	cacheConf := &conf.GetGlobalConfig().Cache
+	saved, savedLocal := *cacheConf, local
+	defer func() { *cacheConf, local = saved, savedLocal }()
+	cacheConf.SessionStore = SessionStoreRedis
+	// 会话从本地缓存读取，不依赖 Redis
+	local = newLRUCache(10, time.Minute)
+	val, _ := json.Marshal(&sessionData{User: model.User{ID: 2, Name: "alice"}, LoginTime: time.Now().Unix()})
+	local.Set(sessionKey(0, "s1"), string(val))
+
+	err := SetSessionInfo(&model.User{ID: 1, Name: "admin"}, "s1")
+	if err != ErrSessionUserMismatch {
+		t.Fatalf("SetSessionInfo with another user = %v, want ErrSessionUserMismatch", err)
+	}
+}
+
+func TestImpersonationStoredWithSession(t *testing.T) {
+	cacheConf := &conf.GetGlobalConfig().Cache
+	saved, savedLocal := *cacheConf, local
+	defer func() { *cacheConf, local = saved, savedLocal }()
+	cacheConf.SessionStore = SessionStoreRedis
+	local = newLRUCache(10, time.Minute)
+	// 模拟登录信息和会话在同一条记录中，从数据库回填的会话同样带有标记
+	info := &ImpersonationInfo{Actor: "admin", ActorSession: "s0", Target: "alice", StartTime: time.Now()}
+	val, _ := json.Marshal(&sessionData{User: model.User{ID: 2, Name: "alice"}, LoginTime: time.Now().Unix(), Impersonation: info})
+	local.Set(sessionKey(0, "s2"), string(val))
+
+	got, err := GetImpersonation(0, "s2")
+	if err != nil || got.Actor != "admin" || got.Target != "alice" {
+		t.Fatalf("GetImpersonation = %+v, %v, want actor admin", got, err)
+	}
+}
+
+func TestMarshalSessionOmitsPassword(t *testing.T) {
+	data := &sessionData{User: model.User{ID: 2, Name: "alice", PassWord: "$2a$10$hash"}, LoginTime: time.Now().Unix()}
+	val, err := marshalSession(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(val), "$2a$10$hash") {
+		t.Errorf("session record contains password hash: %s", val)
+	}
+	if data.PassWord == "" {
+		t.Errorf("marshalSession should not modify the caller's data")
+	}
+}
+
+func TestSessionDataTTL(t *testing.T) {
+	cacheConf := &conf.GetGlobalConfig().Cache
+	saved := *cacheConf
+	defer func() { *cacheConf = saved }()
+	cacheConf.SessionExpired, cacheConf.SessionMaxLifetime, cacheConf.RememberExpired = 600, 3600, 0
+
+	// 时间戳精确到秒，now 取整秒避免误差
+	now := time.Unix(time.Now().Unix(), 0)
+	cases := []struct {
+		name string
+		data sessionData
+		want time.Duration
+	}{
+		{"not renewed", sessionData{LoginTime: now.Add(-100 * time.Second).Unix()}, 500 * time.Second},
+		{"renewed", sessionData{LoginTime: now.Add(-1000 * time.Second).Unix(), RenewTime: now.Add(-100 * time.Second).Unix()}, 500 * time.Second},
+		{"capped by max lifetime", sessionData{LoginTime: now.Add(-3500 * time.Second).Unix(), RenewTime: now.Add(-10 * time.Second).Unix()}, 100 * time.Second},
+	}
+	for _, c := range cases {
+		if got := c.data.ttl(now); got != c.want {
+			t.Errorf("%s: ttl = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"my_user_system/utils"
+)
+
+// AddToStream 向 Redis Stream 追加一条消息，maxLen 大于 0 时近似裁剪到该长度，返回消息ID
+func AddToStream(stream string, maxLen int64, values map[string]interface{}) (string, error) {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+	return utils.GetRedisCli().XAdd(context.Background(), args).Result()
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+)
+
+// tenantKey 给缓存键加上租户前缀，形如 t3:session_xxx，便于按租户整体清理
+func tenantKey(tenantID int, key string) string {
+	return fmt.Sprintf("t%d:%s", tenantID, key)
+}
+
+// FlushTenantCache 清理某个租户的全部缓存，包括会话，返回删除的键数量
+func FlushTenantCache(tenantID int) (int64, error) {
+	pattern := tenantKey(tenantID, "*")
+	var deleted int64
+	err := forEachNode(context.Background(), func(ctx context.Context, client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, pattern, 500).Result()
+			if err != nil {
+				return err
+			}
+			n, err := delKeys(ctx, client, keys...)
+			atomic.AddInt64(&deleted, n)
+			if err != nil {
+				return err
+			}
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	})
+	invalidateLocal(pattern)
+	if err != nil {
+		return deleted, err
+	}
+	log.Infof("FlushTenantCache success, tenant_id=%d|deleted=%d", tenantID, deleted)
+	return deleted, nil
+}
@@ -0,0 +1,141 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"sync"
+	"time"
+)
+
+// auditChainReady 已确认存在链尾记录的租户
+var auditChainReady sync.Map
+
+// ensureAuditChain 租户第一次写入审计日志前插入链尾记录。单独提交，避免在加锁的事务中插入导致死锁
+func ensureAuditChain(tenantID int) error {
+	if _, ok := auditChainReady.Load(tenantID); ok {
+		return nil
+	}
+	err := utils.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(&model.AuditChain{TenantID: tenantID}).Error
+	if err != nil {
+		return err
+	}
+	auditChainReady.Store(tenantID, true)
+	return nil
+}
+
+// CreateAuditLog 写入审计日志。锁住租户的链尾，取上一条记录的哈希计算本条的哈希，多实例并发写入时链也不会分叉
+func CreateAuditLog(entry *model.AuditLog) error {
+	if err := ensureAuditChain(entry.TenantID); err != nil {
+		log.Errorf("CreateAuditLog|ensure chain fail: %v", err)
+		return fmt.Errorf("CreateAuditLog fail: %v", err)
+	}
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		head := &model.AuditChain{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", entry.TenantID).First(head).Error
+		if err != nil {
+			return err
+		}
+		entry.CreateTime = time.Now()
+		entry.PrevHash = head.LastHash
+		entry.Hash = entry.ComputeHash()
+		if err := tx.Model(&model.AuditLog{}).Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.AuditChain{}).Where("tenant_id = ?", entry.TenantID).
+			Update("last_hash", entry.Hash).Error
+	})
+	if err != nil {
+		log.Errorf("CreateAuditLog fail: %v", err)
+		return fmt.Errorf("CreateAuditLog fail: %v", err)
+	}
+	return nil
+}
+
+// ListAuditLogsByUser 获取与用户相关的审计日志，包括用户作为操作人和操作对象的记录
+func ListAuditLogsByUser(tenantID int, userName string) ([]*model.AuditLog, error) {
+	var entries []*model.AuditLog
+	err := utils.GetDB().Model(&model.AuditLog{}).Where("tenant_id = ? AND (actor = ? OR target = ?)", tenantID, userName, userName).
+		Order("id").Find(&entries).Error
+	if err != nil {
+		log.Errorf("ListAuditLogsByUser fail: %v", err)
+		return nil, fmt.Errorf("ListAuditLogsByUser fail: %v", err)
+	}
+	return entries, nil
+}
+
+// AuditFilter 审计日志查询条件，空值表示不过滤
+type AuditFilter struct {
+	TenantID      int
+	Cursor        int // 上一页最后一条的ID，按ID倒序分页
+	Limit         int
+	Actor         string
+	Target        string
+	Action        string
+	RequestID     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// ListAuditLogs 按条件查询审计日志，最新的在前
+func ListAuditLogs(filter *AuditFilter) ([]*model.AuditLog, error) {
+	query := utils.GetDB().Model(&model.AuditLog{}).Where("tenant_id = ?", filter.TenantID)
+	if filter.Cursor > 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", escapeLike(filter.Action)+"%")
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("create_time >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("create_time < ?", filter.CreatedBefore)
+	}
+
+	var entries []*model.AuditLog
+	if err := query.Order("id desc").Limit(filter.Limit).Find(&entries).Error; err != nil {
+		log.Errorf("ListAuditLogs fail: %v", err)
+		return nil, fmt.Errorf("ListAuditLogs fail: %v", err)
+	}
+	return entries, nil
+}
+
+// ScanAuditLogs 按ID升序分批获取租户的审计日志，用于校验哈希链
+func ScanAuditLogs(tenantID, afterID, limit int) ([]*model.AuditLog, error) {
+	var entries []*model.AuditLog
+	err := utils.GetDB().Model(&model.AuditLog{}).Where("tenant_id = ? AND id > ?", tenantID, afterID).
+		Order("id").Limit(limit).Find(&entries).Error
+	if err != nil {
+		log.Errorf("ScanAuditLogs fail: %v", err)
+		return nil, fmt.Errorf("ScanAuditLogs fail: %v", err)
+	}
+	return entries, nil
+}
+
+// GetAuditChainHead 获取租户哈希链的链尾，租户还没有写入过审计日志时返回 nil
+func GetAuditChainHead(tenantID int) (*model.AuditChain, error) {
+	head := &model.AuditChain{}
+	err := utils.GetDB().Model(&model.AuditChain{}).Where("tenant_id = ?", tenantID).First(head).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetAuditChainHead fail: %v", err)
+		return nil, fmt.Errorf("GetAuditChainHead fail: %v", err)
+	}
+	return head, nil
+}
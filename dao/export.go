@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// CreateExportJob 创建导出任务
+func CreateExportJob(job *model.ExportJob) error {
+	if err := utils.GetDB().Model(&model.ExportJob{}).Create(job).Error; err != nil {
+		log.Errorf("CreateExportJob fail: %v", err)
+		return fmt.Errorf("CreateExportJob fail: %v", err)
+	}
+	return nil
+}
+
+// GetExportJobByID 根据ID获取导出任务
+func GetExportJobByID(id int) (*model.ExportJob, error) {
+	job := &model.ExportJob{}
+	if err := utils.GetDB().Model(&model.ExportJob{}).Where("id = ?", id).First(job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetExportJobByID fail: %v", err)
+		return nil, fmt.Errorf("GetExportJobByID fail: %v", err)
+	}
+	return job, nil
+}
+
+// GetExportJobByToken 根据下载凭证获取导出任务
+func GetExportJobByToken(token string) (*model.ExportJob, error) {
+	job := &model.ExportJob{}
+	if err := utils.GetDB().Model(&model.ExportJob{}).Where("download_token = ?", token).First(job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetExportJobByToken fail: %v", err)
+		return nil, fmt.Errorf("GetExportJobByToken fail: %v", err)
+	}
+	return job, nil
+}
+
+// GetLatestExportJob 获取用户最近一次导出任务
+func GetLatestExportJob(tenantID int, userName string) (*model.ExportJob, error) {
+	job := &model.ExportJob{}
+	err := utils.GetDB().Model(&model.ExportJob{}).Where("tenant_id = ? AND user_name = ?", tenantID, userName).
+		Order("id desc").First(job).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetLatestExportJob fail: %v", err)
+		return nil, fmt.Errorf("GetLatestExportJob fail: %v", err)
+	}
+	return job, nil
+}
+
+// UpdateExportJob 更新导出任务字段
+func UpdateExportJob(id int, fields map[string]interface{}) error {
+	if err := utils.GetDB().Model(&model.ExportJob{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		log.Errorf("UpdateExportJob fail: %v", err)
+		return fmt.Errorf("UpdateExportJob fail: %v", err)
+	}
+	return nil
+}
+
+// MarkExportDownloaded 将任务标记为已下载，只有状态为已完成的任务才能标记成功，保证下载链接只能使用一次
+func MarkExportDownloaded(id int) (bool, error) {
+	res := utils.GetDB().Model(&model.ExportJob{}).Where("id = ? AND status = ?", id, model.ExportStatusDone).
+		Update("status", model.ExportStatusDownloaded)
+	if res.Error != nil {
+		log.Errorf("MarkExportDownloaded fail: %v", res.Error)
+		return false, fmt.Errorf("MarkExportDownloaded fail: %v", res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"time"
+)
+
+// CreateInvitation 创建邀请码
+func CreateInvitation(invite *model.Invitation) error {
+	if err := utils.GetDB().Model(&model.Invitation{}).Create(invite).Error; err != nil {
+		log.Errorf("CreateInvitation fail: %v", err)
+		return fmt.Errorf("CreateInvitation fail: %v", err)
+	}
+	return nil
+}
+
+// GetInvitationByCode 获取租户下的邀请码
+func GetInvitationByCode(tenantID int, code string) (*model.Invitation, error) {
+	invite := &model.Invitation{}
+	err := utils.GetDB().Model(&model.Invitation{}).
+		Where("tenant_id = ? AND code = ?", tenantID, code).First(invite).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetInvitationByCode fail: %v", err)
+		return nil, fmt.Errorf("GetInvitationByCode fail: %v", err)
+	}
+	return invite, nil
+}
+
+// ListInvitations 获取租户下的全部邀请码，最新的在前
+func ListInvitations(tenantID int) ([]*model.Invitation, error) {
+	var invites []*model.Invitation
+	err := utils.GetDB().Model(&model.Invitation{}).
+		Where("tenant_id = ?", tenantID).Order("id desc").Find(&invites).Error
+	if err != nil {
+		log.Errorf("ListInvitations fail: %v", err)
+		return nil, fmt.Errorf("ListInvitations fail: %v", err)
+	}
+	return invites, nil
+}
+
+// RevokeInvitation 撤销邀请码，返回受影响的行数
+func RevokeInvitation(tenantID int, code, operator string) (int64, error) {
+	result := utils.GetDB().Model(&model.Invitation{}).
+		Where("tenant_id = ? AND code = ? AND revoked = ?", tenantID, code, false).
+		Updates(map[string]interface{}{"revoked": true, "modifier": operator})
+	if result.Error != nil {
+		log.Errorf("RevokeInvitation fail: %v", result.Error)
+		return 0, fmt.Errorf("RevokeInvitation fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ConsumeInvitation 占用一次邀请码使用次数，条件更新保证并发下不会超用，邀请码不可用时返回 false
+func ConsumeInvitation(id int) (bool, error) {
+	result := utils.GetDB().Model(&model.Invitation{}).
+		Where("id = ? AND revoked = ? AND used_count < max_uses AND (expire_time IS NULL OR expire_time > ?)",
+			id, false, time.Now()).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		log.Errorf("ConsumeInvitation fail: %v", result.Error)
+		return false, fmt.Errorf("ConsumeInvitation fail: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseInvitation 归还一次邀请码使用次数，注册失败时调用
+func ReleaseInvitation(id int) error {
+	err := utils.GetDB().Model(&model.Invitation{}).
+		Where("id = ? AND used_count > 0", id).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+	if err != nil {
+		log.Errorf("ReleaseInvitation fail: %v", err)
+		return fmt.Errorf("ReleaseInvitation fail: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// CreateLoginHistory 写入登录记录
+func CreateLoginHistory(entry *model.LoginHistory) error {
+	if err := utils.GetDB().Model(&model.LoginHistory{}).Create(entry).Error; err != nil {
+		log.Errorf("CreateLoginHistory fail: %v", err)
+		return fmt.Errorf("CreateLoginHistory fail: %v", err)
+	}
+	return nil
+}
+
+// ListLoginHistory 按ID倒序游标分页查询用户的登录记录，cursor 为 0 时从最新的开始，limit 为 0 时返回全部
+func ListLoginHistory(tenantID int, userName string, cursor, limit int) ([]*model.LoginHistory, error) {
+	query := utils.GetDB().Model(&model.LoginHistory{}).Where("tenant_id = ? AND user_name = ?", tenantID, userName)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var entries []*model.LoginHistory
+	if err := query.Order("id desc").Find(&entries).Error; err != nil {
+		log.Errorf("ListLoginHistory fail: %v", err)
+		return nil, fmt.Errorf("ListLoginHistory fail: %v", err)
+	}
+	return entries, nil
+}
+
+// GetLoginDeviceStats 查询用户登录成功过的次数，以及其中使用该设备的次数
+func GetLoginDeviceStats(tenantID int, userName, device string) (total, onDevice int64, err error) {
+	successQuery := func() *gorm.DB {
+		return utils.GetDB().Model(&model.LoginHistory{}).
+			Where("tenant_id = ? AND user_name = ? AND success = ?", tenantID, userName, true)
+	}
+	if err = successQuery().Count(&total).Error; err != nil {
+		log.Errorf("GetLoginDeviceStats fail: %v", err)
+		return 0, 0, fmt.Errorf("GetLoginDeviceStats fail: %v", err)
+	}
+	if total == 0 {
+		return 0, 0, nil
+	}
+	if err = successQuery().Where("device = ?", device).Count(&onDevice).Error; err != nil {
+		log.Errorf("GetLoginDeviceStats fail: %v", err)
+		return 0, 0, fmt.Errorf("GetLoginDeviceStats fail: %v", err)
+	}
+	return total, onDevice, nil
+}
@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// userColumns t_user 上后续新增的列，启动时缺失则补齐
+var userColumns = []string{"Status", "PwdResetRequired", "TenantID", "Inviter"}
+
+// legacyRoleNameIndex t_role 上旧的角色名唯一索引
+const legacyRoleNameIndex = "idx_t_role_name"
+
+// InitTables 自动建表，t_user 由 DBA 维护，这里只补齐新增的列，其余业务表自动迁移
+func InitTables() {
+	migrator := utils.GetDB().Migrator()
+	for _, column := range userColumns {
+		if migrator.HasColumn(&model.User{}, column) {
+			continue
+		}
+		if err := migrator.AddColumn(&model.User{}, column); err != nil {
+			panic("add t_user column " + column + " err:" + err.Error())
+		}
+	}
+
+	err := utils.GetDB().AutoMigrate(
+		&model.ExportJob{},
+		&model.Role{},
+		&model.Permission{},
+		&model.RolePermission{},
+		&model.UserRole{},
+		&model.AuditLog{},
+		&model.AuditChain{},
+		&model.Organization{},
+		&model.OrgMember{},
+		&model.Invitation{},
+		&model.Session{},
+		&model.PasswordHistory{},
+		&model.LoginHistory{},
+		&model.OutboxEvent{},
+		&model.WebhookSubscription{},
+		&model.WebhookDelivery{},
+		&model.OAuthClient{},
+		&model.OAuthConsent{},
+		&model.OAuthRefreshToken{},
+	)
+	if err != nil {
+		panic("auto migrate tables err:" + err.Error())
+	}
+
+	// 角色改为按租户隔离前，角色名上是全局唯一索引，不删除时不同租户不能创建同名角色
+	if migrator.HasIndex(&model.Role{}, legacyRoleNameIndex) {
+		if err := migrator.DropIndex(&model.Role{}, legacyRoleNameIndex); err != nil {
+			panic("drop t_role index " + legacyRoleNameIndex + " err:" + err.Error())
+		}
+	}
+}
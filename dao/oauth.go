@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"time"
+)
+
+// CreateOAuthClient 注册 OAuth 客户端
+func CreateOAuthClient(client *model.OAuthClient) error {
+	if err := utils.GetDB().Model(&model.OAuthClient{}).Create(client).Error; err != nil {
+		log.Errorf("CreateOAuthClient fail: %v", err)
+		return fmt.Errorf("CreateOAuthClient fail: %v", err)
+	}
+	return nil
+}
+
+// GetOAuthClient 获取租户下的 OAuth 客户端，不存在时返回 nil
+func GetOAuthClient(tenantID int, clientID string) (*model.OAuthClient, error) {
+	client := &model.OAuthClient{}
+	err := utils.GetDB().Model(&model.OAuthClient{}).
+		Where("tenant_id = ? AND client_id = ?", tenantID, clientID).First(client).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetOAuthClient fail: %v", err)
+		return nil, fmt.Errorf("GetOAuthClient fail: %v", err)
+	}
+	return client, nil
+}
+
+// ListOAuthClients 获取租户下的全部 OAuth 客户端
+func ListOAuthClients(tenantID int) ([]*model.OAuthClient, error) {
+	var clients []*model.OAuthClient
+	err := utils.GetDB().Model(&model.OAuthClient{}).Where("tenant_id = ?", tenantID).Order("id").Find(&clients).Error
+	if err != nil {
+		log.Errorf("ListOAuthClients fail: %v", err)
+		return nil, fmt.Errorf("ListOAuthClients fail: %v", err)
+	}
+	return clients, nil
+}
+
+// UpdateOAuthClient 修改 OAuth 客户端，返回受影响的行数
+func UpdateOAuthClient(tenantID int, clientID string, fields map[string]interface{}) (int64, error) {
+	result := utils.GetDB().Model(&model.OAuthClient{}).
+		Where("tenant_id = ? AND client_id = ?", tenantID, clientID).Updates(fields)
+	if result.Error != nil {
+		log.Errorf("UpdateOAuthClient fail: %v", result.Error)
+		return 0, fmt.Errorf("UpdateOAuthClient fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetOAuthConsent 获取用户对应用的授权记录，不存在时返回 nil
+func GetOAuthConsent(userID int, clientID string) (*model.OAuthConsent, error) {
+	consent := &model.OAuthConsent{}
+	err := utils.GetDB().Model(&model.OAuthConsent{}).
+		Where("user_id = ? AND client_id = ?", userID, clientID).First(consent).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetOAuthConsent fail: %v", err)
+		return nil, fmt.Errorf("GetOAuthConsent fail: %v", err)
+	}
+	return consent, nil
+}
+
+// SaveOAuthConsent 保存授权记录，已存在时覆盖授权的 scope
+func SaveOAuthConsent(consent *model.OAuthConsent) error {
+	err := utils.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "modify_time"}),
+	}).Create(consent).Error
+	if err != nil {
+		log.Errorf("SaveOAuthConsent fail: %v", err)
+		return fmt.Errorf("SaveOAuthConsent fail: %v", err)
+	}
+	return nil
+}
+
+// CreateOAuthRefreshToken 保存刷新令牌
+func CreateOAuthRefreshToken(token *model.OAuthRefreshToken) error {
+	if err := utils.GetDB().Model(&model.OAuthRefreshToken{}).Create(token).Error; err != nil {
+		log.Errorf("CreateOAuthRefreshToken fail: %v", err)
+		return fmt.Errorf("CreateOAuthRefreshToken fail: %v", err)
+	}
+	return nil
+}
+
+// GetOAuthRefreshToken 按哈希获取刷新令牌，包括已吊销的，不存在时返回 nil
+func GetOAuthRefreshToken(tenantID int, tokenHash string) (*model.OAuthRefreshToken, error) {
+	token := &model.OAuthRefreshToken{}
+	err := utils.GetDB().Model(&model.OAuthRefreshToken{}).
+		Where("tenant_id = ? AND token_hash = ?", tenantID, tokenHash).First(token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetOAuthRefreshToken fail: %v", err)
+		return nil, fmt.Errorf("GetOAuthRefreshToken fail: %v", err)
+	}
+	return token, nil
+}
+
+// RevokeOAuthRefreshToken 吊销一个还有效的刷新令牌，返回 false 表示令牌已被吊销，并发刷新时只有一个请求能成功
+func RevokeOAuthRefreshToken(id int, now time.Time) (bool, error) {
+	result := utils.GetDB().Model(&model.OAuthRefreshToken{}).
+		Where("id = ? AND revoked_time IS NULL", id).Update("revoked_time", now)
+	if result.Error != nil {
+		log.Errorf("RevokeOAuthRefreshToken fail: %v", result.Error)
+		return false, fmt.Errorf("RevokeOAuthRefreshToken fail: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeOAuthGrant 吊销同一次授权下全部还有效的刷新令牌
+func RevokeOAuthGrant(grantID string, now time.Time) error {
+	err := utils.GetDB().Model(&model.OAuthRefreshToken{}).
+		Where("grant_id = ? AND revoked_time IS NULL", grantID).Update("revoked_time", now).Error
+	if err != nil {
+		log.Errorf("RevokeOAuthGrant fail: %v", err)
+		return fmt.Errorf("RevokeOAuthGrant fail: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// EnsureOrganization 组织不存在时创建
+func EnsureOrganization(org *model.Organization) error {
+	if err := utils.GetDB().Where(model.Organization{Code: org.Code}).FirstOrCreate(org).Error; err != nil {
+		log.Errorf("EnsureOrganization fail: %v", err)
+		return fmt.Errorf("EnsureOrganization fail: %v", err)
+	}
+	return nil
+}
+
+// CreateOrganization 创建组织
+func CreateOrganization(org *model.Organization) error {
+	if err := utils.GetDB().Model(&model.Organization{}).Create(org).Error; err != nil {
+		log.Errorf("CreateOrganization fail: %v", err)
+		return fmt.Errorf("CreateOrganization fail: %v", err)
+	}
+	return nil
+}
+
+// getOrganization 按条件获取组织
+func getOrganization(query string, args ...interface{}) (*model.Organization, error) {
+	org := &model.Organization{}
+	if err := utils.GetDB().Model(&model.Organization{}).Where(query, args...).First(org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("getOrganization fail: %v", err)
+		return nil, fmt.Errorf("getOrganization fail: %v", err)
+	}
+	return org, nil
+}
+
+// GetOrganizationByCode 根据租户标识获取组织
+func GetOrganizationByCode(code string) (*model.Organization, error) {
+	return getOrganization("code = ?", code)
+}
+
+// GetOrganizationByHost 根据绑定的域名获取组织
+func GetOrganizationByHost(host string) (*model.Organization, error) {
+	return getOrganization("host = ?", host)
+}
+
+// ListOrganizations 获取全部组织
+func ListOrganizations() ([]*model.Organization, error) {
+	var orgs []*model.Organization
+	if err := utils.GetDB().Model(&model.Organization{}).Order("id").Find(&orgs).Error; err != nil {
+		log.Errorf("ListOrganizations fail: %v", err)
+		return nil, fmt.Errorf("ListOrganizations fail: %v", err)
+	}
+	return orgs, nil
+}
+
+// MigrateUsersToTenant 把未归属租户的存量用户划入指定租户
+func MigrateUsersToTenant(tenantID int) (int64, error) {
+	res := utils.GetDB().Model(&model.User{}).Where("tenant_id = 0").Update("tenant_id", tenantID)
+	if res.Error != nil {
+		log.Errorf("MigrateUsersToTenant fail: %v", res.Error)
+		return 0, fmt.Errorf("MigrateUsersToTenant fail: %v", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// MigrateRolesToTenant 把未归属租户的存量角色划入指定租户
+func MigrateRolesToTenant(tenantID int) (int64, error) {
+	res := utils.GetDB().Model(&model.Role{}).Where("tenant_id = 0").Update("tenant_id", tenantID)
+	if res.Error != nil {
+		log.Errorf("MigrateRolesToTenant fail: %v", res.Error)
+		return 0, fmt.Errorf("MigrateRolesToTenant fail: %v", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// SetOrgMember 设置组织成员角色，不是成员时加入组织
+func SetOrgMember(orgID, userID int, role, operator string) error {
+	member := &model.OrgMember{}
+	err := utils.GetDB().Where(model.OrgMember{OrgID: orgID, UserID: userID}).
+		Assign(map[string]interface{}{"role": role, "modifier": operator}).
+		Attrs(model.OrgMember{CreateModel: model.CreateModel{Creator: operator}}).
+		FirstOrCreate(member).Error
+	if err != nil {
+		log.Errorf("SetOrgMember fail: %v", err)
+		return fmt.Errorf("SetOrgMember fail: %v", err)
+	}
+	return nil
+}
+
+// GetOrgMemberRole 获取用户在组织中的角色，不是组织成员时返回空串
+func GetOrgMemberRole(orgID, userID int) (string, error) {
+	var roles []string
+	err := utils.GetDB().Model(&model.OrgMember{}).Where("org_id = ? AND user_id = ?", orgID, userID).
+		Limit(1).Pluck("role", &roles).Error
+	if err != nil {
+		log.Errorf("GetOrgMemberRole fail: %v", err)
+		return "", fmt.Errorf("GetOrgMemberRole fail: %v", err)
+	}
+	if len(roles) == 0 {
+		return "", nil
+	}
+	return roles[0], nil
+}
+
+// OrgMemberInfo 组织成员及其用户名
+type OrgMemberInfo struct {
+	UserID   int
+	UserName string
+	Role     string
+}
+
+// ListOrgMembers 获取组织的全部成员
+func ListOrgMembers(orgID int) ([]*OrgMemberInfo, error) {
+	var members []*OrgMemberInfo
+	err := utils.GetDB().Model(&model.OrgMember{}).
+		Select("t_org_member.user_id AS user_id, t_user.name AS user_name, t_org_member.role AS role").
+		Joins("JOIN t_user ON t_user.id = t_org_member.user_id").
+		Where("t_org_member.org_id = ?", orgID).Order("t_org_member.id").Scan(&members).Error
+	if err != nil {
+		log.Errorf("ListOrgMembers fail: %v", err)
+		return nil, fmt.Errorf("ListOrgMembers fail: %v", err)
+	}
+	return members, nil
+}
+
+// GetOrganizationByID 根据ID获取组织
+func GetOrganizationByID(id int) (*model.Organization, error) {
+	return getOrganization("id = ?", id)
+}
@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"time"
+)
+
+// insertOutbox 在业务事务中写入领域事件
+func insertOutbox(tx *gorm.DB, events []*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, event := range events {
+		if event.NextAttemptTime.IsZero() {
+			event.NextAttemptTime = now
+		}
+	}
+	return tx.Model(&model.OutboxEvent{}).Create(events).Error
+}
+
+// CreateOutboxEvents 单独写入领域事件，用于没有数据库变更的场景，如 Redis 中的会话
+func CreateOutboxEvents(events ...*model.OutboxEvent) error {
+	if err := insertOutbox(utils.GetDB(), events); err != nil {
+		log.Errorf("CreateOutboxEvents fail: %v", err)
+		return fmt.Errorf("CreateOutboxEvents fail: %v", err)
+	}
+	return nil
+}
+
+// ListPendingOutbox 获取到了投递时间但还没有投递成功的事件，按写入顺序返回
+func ListPendingOutbox(now time.Time, limit int) ([]*model.OutboxEvent, error) {
+	var events []*model.OutboxEvent
+	err := utils.GetDB().Model(&model.OutboxEvent{}).Where("sent = ? AND next_attempt_time <= ?", false, now).
+		Order("id").Limit(limit).Find(&events).Error
+	if err != nil {
+		log.Errorf("ListPendingOutbox fail: %v", err)
+		return nil, fmt.Errorf("ListPendingOutbox fail: %v", err)
+	}
+	return events, nil
+}
+
+// ClaimOutboxEvent 抢占事件的投递权，把下一次投递时间推后到 lease，条件更新保证多个实例中只有一个能抢到
+func ClaimOutboxEvent(id int, now, lease time.Time) (bool, error) {
+	result := utils.GetDB().Model(&model.OutboxEvent{}).
+		Where("id = ? AND sent = ? AND next_attempt_time <= ?", id, false, now).
+		Update("next_attempt_time", lease)
+	if result.Error != nil {
+		log.Errorf("ClaimOutboxEvent fail: %v", result.Error)
+		return false, fmt.Errorf("ClaimOutboxEvent fail: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkOutboxSent 标记事件投递成功
+func MarkOutboxSent(id, attempts int) error {
+	err := utils.GetDB().Model(&model.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"sent": true, "attempts": attempts, "sent_time": time.Now(), "last_error": ""}).Error
+	if err != nil {
+		log.Errorf("MarkOutboxSent fail: %v", err)
+		return fmt.Errorf("MarkOutboxSent fail: %v", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed 记录投递失败，next 之后重试
+func MarkOutboxFailed(id, attempts int, next time.Time, reason string) error {
+	err := utils.GetDB().Model(&model.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"attempts": attempts, "next_attempt_time": next, "last_error": reason}).Error
+	if err != nil {
+		log.Errorf("MarkOutboxFailed fail: %v", err)
+		return fmt.Errorf("MarkOutboxFailed fail: %v", err)
+	}
+	return nil
+}
+
+// DeleteSentOutbox 删除 before 之前投递成功的事件，每次最多删除 limit 条，返回删除的条数
+func DeleteSentOutbox(before time.Time, limit int) (int64, error) {
+	result := utils.GetDB().Where("sent = ? AND sent_time <= ?", true, before).Limit(limit).Delete(&model.OutboxEvent{})
+	if result.Error != nil {
+		log.Errorf("DeleteSentOutbox fail: %v", result.Error)
+		return 0, fmt.Errorf("DeleteSentOutbox fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
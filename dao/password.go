@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// AddPasswordHistory 记录用户设置过的密码，只保留最近 keep 条
+func AddPasswordHistory(history *model.PasswordHistory, keep int) error {
+	db := utils.GetDB()
+	if err := db.Model(&model.PasswordHistory{}).Create(history).Error; err != nil {
+		log.Errorf("AddPasswordHistory fail: %v", err)
+		return fmt.Errorf("AddPasswordHistory fail: %v", err)
+	}
+	var stale []int
+	err := db.Model(&model.PasswordHistory{}).Where("user_id = ?", history.UserID).
+		Order("id desc").Offset(keep).Pluck("id", &stale).Error
+	if err != nil {
+		log.Errorf("AddPasswordHistory|list stale fail: %v", err)
+		return nil
+	}
+	if len(stale) > 0 {
+		if err := db.Where("id IN ?", stale).Delete(&model.PasswordHistory{}).Error; err != nil {
+			log.Errorf("AddPasswordHistory|delete stale fail: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListPasswordHistory 获取用户最近设置过的 limit 个密码哈希，最新的在前
+func ListPasswordHistory(userID, limit int) ([]string, error) {
+	var hashes []string
+	err := utils.GetDB().Model(&model.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("id desc").Limit(limit).Pluck("hash", &hashes).Error
+	if err != nil {
+		log.Errorf("ListPasswordHistory fail: %v", err)
+		return nil, fmt.Errorf("ListPasswordHistory fail: %v", err)
+	}
+	return hashes, nil
+}
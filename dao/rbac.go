@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"my_user_system/model"
+	"my_user_system/utils"
+)
+
+// GetRoleByName 根据角色名获取租户下的角色
+func GetRoleByName(tenantID int, name string) (*model.Role, error) {
+	role := &model.Role{}
+	if err := utils.GetDB().Model(&model.Role{}).Where("tenant_id = ? AND name = ?", tenantID, name).First(role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetRoleByName fail: %v", err)
+		return nil, fmt.Errorf("GetRoleByName fail: %v", err)
+	}
+	return role, nil
+}
+
+// CreateRole 创建角色
+func CreateRole(role *model.Role) error {
+	if err := utils.GetDB().Model(&model.Role{}).Create(role).Error; err != nil {
+		log.Errorf("CreateRole fail: %v", err)
+		return fmt.Errorf("CreateRole fail: %v", err)
+	}
+	return nil
+}
+
+// ListRoles 获取租户下的全部角色
+func ListRoles(tenantID int) ([]*model.Role, error) {
+	var roles []*model.Role
+	if err := utils.GetDB().Model(&model.Role{}).Where("tenant_id = ?", tenantID).Order("id").Find(&roles).Error; err != nil {
+		log.Errorf("ListRoles fail: %v", err)
+		return nil, fmt.Errorf("ListRoles fail: %v", err)
+	}
+	return roles, nil
+}
+
+// GetPermissionByCode 根据权限码获取权限
+func GetPermissionByCode(code string) (*model.Permission, error) {
+	perm := &model.Permission{}
+	if err := utils.GetDB().Model(&model.Permission{}).Where("code = ?", code).First(perm).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetPermissionByCode fail: %v", err)
+		return nil, fmt.Errorf("GetPermissionByCode fail: %v", err)
+	}
+	return perm, nil
+}
+
+// EnsureRole 租户下的角色不存在时创建
+func EnsureRole(role *model.Role) error {
+	if err := utils.GetDB().Where(model.Role{TenantID: role.TenantID, Name: role.Name}).FirstOrCreate(role).Error; err != nil {
+		log.Errorf("EnsureRole fail: %v", err)
+		return fmt.Errorf("EnsureRole fail: %v", err)
+	}
+	return nil
+}
+
+// EnsurePermission 权限不存在时创建
+func EnsurePermission(perm *model.Permission) error {
+	if err := utils.GetDB().Where(model.Permission{Code: perm.Code}).FirstOrCreate(perm).Error; err != nil {
+		log.Errorf("EnsurePermission fail: %v", err)
+		return fmt.Errorf("EnsurePermission fail: %v", err)
+	}
+	return nil
+}
+
+// GrantPermission 给角色授予权限，已授予时不做处理
+func GrantPermission(roleID, permissionID int, operator string) error {
+	rp := &model.RolePermission{
+		RoleID:       roleID,
+		PermissionID: permissionID,
+		CreateModel:  model.CreateModel{Creator: operator},
+	}
+	err := utils.GetDB().Where(model.RolePermission{RoleID: roleID, PermissionID: permissionID}).FirstOrCreate(rp).Error
+	if err != nil {
+		log.Errorf("GrantPermission fail: %v", err)
+		return fmt.Errorf("GrantPermission fail: %v", err)
+	}
+	return nil
+}
+
+// RevokePermission 收回角色的权限
+func RevokePermission(roleID, permissionID int) error {
+	err := utils.GetDB().Where("role_id = ? AND permission_id = ?", roleID, permissionID).Delete(&model.RolePermission{}).Error
+	if err != nil {
+		log.Errorf("RevokePermission fail: %v", err)
+		return fmt.Errorf("RevokePermission fail: %v", err)
+	}
+	return nil
+}
+
+// GetRolePermissionCodes 获取角色拥有的权限码
+func GetRolePermissionCodes(roleID int) ([]string, error) {
+	var codes []string
+	err := utils.GetDB().Model(&model.Permission{}).
+		Joins("JOIN t_role_permission rp ON rp.permission_id = t_permission.id").
+		Where("rp.role_id = ?", roleID).Order("t_permission.code").Pluck("t_permission.code", &codes).Error
+	if err != nil {
+		log.Errorf("GetRolePermissionCodes fail: %v", err)
+		return nil, fmt.Errorf("GetRolePermissionCodes fail: %v", err)
+	}
+	return codes, nil
+}
+
+// AssignRole 给用户分配角色，已分配时不做处理
+func AssignRole(userID, roleID int, operator string) error {
+	ur := &model.UserRole{
+		UserID:      userID,
+		RoleID:      roleID,
+		CreateModel: model.CreateModel{Creator: operator},
+	}
+	if err := utils.GetDB().Where(model.UserRole{UserID: userID, RoleID: roleID}).FirstOrCreate(ur).Error; err != nil {
+		log.Errorf("AssignRole fail: %v", err)
+		return fmt.Errorf("AssignRole fail: %v", err)
+	}
+	return nil
+}
+
+// UnassignRole 取消用户的角色
+func UnassignRole(userID, roleID int) error {
+	if err := utils.GetDB().Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&model.UserRole{}).Error; err != nil {
+		log.Errorf("UnassignRole fail: %v", err)
+		return fmt.Errorf("UnassignRole fail: %v", err)
+	}
+	return nil
+}
+
+// GetUserRoleNames 获取用户的角色名，只返回用户所属租户的角色
+func GetUserRoleNames(userID int) ([]string, error) {
+	var names []string
+	err := utils.GetDB().Model(&model.Role{}).
+		Joins("JOIN t_user_role ur ON ur.role_id = t_role.id").
+		Joins("JOIN t_user u ON u.id = ur.user_id AND u.tenant_id = t_role.tenant_id").
+		Where("ur.user_id = ?", userID).Order("t_role.name").Pluck("t_role.name", &names).Error
+	if err != nil {
+		log.Errorf("GetUserRoleNames fail: %v", err)
+		return nil, fmt.Errorf("GetUserRoleNames fail: %v", err)
+	}
+	return names, nil
+}
+
+// GetUserPermissionCodes 获取用户通过角色获得的全部权限码，角色改为按租户隔离前跨租户分配的角色不生效
+func GetUserPermissionCodes(userID int) ([]string, error) {
+	var codes []string
+	err := utils.GetDB().Model(&model.Permission{}).Distinct("t_permission.code").
+		Joins("JOIN t_role_permission rp ON rp.permission_id = t_permission.id").
+		Joins("JOIN t_user_role ur ON ur.role_id = rp.role_id").
+		Joins("JOIN t_role r ON r.id = ur.role_id").
+		Joins("JOIN t_user u ON u.id = ur.user_id AND u.tenant_id = r.tenant_id").
+		Where("ur.user_id = ?", userID).Pluck("t_permission.code", &codes).Error
+	if err != nil {
+		log.Errorf("GetUserPermissionCodes fail: %v", err)
+		return nil, fmt.Errorf("GetUserPermissionCodes fail: %v", err)
+	}
+	return codes, nil
+}
+
+// GetUsersByRole 获取拥有某角色的全部用户，只查询ID、租户和用户名
+func GetUsersByRole(roleID int) ([]*model.User, error) {
+	var users []*model.User
+	err := utils.GetDB().Model(&model.User{}).Select("t_user.id, t_user.tenant_id, t_user.name").
+		Joins("JOIN t_user_role ur ON ur.user_id = t_user.id").
+		Where("ur.role_id = ?", roleID).Find(&users).Error
+	if err != nil {
+		log.Errorf("GetUsersByRole fail: %v", err)
+		return nil, fmt.Errorf("GetUsersByRole fail: %v", err)
+	}
+	return users, nil
+}
+
+// CountRoleUsers 统计拥有某角色的用户数
+func CountRoleUsers(roleID int) (int64, error) {
+	var count int64
+	if err := utils.GetDB().Model(&model.UserRole{}).Where("role_id = ?", roleID).Count(&count).Error; err != nil {
+		log.Errorf("CountRoleUsers fail: %v", err)
+		return 0, fmt.Errorf("CountRoleUsers fail: %v", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,95 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"time"
+)
+
+// SaveSession 写入或覆盖会话
+func SaveSession(session *model.Session) error {
+	err := utils.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_name", "data", "expire_time", "modify_time"}),
+	}).Create(session).Error
+	if err != nil {
+		log.Errorf("SaveSession fail: %v", err)
+		return fmt.Errorf("SaveSession fail: %v", err)
+	}
+	return nil
+}
+
+// GetSession 获取未过期的会话
+func GetSession(tenantID int, sessionID string) (*model.Session, error) {
+	session := &model.Session{}
+	err := utils.GetDB().Model(&model.Session{}).
+		Where("tenant_id = ? AND session_id = ? AND expire_time > ?", tenantID, sessionID, time.Now()).
+		First(session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetSession fail: %v", err)
+		return nil, fmt.Errorf("GetSession fail: %v", err)
+	}
+	return session, nil
+}
+
+// RenewSession 更新会话内容和过期时间，会话已被删除时不做任何事
+func RenewSession(tenantID int, sessionID, data string, expireTime time.Time) error {
+	err := utils.GetDB().Model(&model.Session{}).
+		Where("tenant_id = ? AND session_id = ?", tenantID, sessionID).
+		Updates(map[string]interface{}{"data": data, "expire_time": expireTime}).Error
+	if err != nil {
+		log.Errorf("RenewSession fail: %v", err)
+		return fmt.Errorf("RenewSession fail: %v", err)
+	}
+	return nil
+}
+
+// DeleteSession 删除会话
+func DeleteSession(tenantID int, sessionID string) error {
+	err := utils.GetDB().Where("tenant_id = ? AND session_id = ?", tenantID, sessionID).Delete(&model.Session{}).Error
+	if err != nil {
+		log.Errorf("DeleteSession fail: %v", err)
+		return fmt.Errorf("DeleteSession fail: %v", err)
+	}
+	return nil
+}
+
+// DeleteUserSessions 删除用户名下的全部会话
+func DeleteUserSessions(tenantID int, userName string) error {
+	err := utils.GetDB().Where("tenant_id = ? AND user_name = ?", tenantID, userName).Delete(&model.Session{}).Error
+	if err != nil {
+		log.Errorf("DeleteUserSessions fail: %v", err)
+		return fmt.Errorf("DeleteUserSessions fail: %v", err)
+	}
+	return nil
+}
+
+// ListUserSessionIDs 获取用户名下未过期的会话标识
+func ListUserSessionIDs(tenantID int, userName string) ([]string, error) {
+	var ids []string
+	err := utils.GetDB().Model(&model.Session{}).
+		Where("tenant_id = ? AND user_name = ? AND expire_time > ?", tenantID, userName, time.Now()).
+		Pluck("session_id", &ids).Error
+	if err != nil {
+		log.Errorf("ListUserSessionIDs fail: %v", err)
+		return nil, fmt.Errorf("ListUserSessionIDs fail: %v", err)
+	}
+	return ids, nil
+}
+
+// DeleteExpiredSessions 删除一批过期会话，返回删除的行数
+func DeleteExpiredSessions(before time.Time, limit int) (int64, error) {
+	result := utils.GetDB().Where("expire_time <= ?", before).Limit(limit).Delete(&model.Session{})
+	if result.Error != nil {
+		log.Errorf("DeleteExpiredSessions fail: %v", result.Error)
+		return 0, fmt.Errorf("DeleteExpiredSessions fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -6,12 +6,14 @@ import (
 	"gorm.io/gorm"
 	"my_user_system/model"
 	"my_user_system/utils"
+	"strings"
+	"time"
 )
 
-// 根据姓名获取用户
-func GetUserByName(name string) (*model.User, error) {
+// 根据姓名获取租户内的用户
+func GetUserByName(tenantID int, name string) (*model.User, error) {
 	user := &model.User{}
-	if err := utils.GetDB().Model(model.User{}).Where("name=?", name).First(user).Error; err != nil {
+	if err := utils.GetDB().Model(model.User{}).Where("tenant_id=? AND name=?", tenantID, name).First(user).Error; err != nil {
 		if err.Error() == gorm.ErrRecordNotFound.Error() {
 			return nil, nil
 		}
@@ -20,8 +22,16 @@ func GetUserByName(name string) (*model.User, error) {
 	}
 	return user, nil
 }
-func CreateUser(user *model.User) error {
-	if err := utils.GetDB().Model(&model.User{}).Create(user).Error; err != nil {
+
+// CreateUser 创建用户，events 为与之同一事务写入发件箱的领域事件
+func CreateUser(user *model.User, events ...*model.OutboxEvent) error {
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Create(user).Error; err != nil {
+			return err
+		}
+		return insertOutbox(tx, events)
+	})
+	if err != nil {
 		log.Errorf("CreateUser Fail: %v", err)
 		return fmt.Errorf("CreateUser fail: %v", err)
 	}
@@ -29,7 +39,114 @@ func CreateUser(user *model.User) error {
 	return nil
 }
 
-// UpdateUserInfo 更新昵称
-func UpdateUserInfo(userName string, user *model.User) int64 {
-	return utils.GetDB().Model(&model.User{}).Where("`name` = ?", userName).Updates(user).RowsAffected
+// UpdateUserInfo 更新昵称，有记录被更新时同一事务写入 events
+func UpdateUserInfo(tenantID int, userName string, user *model.User, events ...*model.OutboxEvent) (int64, error) {
+	var affected int64
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("tenant_id = ? AND `name` = ?", tenantID, userName).Updates(user)
+		if result.Error != nil {
+			return result.Error
+		}
+		if affected = result.RowsAffected; affected == 0 {
+			return nil
+		}
+		return insertOutbox(tx, events)
+	})
+	if err != nil {
+		log.Errorf("UpdateUserInfo fail: %v", err)
+		return 0, fmt.Errorf("UpdateUserInfo fail: %v", err)
+	}
+	return affected, nil
+}
+
+// UserFilter 管理后台查询用户的过滤条件
+type UserFilter struct {
+	TenantID      int       // 所属租户
+	Cursor        int       // 上一页最后一条记录的ID
+	Limit         int       // 每页条数
+	NamePrefix    string    // 用户名前缀
+	Gender        string    // 性别
+	MinAge        int       // 最小年龄
+	MaxAge        int       // 最大年龄
+	CreatedAfter  time.Time // 创建时间下限
+	CreatedBefore time.Time // 创建时间上限
+}
+
+// ListUsers 按ID升序游标分页查询用户
+func ListUsers(filter *UserFilter) ([]*model.User, error) {
+	query := utils.GetDB().Model(&model.User{}).Where("tenant_id = ? AND id > ?", filter.TenantID, filter.Cursor)
+	if filter.NamePrefix != "" {
+		query = query.Where("name LIKE ?", escapeLike(filter.NamePrefix)+"%")
+	}
+	if filter.Gender != "" {
+		query = query.Where("gender = ?", filter.Gender)
+	}
+	if filter.MinAge > 0 {
+		query = query.Where("age >= ?", filter.MinAge)
+	}
+	if filter.MaxAge > 0 {
+		query = query.Where("age <= ?", filter.MaxAge)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("create_time >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("create_time < ?", filter.CreatedBefore)
+	}
+
+	var users []*model.User
+	if err := query.Order("id").Limit(filter.Limit).Find(&users).Error; err != nil {
+		log.Errorf("ListUsers fail: %v", err)
+		return nil, fmt.Errorf("ListUsers fail: %v", err)
+	}
+	return users, nil
+}
+
+// UpdateUserFields 按字段更新用户，可以写入零值，有记录被更新时同一事务写入 events
+func UpdateUserFields(tenantID int, userName string, fields map[string]interface{}, events ...*model.OutboxEvent) (int64, error) {
+	var affected int64
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.User{}).Where("tenant_id = ? AND `name` = ?", tenantID, userName).Updates(fields)
+		if res.Error != nil {
+			return res.Error
+		}
+		if affected = res.RowsAffected; affected == 0 {
+			return nil
+		}
+		return insertOutbox(tx, events)
+	})
+	if err != nil {
+		log.Errorf("UpdateUserFields fail: %v", err)
+		return 0, fmt.Errorf("UpdateUserFields fail: %v", err)
+	}
+	return affected, nil
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DeleteUser 删除用户及其角色、组织成员关联和密码历史，events 同一事务写入发件箱
+func DeleteUser(user *model.User, events ...*model.OutboxEvent) error {
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.OrgMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", user.ID).Delete(&model.User{}).Error; err != nil {
+			return err
+		}
+		return insertOutbox(tx, events)
+	})
+	if err != nil {
+		log.Errorf("DeleteUser fail: %v", err)
+		return fmt.Errorf("DeleteUser fail: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,196 @@
+package dao
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"time"
+)
+
+// CreateWebhookSubscription 创建 Webhook 订阅
+func CreateWebhookSubscription(sub *model.WebhookSubscription) error {
+	if err := utils.GetDB().Model(&model.WebhookSubscription{}).Create(sub).Error; err != nil {
+		log.Errorf("CreateWebhookSubscription fail: %v", err)
+		return fmt.Errorf("CreateWebhookSubscription fail: %v", err)
+	}
+	return nil
+}
+
+// GetWebhookSubscription 获取租户下的 Webhook 订阅，不存在时返回 nil
+func GetWebhookSubscription(tenantID, id int) (*model.WebhookSubscription, error) {
+	sub := &model.WebhookSubscription{}
+	err := utils.GetDB().Model(&model.WebhookSubscription{}).
+		Where("tenant_id = ? AND id = ?", tenantID, id).First(sub).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetWebhookSubscription fail: %v", err)
+		return nil, fmt.Errorf("GetWebhookSubscription fail: %v", err)
+	}
+	return sub, nil
+}
+
+// ListWebhookSubscriptions 获取租户下的全部 Webhook 订阅，onlyEnabled 为 true 时只返回启用的订阅
+func ListWebhookSubscriptions(tenantID int, onlyEnabled bool) ([]*model.WebhookSubscription, error) {
+	var subs []*model.WebhookSubscription
+	query := utils.GetDB().Model(&model.WebhookSubscription{}).Where("tenant_id = ?", tenantID)
+	if onlyEnabled {
+		query = query.Where("enabled = ?", true)
+	}
+	if err := query.Order("id").Find(&subs).Error; err != nil {
+		log.Errorf("ListWebhookSubscriptions fail: %v", err)
+		return nil, fmt.Errorf("ListWebhookSubscriptions fail: %v", err)
+	}
+	return subs, nil
+}
+
+// UpdateWebhookSubscription 修改 Webhook 订阅，返回受影响的行数
+func UpdateWebhookSubscription(tenantID, id int, fields map[string]interface{}) (int64, error) {
+	result := utils.GetDB().Model(&model.WebhookSubscription{}).
+		Where("tenant_id = ? AND id = ?", tenantID, id).Updates(fields)
+	if result.Error != nil {
+		log.Errorf("UpdateWebhookSubscription fail: %v", result.Error)
+		return 0, fmt.Errorf("UpdateWebhookSubscription fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteWebhookSubscription 删除 Webhook 订阅以及还未投递成功的记录，已有的投递日志保留，返回删除的订阅条数
+func DeleteWebhookSubscription(tenantID, id int) (int64, error) {
+	var deleted int64
+	err := utils.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&model.WebhookSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if deleted == 0 {
+			return nil
+		}
+		return tx.Where("subscription_id = ? AND status = ?", id, model.WebhookStatusPending).
+			Delete(&model.WebhookDelivery{}).Error
+	})
+	if err != nil {
+		log.Errorf("DeleteWebhookSubscription fail: %v", err)
+		return 0, fmt.Errorf("DeleteWebhookSubscription fail: %v", err)
+	}
+	return deleted, nil
+}
+
+// CreateWebhookDeliveries 写入待投递记录，同一事件对同一订阅已有记录时跳过，事件重复投递时不会重复回调
+func CreateWebhookDeliveries(deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	err := utils.GetDB().Model(&model.WebhookDelivery{}).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(deliveries).Error
+	if err != nil {
+		log.Errorf("CreateWebhookDeliveries fail: %v", err)
+		return fmt.Errorf("CreateWebhookDeliveries fail: %v", err)
+	}
+	return nil
+}
+
+// GetWebhookDelivery 获取租户下的投递记录，不存在时返回 nil
+func GetWebhookDelivery(tenantID, id int) (*model.WebhookDelivery, error) {
+	delivery := &model.WebhookDelivery{}
+	err := utils.GetDB().Model(&model.WebhookDelivery{}).
+		Where("tenant_id = ? AND id = ?", tenantID, id).First(delivery).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		log.Errorf("GetWebhookDelivery fail: %v", err)
+		return nil, fmt.Errorf("GetWebhookDelivery fail: %v", err)
+	}
+	return delivery, nil
+}
+
+// WebhookDeliveryFilter 投递日志查询条件，空值表示不过滤
+type WebhookDeliveryFilter struct {
+	TenantID       int
+	SubscriptionID int
+	Status         string
+	EventID        string
+	Cursor         int // 上一页最后一条的ID，按ID倒序分页
+	Limit          int
+}
+
+// ListWebhookDeliveries 按条件查询投递日志，最新的在前
+func ListWebhookDeliveries(filter *WebhookDeliveryFilter) ([]*model.WebhookDelivery, error) {
+	query := utils.GetDB().Model(&model.WebhookDelivery{}).Where("tenant_id = ?", filter.TenantID)
+	if filter.SubscriptionID > 0 {
+		query = query.Where("subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EventID != "" {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+	if filter.Cursor > 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	}
+	var deliveries []*model.WebhookDelivery
+	if err := query.Order("id desc").Limit(filter.Limit).Find(&deliveries).Error; err != nil {
+		log.Errorf("ListWebhookDeliveries fail: %v", err)
+		return nil, fmt.Errorf("ListWebhookDeliveries fail: %v", err)
+	}
+	return deliveries, nil
+}
+
+// ListPendingWebhookDeliveries 获取到了投递时间的待投递记录，按写入顺序返回
+func ListPendingWebhookDeliveries(now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := utils.GetDB().Model(&model.WebhookDelivery{}).
+		Where("status = ? AND next_attempt_time <= ?", model.WebhookStatusPending, now).
+		Order("id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		log.Errorf("ListPendingWebhookDeliveries fail: %v", err)
+		return nil, fmt.Errorf("ListPendingWebhookDeliveries fail: %v", err)
+	}
+	return deliveries, nil
+}
+
+// ClaimWebhookDelivery 抢占投递权，把下一次投递时间推后到 lease，条件更新保证多个实例中只有一个能抢到
+func ClaimWebhookDelivery(id int, now, lease time.Time) (bool, error) {
+	result := utils.GetDB().Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_time <= ?", id, model.WebhookStatusPending, now).
+		Update("next_attempt_time", lease)
+	if result.Error != nil {
+		log.Errorf("ClaimWebhookDelivery fail: %v", result.Error)
+		return false, fmt.Errorf("ClaimWebhookDelivery fail: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveWebhookAttempt 记录一次投递的结果
+func SaveWebhookAttempt(id int, fields map[string]interface{}) error {
+	err := utils.GetDB().Model(&model.WebhookDelivery{}).Where("id = ?", id).Updates(fields).Error
+	if err != nil {
+		log.Errorf("SaveWebhookAttempt fail: %v", err)
+		return fmt.Errorf("SaveWebhookAttempt fail: %v", err)
+	}
+	return nil
+}
+
+// ResetWebhookDelivery 把投递记录重新置为待投递并清零重试次数，立即可以投递，返回受影响的行数。
+// 记录正在投递时会再投递一次，接收方按 event_id 去重
+func ResetWebhookDelivery(tenantID, id int, now time.Time) (int64, error) {
+	result := utils.GetDB().Model(&model.WebhookDelivery{}).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		Updates(map[string]interface{}{
+			"status":            model.WebhookStatusPending,
+			"attempts":          0,
+			"next_attempt_time": now,
+		})
+	if result.Error != nil {
+		log.Errorf("ResetWebhookDelivery fail: %v", result.Error)
+		return 0, fmt.Errorf("ResetWebhookDelivery fail: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
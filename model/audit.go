@@ -0,0 +1,83 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// 审计动作
+const (
+	AuditImpersonateStart = "impersonate.start" // 开始模拟登录
+	AuditImpersonateStop  = "impersonate.stop"  // 结束模拟登录
+	AuditUserRegister     = "user.register"     // 注册
+	AuditUserUpdate       = "user.update"       // 用户修改自己的资料
+	AuditUserPassword     = "user.password"     // 修改密码
+	AuditUserDelete       = "user.delete"       // 注销账号
+	AuditAdminUserUpdate  = "admin.user.update" // 管理员修改用户资料
+	AuditAdminUserStatus  = "admin.user.status" // 管理员禁用或启用账号
+	AuditAdminPwdReset    = "admin.user.reset"  // 管理员要求重置密码
+	AuditRoleCreate       = "role.create"       // 创建角色
+	AuditRoleGrant        = "role.grant"        // 给角色授权
+	AuditRoleRevoke       = "role.revoke"       // 收回角色权限
+	AuditRoleAssign       = "role.assign"       // 给用户分配角色
+	AuditRoleUnassign     = "role.unassign"     // 取消用户的角色
+	AuditOrgCreate        = "org.create"        // 创建组织
+	AuditOrgMemberRole    = "org.member_role"   // 设置组织成员角色
+	AuditSessionLogout    = "session.logout"    // 登出
+	AuditSessionRevoke    = "session.revoke"    // 踢掉用户的全部会话
+	AuditTenantFlush      = "tenant.flush"      // 清理租户缓存
+	AuditInviteCreate     = "invite.create"     // 生成邀请码
+	AuditInviteRevoke     = "invite.revoke"     // 撤销邀请码
+	AuditWebhookCreate    = "webhook.create"    // 创建 Webhook 订阅
+	AuditWebhookUpdate    = "webhook.update"    // 修改 Webhook 订阅
+	AuditWebhookDelete    = "webhook.delete"    // 删除 Webhook 订阅
+	AuditWebhookRedeliver = "webhook.redeliver" // 手动重新投递
+
+	AuditOAuthClientCreate = "oauth.client.create" // 注册 OAuth 客户端
+	AuditOAuthClientUpdate = "oauth.client.update" // 修改 OAuth 客户端
+	AuditOAuthConsent      = "oauth.consent"       // 用户授权应用
+)
+
+// AuditLog 审计日志，只追加不修改。同一租户的日志按写入顺序组成哈希链，
+// 每条记录的哈希包含上一条的哈希，修改或删除中间的记录都会导致之后的校验失败
+type AuditLog struct {
+	ID         int       `gorm:"column:id"`                                           // ID
+	TenantID   int       `gorm:"column:tenant_id;not null;default:0;index"`           // 所属租户
+	Actor      string    `gorm:"column:actor;type:varchar(100);index"`                // 操作人
+	Target     string    `gorm:"column:target;type:varchar(100);index"`               // 操作对象
+	Action     string    `gorm:"column:action;type:varchar(64)"`                      // 动作
+	Detail     string    `gorm:"column:detail;type:varchar(1024);default:''"`         // 详情
+	Diff       string    `gorm:"column:diff;type:text"`                               // 变更字段修改前后的值，JSON 格式
+	RequestID  string    `gorm:"column:request_id;type:varchar(64);default:'';index"` // 请求ID
+	IP         string    `gorm:"column:ip;type:varchar(64);default:''"`               // 来源IP
+	PrevHash   string    `gorm:"column:prev_hash;type:varchar(64);default:''"`        // 同一租户上一条记录的哈希
+	Hash       string    `gorm:"column:hash;type:varchar(64);default:''"`             // 本条记录的哈希，早于哈希链上线的记录为空
+	CreateTime time.Time `gorm:"autoCreateTime"`                                      // 记录时间
+}
+
+// TableName 表名
+func (t *AuditLog) TableName() string {
+	return "t_audit_log"
+}
+
+// ComputeHash 计算记录的哈希，时间只取到秒，与数据库中保存的精度一致
+func (t *AuditLog) ComputeHash() string {
+	content := fmt.Sprintf("%s|%d|%s|%s|%s|%s|%s|%s|%s|%d", t.PrevHash, t.TenantID, t.Actor, t.Target,
+		t.Action, t.Detail, t.Diff, t.RequestID, t.IP, t.CreateTime.Unix())
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditChain 每个租户审计日志哈希链的链尾，写入审计日志时加锁，保证并发写入时链不分叉
+type AuditChain struct {
+	TenantID   int       `gorm:"column:tenant_id;primaryKey;autoIncrement:false"` // 租户
+	LastHash   string    `gorm:"column:last_hash;type:varchar(64);default:''"`    // 最后一条记录的哈希
+	ModifyTime time.Time `gorm:"autoUpdateTime"`                                  // 最后写入时间
+}
+
+// TableName 表名
+func (t *AuditChain) TableName() string {
+	return "t_audit_chain"
+}
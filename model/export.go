@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// 导出任务状态
+const (
+	ExportStatusPending    = "pending"    // 等待执行
+	ExportStatusRunning    = "running"    // 执行中
+	ExportStatusDone       = "done"       // 已完成，可下载
+	ExportStatusFailed     = "failed"     // 执行失败
+	ExportStatusDownloaded = "downloaded" // 已下载，下载链接失效
+	ExportStatusExpired    = "expired"    // 下载链接已过期
+)
+
+// ExportJob 个人数据导出任务
+type ExportJob struct {
+	CreateModel
+	ModifyModel
+	ID            int       `gorm:"column:id"`                                    // ID
+	TenantID      int       `gorm:"column:tenant_id;not null;default:0"`          // 所属租户
+	UserName      string    `gorm:"column:user_name;type:varchar(100);index"`     // 申请导出的用户
+	Status        string    `gorm:"column:status;type:varchar(20)"`               // 任务状态
+	FilePath      string    `gorm:"column:file_path;type:varchar(255)"`           // 导出包路径
+	DownloadToken string    `gorm:"column:download_token;type:varchar(64);index"` // 一次性下载凭证
+	ExpireTime    time.Time `gorm:"column:expire_time"`                           // 下载链接过期时间
+	ErrMsg        string    `gorm:"column:err_msg;type:varchar(255)"`             // 失败原因
+}
+
+// TableName 表名
+func (t *ExportJob) TableName() string {
+	return "t_export_job"
+}
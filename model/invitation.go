@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Invitation 邀请码，MaxUses 次用完、过期或被撤销后失效
+type Invitation struct {
+	CreateModel
+	ModifyModel
+	ID         int        `gorm:"column:id"`                                  // ID
+	TenantID   int        `gorm:"column:tenant_id;not null;default:0;index"`  // 所属租户
+	Code       string     `gorm:"column:code;type:varchar(64);uniqueIndex"`   // 邀请码
+	Inviter    string     `gorm:"column:inviter;type:varchar(100)"`           // 邀请人
+	MaxUses    int        `gorm:"column:max_uses;not null;default:1"`         // 最大使用次数
+	UsedCount  int        `gorm:"column:used_count;not null;default:0"`       // 已使用次数
+	ExpireTime *time.Time `gorm:"column:expire_time"`                         // 过期时间，为空表示不过期
+	Roles      string     `gorm:"column:roles;type:varchar(512);default:''"`  // 注册后自动分配的角色，逗号分隔
+	Revoked    bool       `gorm:"column:revoked;not null;default:false"`      // 是否已撤销
+	Remark     string     `gorm:"column:remark;type:varchar(255);default:''"` // 备注
+}
+
+// TableName 表名
+func (t *Invitation) TableName() string {
+	return "t_invitation"
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// 登录结果，成功为 success，其余为失败原因
+const (
+	LoginResultSuccess       = "success"            // 登录成功
+	LoginResultChallenge     = "challenge_failed"   // 人机校验未通过
+	LoginResultUserNotFound  = "user_not_found"     // 用户不存在
+	LoginResultWrongPassword = "wrong_password"     // 密码错误
+	LoginResultDisabled      = "disabled"           // 账号已被禁用
+	LoginResultPwdReset      = "pwd_reset_required" // 需要先修改密码
+	LoginResultError         = "internal_error"     // 服务内部错误
+)
+
+// LoginHistory 登录记录，每次登录尝试都会记录，只追加不修改
+type LoginHistory struct {
+	ID        int    `gorm:"column:id"`                                                                  // ID
+	TenantID  int    `gorm:"column:tenant_id;not null;default:0;index:idx_login_user_device,priority:1"` // 所属租户
+	UserName  string `gorm:"column:user_name;type:varchar(100);index:idx_login_user_device,priority:2"`  // 登录的用户名，用户不存在时也记录
+	Success   bool   `gorm:"column:success;not null;default:false"`                                      // 是否登录成功
+	Result    string `gorm:"column:result;type:varchar(32)"`                                             // 登录结果，见 LoginResultXXX
+	IP        string `gorm:"column:ip;type:varchar(64);default:''"`                                      // 来源IP
+	UserAgent string `gorm:"column:user_agent;type:varchar(512);default:''"`                             // 浏览器 User-Agent
+	// Device 设备指纹，同一浏览器多次登录的指纹相同
+	Device     string    `gorm:"column:device;type:varchar(64);default:'';index:idx_login_user_device,priority:3"`
+	NewDevice  bool      `gorm:"column:new_device;not null;default:false"` // 是否为首次出现的设备，只对登录成功的记录有意义
+	CreateTime time.Time `gorm:"autoCreateTime"`                           // 登录时间
+}
+
+// TableName 表名
+func (t *LoginHistory) TableName() string {
+	return "t_login_history"
+}
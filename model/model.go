@@ -18,14 +18,26 @@ type ModifyModel struct {
 type User struct {
 	CreateModel
 	ModifyModel
-	ID       int    `gorm:"column:id"`       // ID
-	Name     string `gorm:"column:name"`     // 姓名
-	Gender   string `gorm:"column:gender"`   //性别
-	Age      int    `gorm:"column:age"`      //年龄
-	PassWord string `gorm:"column:password"` //密码
-	NickName string `gorm:"column:nickname"` //昵称
+	ID       int    `gorm:"column:id"`                           // ID
+	TenantID int    `gorm:"column:tenant_id;not null;default:0"` // 所属租户，用户名在租户内唯一
+	Name     string `gorm:"column:name"`                         // 姓名
+	Gender   string `gorm:"column:gender"`                       //性别
+	Age      int    `gorm:"column:age"`                          //年龄
+	PassWord string `gorm:"column:password"`                     //密码
+	NickName string `gorm:"column:nickname"`                     //昵称
+	Status   int    `gorm:"column:status;not null;default:0"`    // 账号状态，见 UserStatusXXX
+	// PwdResetRequired 管理员要求重置密码，修改密码前无法登录
+	PwdResetRequired bool `gorm:"column:pwd_reset_required;not null;default:false"`
+	// Inviter 通过邀请码注册时记录邀请人
+	Inviter string `gorm:"column:inviter;type:varchar(100);not null;default:''"`
 }
 
+// 账号状态
+const (
+	UserStatusNormal   = 0 // 正常
+	UserStatusDisabled = 1 // 已禁用
+)
+
 // TableName 表名
 func (t *User) TableName() string {
 	return "t_user"
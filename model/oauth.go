@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+// OAuth 授权方式
+const (
+	OAuthGrantAuthorizationCode = "authorization_code" // 授权码，必须带 PKCE
+	OAuthGrantRefreshToken      = "refresh_token"      // 刷新令牌
+	OAuthGrantClientCredentials = "client_credentials" // 客户端凭证，只能由机密客户端使用
+)
+
+// OAuthClient 注册到授权服务器的第三方应用
+type OAuthClient struct {
+	CreateModel
+	ModifyModel
+	ID           int    `gorm:"column:id"`                                       // ID
+	TenantID     int    `gorm:"column:tenant_id;not null;default:0;index"`       // 所属租户
+	ClientID     string `gorm:"column:client_id;type:varchar(64);uniqueIndex"`   // 客户端标识
+	SecretHash   string `gorm:"column:secret_hash;type:varchar(64);default:''"`  // 客户端密钥的 SHA-256，公开客户端为空
+	Name         string `gorm:"column:name;type:varchar(100)"`                   // 应用名，展示在授权确认页
+	RedirectURIs string `gorm:"column:redirect_uris;type:text"`                  // 允许的回调地址，空格分隔，授权请求中的地址必须与其中之一完全一致
+	Scopes       string `gorm:"column:scopes;type:varchar(512)"`                 // 允许申请的 scope，空格分隔
+	GrantTypes   string `gorm:"column:grant_types;type:varchar(255)"`            // 允许的授权方式，空格分隔，见 OAuthGrantXXX
+	Public       bool   `gorm:"column:public;not null;default:false"`            // 公开客户端，如单页应用和移动端，没有密钥
+	Enabled      bool   `gorm:"column:enabled;not null;default:true"`            // 是否启用，停用后不能再获取和刷新令牌
+	Description  string `gorm:"column:description;type:varchar(255);default:''"` // 备注
+}
+
+// TableName 表名
+func (t *OAuthClient) TableName() string {
+	return "t_oauth_client"
+}
+
+// OAuthConsent 用户对应用的授权记录，再次申请的 scope 不超出已授权范围时不再展示确认页
+type OAuthConsent struct {
+	ID         int       `gorm:"column:id"`                                                                 // ID
+	TenantID   int       `gorm:"column:tenant_id;not null;default:0"`                                       // 所属租户
+	UserID     int       `gorm:"column:user_id;uniqueIndex:uk_oauth_consent,priority:1"`                    // 用户ID
+	ClientID   string    `gorm:"column:client_id;type:varchar(64);uniqueIndex:uk_oauth_consent,priority:2"` // 客户端标识
+	Scope      string    `gorm:"column:scope;type:varchar(512)"`                                            // 已授权的 scope，空格分隔
+	CreateTime time.Time `gorm:"autoCreateTime"`                                                            // 第一次授权的时间
+	ModifyTime time.Time `gorm:"autoUpdateTime"`                                                            // 最近一次授权的时间
+}
+
+// TableName 表名
+func (t *OAuthConsent) TableName() string {
+	return "t_oauth_consent"
+}
+
+// OAuthRefreshToken 刷新令牌，只保存哈希。每次刷新都会轮换，同一次授权得到的令牌属于同一个 GrantID，
+// 已轮换的令牌再次使用说明令牌可能泄露，整个 GrantID 下的令牌都会被吊销
+type OAuthRefreshToken struct {
+	ID          int        `gorm:"column:id"`                                      // ID
+	TenantID    int        `gorm:"column:tenant_id;not null;default:0"`            // 所属租户
+	TokenHash   string     `gorm:"column:token_hash;type:varchar(64);uniqueIndex"` // 令牌的 SHA-256
+	GrantID     string     `gorm:"column:grant_id;type:varchar(64);index"`         // 授权标识，轮换后不变
+	ClientID    string     `gorm:"column:client_id;type:varchar(64)"`              // 客户端标识
+	UserID      int        `gorm:"column:user_id;index"`                           // 用户ID
+	UserName    string     `gorm:"column:user_name;type:varchar(100)"`             // 用户名
+	Scope       string     `gorm:"column:scope;type:varchar(512)"`                 // 授权的 scope，空格分隔
+	ExpireTime  time.Time  `gorm:"column:expire_time"`                             // 过期时间
+	RevokedTime *time.Time `gorm:"column:revoked_time"`                            // 轮换或吊销的时间，为空表示有效
+	CreateTime  time.Time  `gorm:"autoCreateTime"`                                 // 签发时间
+}
+
+// TableName 表名
+func (t *OAuthRefreshToken) TableName() string {
+	return "t_oauth_refresh_token"
+}
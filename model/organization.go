@@ -0,0 +1,41 @@
+package model
+
+// 成员角色
+const (
+	MemberRoleOwner  = "owner"  // 所有者
+	MemberRoleAdmin  = "admin"  // 管理员
+	MemberRoleMember = "member" // 普通成员
+)
+
+// DefaultTenantCode 默认租户，未指定租户的请求以及存量用户都归属于它
+const DefaultTenantCode = "default"
+
+// Organization 组织，即租户
+type Organization struct {
+	CreateModel
+	ModifyModel
+	ID   int    `gorm:"column:id"`                                      // ID
+	Code string `gorm:"column:code;type:varchar(64);uniqueIndex"`       // 租户标识，用于请求头和路径
+	Name string `gorm:"column:name;type:varchar(100)"`                  // 名称
+	Host string `gorm:"column:host;type:varchar(255);index;default:''"` // 绑定的域名，可为空
+}
+
+// TableName 表名
+func (t *Organization) TableName() string {
+	return "t_organization"
+}
+
+// OrgMember 组织成员
+type OrgMember struct {
+	CreateModel
+	ModifyModel
+	ID     int    `gorm:"column:id"`                              // ID
+	OrgID  int    `gorm:"column:org_id;uniqueIndex:uk_org_user"`  // 组织ID
+	UserID int    `gorm:"column:user_id;uniqueIndex:uk_org_user"` // 用户ID
+	Role   string `gorm:"column:role;type:varchar(20)"`           // 成员角色，见 MemberRoleXXX
+}
+
+// TableName 表名
+func (t *OrgMember) TableName() string {
+	return "t_org_member"
+}
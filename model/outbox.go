@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// 领域事件类型
+const (
+	EventUserRegistered = "user.registered" // 用户注册
+	EventUserUpdated    = "user.updated"    // 用户资料或状态变更
+	EventUserDeleted    = "user.deleted"    // 用户注销
+	EventSessionCreated = "session.created" // 登录或开始模拟登录
+	EventSessionRevoked = "session.revoked" // 登出、被踢下线或结束模拟登录
+)
+
+// OutboxEvent 发件箱中待投递的领域事件。与业务数据在同一事务中写入，由后台任务投递，投递成功前会一直重试
+type OutboxEvent struct {
+	ID        int    `gorm:"column:id"`                                                              // ID
+	EventID   string `gorm:"column:event_id;type:varchar(64);uniqueIndex"`                           // 事件唯一标识，消费方据此去重
+	TenantID  int    `gorm:"column:tenant_id;not null;default:0"`                                    // 所属租户
+	EventType string `gorm:"column:event_type;type:varchar(64)"`                                     // 事件类型，见 EventXXX
+	Subject   string `gorm:"column:subject;type:varchar(100)"`                                       // 事件主体，一般为用户名
+	Payload   string `gorm:"column:payload;type:text"`                                               // 事件内容，JSON 格式
+	Sent      bool   `gorm:"column:sent;not null;default:false;index:idx_outbox_pending,priority:1"` // 是否已投递
+	Attempts  int    `gorm:"column:attempts;not null;default:0"`                                     // 投递次数
+	LastError string `gorm:"column:last_error;type:varchar(512);default:''"`                         // 最近一次投递失败的原因
+	// NextAttemptTime 下一次可以投递的时间，投递中的事件会被推后一段时间，避免多个实例重复投递
+	NextAttemptTime time.Time  `gorm:"column:next_attempt_time;index:idx_outbox_pending,priority:2"`
+	SentTime        *time.Time `gorm:"column:sent_time"` // 投递成功的时间
+	CreateTime      time.Time  `gorm:"autoCreateTime"`   // 事件发生时间
+}
+
+// TableName 表名
+func (t *OutboxEvent) TableName() string {
+	return "t_outbox"
+}
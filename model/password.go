@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// PasswordHistory 用户使用过的密码哈希，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID         int       `gorm:"column:id"`                              // ID
+	TenantID   int       `gorm:"column:tenant_id;not null;default:0"`    // 所属租户
+	UserID     int       `gorm:"column:user_id;not null;index"`          // 用户 ID
+	Hash       string    `gorm:"column:hash;type:varchar(100);not null"` // 密码哈希
+	CreateTime time.Time `gorm:"column:create_time;autoCreateTime"`      // 设置时间
+}
+
+// TableName 表名
+func (t *PasswordHistory) TableName() string {
+	return "t_password_history"
+}
@@ -0,0 +1,55 @@
+package model
+
+// Role 角色，按租户隔离，不同租户可以有同名角色
+type Role struct {
+	CreateModel
+	ModifyModel
+	ID          int    `gorm:"column:id"`                                                                 // ID
+	TenantID    int    `gorm:"column:tenant_id;not null;default:0;uniqueIndex:uk_tenant_role,priority:1"` // 所属租户
+	Name        string `gorm:"column:name;type:varchar(64);uniqueIndex:uk_tenant_role,priority:2"`        // 角色名
+	Description string `gorm:"column:description;type:varchar(255);default:''"`                           // 描述
+}
+
+// TableName 表名
+func (t *Role) TableName() string {
+	return "t_role"
+}
+
+// Permission 权限点，Code 形如 users:read。权限点是代码内置的全局目录，各租户通过自己的角色授予
+type Permission struct {
+	CreateModel
+	ID          int    `gorm:"column:id"`                                       // ID
+	Code        string `gorm:"column:code;type:varchar(64);uniqueIndex"`        // 权限码
+	Description string `gorm:"column:description;type:varchar(255);default:''"` // 描述
+}
+
+// TableName 表名
+func (t *Permission) TableName() string {
+	return "t_permission"
+}
+
+// RolePermission 角色与权限的关联
+type RolePermission struct {
+	CreateModel
+	ID           int `gorm:"column:id"`                                           // ID
+	RoleID       int `gorm:"column:role_id;uniqueIndex:uk_role_permission"`       // 角色ID
+	PermissionID int `gorm:"column:permission_id;uniqueIndex:uk_role_permission"` // 权限ID
+}
+
+// TableName 表名
+func (t *RolePermission) TableName() string {
+	return "t_role_permission"
+}
+
+// UserRole 用户与角色的关联
+type UserRole struct {
+	CreateModel
+	ID     int `gorm:"column:id"`                               // ID
+	UserID int `gorm:"column:user_id;uniqueIndex:uk_user_role"` // 用户ID
+	RoleID int `gorm:"column:role_id;uniqueIndex:uk_user_role"` // 角色ID
+}
+
+// TableName 表名
+func (t *UserRole) TableName() string {
+	return "t_user_role"
+}
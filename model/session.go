@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Session 数据库中的会话副本，Redis 不可用时作为备用会话存储
+type Session struct {
+	ID         int       `gorm:"column:id"`                                                  // ID
+	TenantID   int       `gorm:"column:tenant_id;not null;default:0;uniqueIndex:uk_session"` // 所属租户
+	SessionID  string    `gorm:"column:session_id;type:varchar(64);uniqueIndex:uk_session"`  // 会话标识
+	UserName   string    `gorm:"column:user_name;type:varchar(100);index"`                   // 用户名
+	Data       string    `gorm:"column:data;type:text"`                                      // 会话中的用户信息，JSON 格式
+	ExpireTime time.Time `gorm:"column:expire_time;index"`                                   // 过期时间
+	CreateTime time.Time `gorm:"autoCreateTime"`                                             // 创建时间
+	ModifyTime time.Time `gorm:"autoUpdateTime"`                                             // 修改时间
+}
+
+// TableName 表名
+func (t *Session) TableName() string {
+	return "t_session"
+}
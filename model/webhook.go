@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// WebhookSubscription 租户配置的 Webhook 订阅，匹配的领域事件会回调 URL
+type WebhookSubscription struct {
+	CreateModel
+	ModifyModel
+	ID          int    `gorm:"column:id"`                                       // ID
+	TenantID    int    `gorm:"column:tenant_id;not null;default:0;index"`       // 所属租户
+	URL         string `gorm:"column:url;type:varchar(512)"`                    // 回调地址
+	Events      string `gorm:"column:events;type:varchar(512)"`                 // 订阅的事件，逗号分隔，支持 * 和 user.* 形式的前缀
+	Secret      string `gorm:"column:secret;type:varchar(128)"`                 // 签名密钥
+	Enabled     bool   `gorm:"column:enabled;not null;default:true"`            // 是否启用
+	Description string `gorm:"column:description;type:varchar(255);default:''"` // 备注
+}
+
+// TableName 表名
+func (t *WebhookSubscription) TableName() string {
+	return "t_webhook_subscription"
+}
+
+// Webhook 投递状态
+const (
+	WebhookStatusPending   = "pending"   // 等待投递或等待重试
+	WebhookStatusSucceeded = "succeeded" // 投递成功
+	WebhookStatusDead      = "dead"      // 重试次数用完，需要人工重新投递
+)
+
+// WebhookDelivery 一个事件对一个订阅的投递记录，同时作为投递日志
+type WebhookDelivery struct {
+	ID             int    `gorm:"column:id"`                                                                // ID
+	TenantID       int    `gorm:"column:tenant_id;not null;default:0"`                                      // 所属租户
+	SubscriptionID int    `gorm:"column:subscription_id;uniqueIndex:uk_webhook_event,priority:1"`           // 订阅ID
+	EventID        string `gorm:"column:event_id;type:varchar(64);uniqueIndex:uk_webhook_event,priority:2"` // 领域事件ID，同一事件对同一订阅只投递一条
+	EventType      string `gorm:"column:event_type;type:varchar(64)"`                                       // 事件类型
+	Payload        string `gorm:"column:payload;type:text"`                                                 // 回调的请求体
+	Status         string `gorm:"column:status;type:varchar(16);index:idx_webhook_pending,priority:1"`      // 投递状态，见 WebhookStatusXXX
+	Attempts       int    `gorm:"column:attempts;not null;default:0"`                                       // 已投递次数
+	ResponseCode   int    `gorm:"column:response_code;not null;default:0"`                                  // 最近一次投递的 HTTP 状态码，请求失败时为 0
+	ResponseBody   string `gorm:"column:response_body;type:varchar(512);default:''"`                        // 最近一次投递的响应内容，截断保存
+	LastError      string `gorm:"column:last_error;type:varchar(512);default:''"`                           // 最近一次投递失败的原因
+	// NextAttemptTime 下一次可以投递的时间，投递中的记录会被推后一段时间，避免多个实例重复投递
+	NextAttemptTime time.Time  `gorm:"column:next_attempt_time;index:idx_webhook_pending,priority:2"`
+	DeliveredTime   *time.Time `gorm:"column:delivered_time"` // 投递成功的时间
+	CreateTime      time.Time  `gorm:"autoCreateTime"`        // 创建时间
+	ModifyTime      time.Time  `gorm:"autoUpdateTime"`        // 最近一次投递的时间
+}
+
+// TableName 表名
+func (t *WebhookDelivery) TableName() string {
+	return "t_webhook_delivery"
+}
@@ -0,0 +1,226 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	defaultCorsMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	defaultCorsHeaders = []string{"Content-Type"}
+)
+
+// corsPolicy 一组路径生效的跨域策略
+type corsPolicy struct {
+	disabled    bool
+	origins     []string
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// corsRoute 按路径前缀覆盖的跨域策略
+type corsRoute struct {
+	prefix string
+	policy *corsPolicy
+}
+
+// newCorsPolicy 根据配置构造跨域策略，租户请求头和 CSRF 请求头自动加入允许的请求头
+func newCorsPolicy(origins, methods, headers, expose []string, credentials bool, maxAge int) *corsPolicy {
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+	if len(headers) == 0 {
+		headers = defaultCorsHeaders
+	}
+	globalConf := conf.GetGlobalConfig()
+	for _, header := range []string{globalConf.Tenant.Header, globalConf.CSRF.HeaderName} {
+		if header != "" && !containsFold(headers, header) {
+			headers = append(append([]string{}, headers...), header)
+		}
+	}
+	policy := &corsPolicy{
+		origins:     origins,
+		methods:     strings.Join(methods, ", "),
+		headers:     strings.Join(headers, ", "),
+		expose:      strings.Join(expose, ", "),
+		credentials: credentials,
+	}
+	if maxAge > 0 {
+		policy.maxAge = strconv.Itoa(maxAge)
+	}
+	return policy
+}
+
+// buildCorsPolicies 构造全局策略和按路径覆盖的策略，覆盖策略按前缀长度倒序，优先匹配最长的
+func buildCorsPolicies() (*corsPolicy, []corsRoute) {
+	globalConf := conf.GetGlobalConfig()
+	corsConf := globalConf.Cors
+	global := newCorsPolicy(globalConf.CorsOrigin, corsConf.AllowMethods, corsConf.AllowHeaders,
+		corsConf.ExposeHeaders, corsConf.AllowCredentials, corsConf.MaxAge)
+	checkCorsCredentials("cors_origin", global)
+
+	routes := make([]corsRoute, 0, len(corsConf.Routes))
+	for _, route := range corsConf.Routes {
+		if route.Path == "" {
+			continue
+		}
+		origins, methods, headers := route.Origins, route.AllowMethods, route.AllowHeaders
+		if len(origins) == 0 {
+			origins = globalConf.CorsOrigin
+		}
+		if len(methods) == 0 {
+			methods = corsConf.AllowMethods
+		}
+		if len(headers) == 0 {
+			headers = corsConf.AllowHeaders
+		}
+		credentials := corsConf.AllowCredentials
+		if route.AllowCredentials != nil {
+			credentials = *route.AllowCredentials
+		}
+		maxAge := corsConf.MaxAge
+		if route.MaxAge > 0 {
+			maxAge = route.MaxAge
+		}
+		policy := newCorsPolicy(origins, methods, headers, corsConf.ExposeHeaders, credentials, maxAge)
+		policy.disabled = route.Disabled
+		checkCorsCredentials("cors.routes["+route.Path+"]", policy)
+		routes = append(routes, corsRoute{prefix: route.Path, policy: policy})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+	return global, routes
+}
+
+// checkCorsCredentials 允许携带 Cookie 时，来源不能是 *，也必须带协议，否则任意网站或明文 http 页面都能带着用户的会话调用接口，
+// 配置错误直接退出
+func checkCorsCredentials(name string, policy *corsPolicy) {
+	if policy.disabled || !policy.credentials {
+		return
+	}
+	for _, origin := range policy.origins {
+		if origin == "*" {
+			panic("cors config err: " + name + " allows credentials for all origins, list the origins or disable allow_credentials")
+		}
+		if !strings.Contains(origin, "://") {
+			panic("cors config err: " + name + " origin " + origin + " has no scheme, credentials require a scheme such as https://")
+		}
+	}
+}
+
+// CORSMiddleWare 跨域中间件，需要挂在 gin.Engine 上，这样没有注册 OPTIONS 的路由也能响应预检请求。
+// 允许的来源来自 cors_origin，支持 * 和 *.example.com 形式的子域名通配
+func CORSMiddleWare() gin.HandlerFunc {
+	global, routes := buildCorsPolicies()
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		policy := matchCorsPolicy(global, routes, c.Request.URL.Path)
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Header("Vary", "Origin")
+		if policy.disabled || !originAllowed(origin, policy.origins) {
+			if preflight {
+				log.Warnf("CORSMiddleWare|origin not allowed, origin=%s|path=%s", origin, c.Request.URL.Path)
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 非预检请求照常处理，不带跨域响应头，浏览器会拦截响应
+			c.Next()
+			return
+		}
+
+		// 携带 Cookie 时不能返回 *，回显请求的来源
+		c.Header("Access-Control-Allow-Origin", origin)
+		if policy.credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if policy.expose != "" {
+				c.Header("Access-Control-Expose-Headers", policy.expose)
+			}
+			c.Next()
+			return
+		}
+		c.Header("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+		c.Header("Access-Control-Allow-Methods", policy.methods)
+		c.Header("Access-Control-Allow-Headers", policy.headers)
+		if policy.maxAge != "" {
+			c.Header("Access-Control-Max-Age", policy.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// matchCorsPolicy 找到请求路径对应的策略，路径先去掉 /t/:tenant 前缀
+func matchCorsPolicy(global *corsPolicy, routes []corsRoute, path string) *corsPolicy {
+	if strings.HasPrefix(path, "/t/") {
+		if idx := strings.Index(path[len("/t/"):], "/"); idx >= 0 {
+			path = path[len("/t/")+idx:]
+		}
+	}
+	for _, route := range routes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.policy
+		}
+	}
+	return global
+}
+
+// originAllowed 判断来源是否命中任一规则
+func originAllowed(origin string, patterns []string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matchOrigin(u.Scheme, strings.ToLower(u.Host), strings.ToLower(u.Hostname()), strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin 规则可以带协议和端口，如 https://*.example.com、http://localhost:3000；不带协议时任意协议都匹配，
+// 不带端口时任意端口都匹配。*.example.com 只匹配子域名，不匹配 example.com 本身
+func matchOrigin(scheme, host, hostname, pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if idx := strings.Index(pattern, "://"); idx >= 0 {
+		if pattern[:idx] != scheme {
+			return false
+		}
+		pattern = pattern[idx+len("://"):]
+	}
+	if _, _, err := net.SplitHostPort(pattern); err != nil {
+		host = hostname
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		suffix := pattern[1:]
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+func containsFold(source []string, target string) bool {
+	for _, s := range source {
+		if strings.EqualFold(s, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package router
+
+import "testing"
+
+func TestOriginAllowed(t *testing.T) {
+	patterns := []string{"*.trovo.live", "https://*.example.com", "http://localhost:3000"}
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://www.trovo.live", true},
+		{"http://a.b.trovo.live", true},
+		{"https://trovo.live", false},
+		{"https://eviltrovo.live", false},
+		{"https://www.trovo.live.evil.com", false},
+		{"https://api.example.com", true},
+		{"http://api.example.com", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:8080", false},
+		{"https://a.trovo.live:8443", true},
+		{"https://api.example.com:8443", true},
+		{"null", false},
+	}
+	for _, c := range cases {
+		if got := originAllowed(c.origin, patterns); got != c.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", c.origin, got, c.want)
+		}
+	}
+	if !originAllowed("https://anything.com", []string{"*"}) {
+		t.Errorf("* should allow any origin")
+	}
+}
+
+func TestCheckCorsCredentials(t *testing.T) {
+	panics := func(origins []string) (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		checkCorsCredentials("cors_origin", &corsPolicy{origins: origins, credentials: true})
+		return false
+	}
+	if !panics([]string{"*"}) {
+		t.Errorf("credentials with * origin should panic")
+	}
+	if !panics([]string{"*.trovo.live"}) {
+		t.Errorf("credentials with origin without scheme should panic")
+	}
+	if panics([]string{"https://*.trovo.live", "http://localhost:3000"}) {
+		t.Errorf("credentials with schemed origins should not panic")
+	}
+}
+
+func TestMatchCorsPolicy(t *testing.T) {
+	global := &corsPolicy{}
+	admin := &corsPolicy{disabled: true}
+	export := &corsPolicy{}
+	routes := []corsRoute{
+		{prefix: "/user/export", policy: export},
+		{prefix: "/admin", policy: admin},
+	}
+	cases := []struct {
+		path string
+		want *corsPolicy
+	}{
+		{"/user/login", global},
+		{"/admin/user/list", admin},
+		{"/t/acme/admin/user/list", admin},
+		{"/t/acme/user/export/status", export},
+		{"/t/acme", global},
+	}
+	for _, c := range cases {
+		if got := matchCorsPolicy(global, routes, c.path); got != c.want {
+			t.Errorf("matchCorsPolicy(%q) got unexpected policy", c.path)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	api "my_user_system/api/http/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFTokenBoundToSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/user/csrf_token", api.CSRFToken)
+	r.POST("/user/update", CSRFMiddleWare(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/user/csrf_token", nil)
+	req.AddCookie(&http.Cookie{Name: "user_session", Value: "s1"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var token *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "csrf_token" {
+			token = cookie
+		}
+	}
+	if token == nil {
+		t.Fatalf("csrf_token cookie not issued")
+	}
+
+	// 令牌只能和签发时的会话一起使用
+	for session, want := range map[string]int{"s1": http.StatusOK, "s2": http.StatusForbidden, "": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPost, "/user/update", nil)
+		req.AddCookie(&http.Cookie{Name: "user_session", Value: session})
+		req.AddCookie(token)
+		req.Header.Set("X-CSRF-Token", token.Value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("session %q status = %d, want %d", session, w.Code, want)
+		}
+	}
+}
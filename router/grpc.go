@@ -0,0 +1,243 @@
+package router
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"my_user_system/api/grpc/pb"
+	grpcapi "my_user_system/api/grpc/v1"
+	"my_user_system/conf"
+	"my_user_system/service"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGRPCHealthInterval = 10
+	// grpcBearerPrefix 元数据 authorization 中会话标识的前缀
+	grpcBearerPrefix = "bearer "
+)
+
+// grpcAuthMethods 需要登录态的方法
+var grpcAuthMethods = map[string]bool{
+	pb.UserService_Logout_FullMethodName:        true,
+	pb.UserService_GetUserInfo_FullMethodName:   true,
+	pb.UserService_UpdateProfile_FullMethodName: true,
+}
+
+// grpcRateLimitPaths gRPC 方法对应的 HTTP 路径，按 rate_limit.routes 中同一路径的规则限流，与 HTTP 接口共用计数
+var grpcRateLimitPaths = map[string]string{
+	pb.UserService_Register_FullMethodName:      "/user/register",
+	pb.UserService_Login_FullMethodName:         "/user/login",
+	pb.UserService_Logout_FullMethodName:        "/user/logout",
+	pb.UserService_GetUserInfo_FullMethodName:   "/user/get_user_info",
+	pb.UserService_UpdateProfile_FullMethodName: "/user/update_nick_name",
+}
+
+// InitGRPCServer 在单独的端口上启动 gRPC 服务，未开启时不启动。端口监听失败时直接退出
+func InitGRPCServer() {
+	grpcConf := conf.GetGlobalConfig().GRPC
+	if !grpcConf.Enabled {
+		return
+	}
+	lis, err := net.Listen("tcp", ":"+strconv.Itoa(grpcConf.Port))
+	if err != nil {
+		panic("listen grpc port err:" + err.Error())
+	}
+	var opts []grpc.ServerOption
+	if grpcConf.CertFile != "" || grpcConf.KeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(grpcConf.CertFile, grpcConf.KeyFile)
+		if err != nil {
+			panic("load grpc tls credentials err:" + err.Error())
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server, healthServer := newGRPCServer(grpcConf.Reflection, opts...)
+	interval := grpcConf.HealthInterval
+	if interval <= 0 {
+		interval = defaultGRPCHealthInterval
+	}
+	go watchGRPCHealth(healthServer, time.Duration(interval)*time.Second)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Error("start grpc server err:" + err.Error())
+		}
+	}()
+	log.Infof("InitGRPCServer success, port=%d|tls=%v|reflection=%v", grpcConf.Port, len(opts) > 0, grpcConf.Reflection)
+}
+
+// newGRPCServer 创建 gRPC 服务，注册用户服务和健康检查服务，按需注册反射服务。opts 用于传入 TLS 证书等服务选项
+func newGRPCServer(enableReflection bool, opts ...grpc.ServerOption) (*grpc.Server, *health.Server) {
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		grpcRecoveryInterceptor,
+		grpcContextInterceptor,
+		newGRPCRateLimitInterceptor(newRateLimiter()),
+		grpcAuthInterceptor,
+	))
+	server := grpc.NewServer(opts...)
+	pb.RegisterUserServiceServer(server, grpcapi.NewUserServer())
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	if enableReflection {
+		reflection.Register(server)
+	}
+	return server, healthServer
+}
+
+// watchGRPCHealth 按依赖健康状态刷新健康检查服务，数据库不可用时为 NOT_SERVING，Redis 不可用时仍可降级服务
+func watchGRPCHealth(healthServer *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		state := healthpb.HealthCheckResponse_SERVING
+		if service.Health().Status == service.HealthDown {
+			state = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		healthServer.SetServingStatus("", state)
+		healthServer.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, state)
+		<-ticker.C
+	}
+}
+
+// grpcRecoveryInterceptor 记录访问日志，处理函数 panic 时返回 Internal，避免整个进程退出
+func grpcRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (rsp interface{}, err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("grpc|%s|panic:%v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+		log.Infof("grpc|%s|code=%s|cost=%v", info.FullMethod, status.Code(err), time.Since(start))
+	}()
+	return handler(ctx, req)
+}
+
+// grpcContextInterceptor 从元数据中构造与 HTTP 接口相同的请求上下文：租户、会话、User-Agent、来源IP和请求ID。
+// 健康检查等非业务服务不解析租户，数据库不可用时也能响应
+func grpcContextInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+pb.UserService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	headerCode := ""
+	if header := conf.GetGlobalConfig().Tenant.Header; header != "" {
+		headerCode = firstMetadata(md, strings.ToLower(header))
+	}
+	tenantID, err := service.ResolveTenant("", headerCode, firstMetadata(md, ":authority"))
+	if err != nil {
+		log.Errorf("grpcContextInterceptor|resolve tenant err=%v", err)
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	ctx = context.WithValue(ctx, static.SessionKey, sessionFromMetadata(md))
+	ctx = context.WithValue(ctx, static.TenantKey, tenantID)
+	ctx = context.WithValue(ctx, static.UserAgentKey, firstMetadata(md, "user-agent"))
+	ctx = context.WithValue(ctx, static.ClientIPKey, peerIP(ctx))
+	uuid := utils.Md5String(info.FullMethod + time.Now().GoString())
+	return handler(context.WithValue(ctx, static.ReqUuid, uuid), req)
+}
+
+// newGRPCRateLimitInterceptor 按 rate_limit 配置限流，规则与 RateLimitMiddleWare 相同，超限时返回 ResourceExhausted。
+// 需要挂在 grpcContextInterceptor 之后，来源 IP 取对端地址，健康检查等非业务服务不限流
+func newGRPCRateLimitInterceptor(limiter *rateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ip, ok := ctx.Value(static.ClientIPKey).(string)
+		if limiter == nil || !ok || limiter.allowlist.contains(ip) {
+			return handler(ctx, req)
+		}
+		tenantID, _ := ctx.Value(static.TenantKey).(int)
+		policies := limiter.policies(tenantID, grpcRateLimitPaths[info.FullMethod], ip, func() string {
+			return grpcRateLimitUser(ctx, req)
+		})
+		if result := service.CheckRateLimit(policies); result != nil && !result.Allowed {
+			log.Warnf("grpcRateLimitInterceptor|too many requests, ip=%s|method=%s", ip, info.FullMethod)
+			reset := int((result.Reset + time.Second - 1) / time.Second)
+			return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry after %ds", reset)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// grpcRateLimitUser 取出按用户限流使用的用户名，与 rateLimitUser 一致：已登录时取会话中的用户，否则取请求中的 user_name
+func grpcRateLimitUser(ctx context.Context, req interface{}) string {
+	if session, _ := ctx.Value(static.SessionKey).(string); session != "" {
+		tenantID, _ := ctx.Value(static.TenantKey).(int)
+		if user, err := service.GetPrincipal(tenantID, session); err == nil {
+			return user.Name
+		}
+	}
+	if named, ok := req.(interface{ GetUserName() string }); ok {
+		return strings.TrimSpace(named.GetUserName())
+	}
+	return ""
+}
+
+// grpcAuthInterceptor 需要登录态的方法校验会话并滑动续期，把当前用户放入上下文。
+// 与 AuthMiddleWare 一致，续期时依赖异常不拦截，交给后续处理函数判断
+func grpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if !grpcAuthMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	session, _ := ctx.Value(static.SessionKey).(string)
+	if session == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing session")
+	}
+	tenantID, _ := ctx.Value(static.TenantKey).(int)
+	_, err := service.TouchSession(tenantID, session)
+	if err == service.ErrSessionInvalid {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		log.Warnf("grpcAuthInterceptor|renew session failed, session=%s|err=%v", session, err)
+	}
+	user, err := service.GetPrincipal(tenantID, session)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "session invalid")
+	}
+	return handler(context.WithValue(ctx, static.PrincipalKey, user), req)
+}
+
+// sessionFromMetadata 从元数据 authorization: Bearer <session> 中取会话标识
+func sessionFromMetadata(md metadata.MD) string {
+	auth := firstMetadata(md, "authorization")
+	if len(auth) > len(grpcBearerPrefix) && strings.EqualFold(auth[:len(grpcBearerPrefix)], grpcBearerPrefix) {
+		return strings.TrimSpace(auth[len(grpcBearerPrefix):])
+	}
+	return ""
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP 对端地址中的IP，gRPC 服务不经过 HTTP 代理，不读取 X-Forwarded-For
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"my_user_system/api/grpc/pb"
+	"my_user_system/conf"
+	"my_user_system/static"
+	"net"
+	"testing"
+)
+
+func TestSessionFromMetadata(t *testing.T) {
+	cases := map[string]string{
+		"Bearer abc":   "abc",
+		"bearer  abc ": "abc",
+		"Basic abc":    "",
+		"Bearer ":      "",
+		"":             "",
+	}
+	for auth, want := range cases {
+		if got := sessionFromMetadata(metadata.Pairs("authorization", auth)); got != want {
+			t.Errorf("sessionFromMetadata(%q) = %q, want %q", auth, got, want)
+		}
+	}
+}
+
+func TestGRPCContextInterceptor(t *testing.T) {
+	md := metadata.Pairs("authorization", "Bearer s1", "user-agent", "grpc-go-test")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.8"), Port: 5000}})
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_ValidateSession_FullMethodName}
+	_, err := grpcContextInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if ctx.Value(static.SessionKey) != "s1" || ctx.Value(static.ClientIPKey) != "10.0.0.8" ||
+			ctx.Value(static.UserAgentKey) != "grpc-go-test" || ctx.Value(static.ReqUuid) == nil {
+			t.Errorf("unexpected request context")
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGRPCAuthInterceptor(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	ctx := context.WithValue(context.Background(), static.SessionKey, "")
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_GetUserInfo_FullMethodName}
+	if _, err := grpcAuthInterceptor(ctx, nil, info, handler); status.Code(err) != codes.Unauthenticated || called {
+		t.Errorf("GetUserInfo without session: err=%v called=%v, want Unauthenticated", err, called)
+	}
+	info.FullMethod = pb.UserService_ValidateSession_FullMethodName
+	if _, err := grpcAuthInterceptor(ctx, nil, info, handler); err != nil || !called {
+		t.Errorf("ValidateSession should not require session: err=%v called=%v", err, called)
+	}
+}
+
+func TestGRPCRateLimitInterceptor(t *testing.T) {
+	rateConf := &conf.GetGlobalConfig().RateLimit
+	saved := *rateConf
+	defer func() { *rateConf = saved }()
+	*rateConf = conf.RateLimitConf{
+		Enabled:   true,
+		Backend:   "memory",
+		Allowlist: []string{"127.0.0.1"},
+		PerIP:     conf.RateLimitRule{Limit: 100, Window: 60},
+		Routes: []conf.RateLimitRouteConf{
+			{Path: "/user/login", PerUser: conf.RateLimitRule{Limit: 1, Window: 60}},
+		},
+	}
+	interceptor := newGRPCRateLimitInterceptor(newRateLimiter())
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_Login_FullMethodName}
+	login := func(ip, name string) error {
+		ctx := context.WithValue(context.Background(), static.ClientIPKey, ip)
+		ctx = context.WithValue(ctx, static.SessionKey, "")
+		_, err := interceptor(ctx, &pb.LoginRequest{UserName: name}, info, handler)
+		return err
+	}
+
+	// 与 HTTP 登录接口相同，同一账号换 IP 也共用计数
+	if err := login("10.0.1.1", "grpc_rate_user"); err != nil {
+		t.Fatalf("first login err = %v", err)
+	}
+	if err := login("10.0.1.2", "grpc_rate_user"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second login err = %v, want ResourceExhausted", err)
+	}
+	if err := login("127.0.0.1", "grpc_rate_user"); err != nil {
+		t.Errorf("allowlisted login err = %v, want nil", err)
+	}
+}
+
+func TestGRPCRecoveryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserService_Login_FullMethodName}
+	_, err := grpcRecoveryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("err = %v, want Internal", err)
+	}
+}
+
+func TestGRPCHealth(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server, _ := newGRPCServer(true)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rsp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || rsp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health check = %v %v, want SERVING", rsp, err)
+	}
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	api "my_user_system/api/http/v1"
+	"my_user_system/conf"
+	"my_user_system/service"
+	"my_user_system/static"
+	"net/http"
+)
+
+// TenantMiddleWare 解析请求所属租户并放入 gin.Context，指定的租户不存在时直接返回 404
+func TenantMiddleWare() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		headerCode := ""
+		if header := conf.GetGlobalConfig().Tenant.Header; header != "" {
+			headerCode = c.GetHeader(header)
+		}
+		tenantID, err := service.ResolveTenant(c.Param("tenant"), headerCode, c.Request.Host)
+		if err != nil {
+			log.Errorf("TenantMiddleWare|resolve tenant err=%v", err)
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Set(static.TenantKey, tenantID)
+		c.Next()
+	}
+}
+
+// RequirePermission 权限校验中间件，需要挂在 AuthMiddleWare 之后，
+// 通过会话解析出当前用户，校验其有效权限中包含 perm，并把用户放入 gin.Context 供后续处理函数使用。
+// 模拟登录会话一律拒绝，避免只有模拟登录权限的管理员借目标用户的权限操作
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := api.GetSessionCookie(c)
+		tenantID := c.GetInt(static.TenantKey)
+		user, err := service.GetPrincipal(tenantID, session)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session invalid"})
+			c.Abort()
+			return
+		}
+		if service.IsImpersonating(tenantID, session) {
+			log.Warnf("RequirePermission|impersonation session denied, user_name=%s|perm=%s", user.Name, perm)
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied while impersonating"})
+			c.Abort()
+			return
+		}
+		ok, err := service.HasPermission(user, perm)
+		if err != nil {
+			log.Errorf("RequirePermission|user_name=%s|perm=%s|err=%v", user.Name, perm, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "check permission err"})
+			c.Abort()
+			return
+		}
+		if !ok {
+			log.Warnf("RequirePermission|permission denied, user_name=%s|perm=%s", user.Name, perm)
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			c.Abort()
+			return
+		}
+		c.Set(static.PrincipalKey, user)
+		c.Next()
+	}
+}
+
+// CSRFMiddleWare 对写请求做 CSRF 校验，GET、HEAD、OPTIONS 等安全方法直接放行。
+// 令牌由 /user/csrf_token 签发，需要在配置的请求头中原样带回
+func CSRFMiddleWare() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !conf.GetGlobalConfig().CSRF.Enabled {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		if !api.CheckCSRF(c) {
+			log.Warnf("CSRFMiddleWare|csrf token invalid, method=%s|path=%s|ip=%s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "csrf token invalid"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
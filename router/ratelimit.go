@@ -0,0 +1,174 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"io"
+	api "my_user_system/api/http/v1"
+	"my_user_system/conf"
+	"my_user_system/service"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitPeekSize 为取出用户名最多读取的请求体字节数
+const rateLimitPeekSize = 64 << 10
+
+// ipAllowlist 不限流的 IP 和网段
+type ipAllowlist []*net.IPNet
+
+// parseAllowlist 解析 IP 或 CIDR，单个 IP 转为只包含自身的网段
+func parseAllowlist(entries []string) ipAllowlist {
+	list := make(ipAllowlist, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Errorf("rate limit allowlist entry %s invalid, skip, err:%v", entry, err)
+			continue
+		}
+		list = append(list, ipNet)
+	}
+	return list
+}
+
+func (l ipAllowlist) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range l {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimiter HTTP 和 gRPC 接口共用的限流规则
+type rateLimiter struct {
+	conf      conf.RateLimitConf
+	allowlist ipAllowlist
+	routes    map[string]conf.RateLimitRouteConf
+}
+
+// newRateLimiter 按配置构造限流规则，未开启限流时返回 nil
+func newRateLimiter() *rateLimiter {
+	rateConf := conf.GetGlobalConfig().RateLimit
+	if !rateConf.Enabled {
+		return nil
+	}
+	routes := make(map[string]conf.RateLimitRouteConf, len(rateConf.Routes))
+	for _, route := range rateConf.Routes {
+		routes[route.Path] = route
+	}
+	return &rateLimiter{conf: rateConf, allowlist: parseAllowlist(rateConf.Allowlist), routes: routes}
+}
+
+// policies 生成一次请求需要检查的限流策略，path 为不含租户前缀的 HTTP 路径。
+// userName 只在接口配置了按用户限制时调用，取不到用户名时跳过按用户的限制
+func (l *rateLimiter) policies(tenantID int, path, ip string, userName func() string) []service.RateLimitPolicy {
+	var policies []service.RateLimitPolicy
+	addPolicy := func(rule conf.RateLimitRule, key string) {
+		if rule.Limit > 0 && rule.Window > 0 {
+			policies = append(policies, service.RateLimitPolicy{
+				Key:    key,
+				Limit:  rule.Limit,
+				Window: time.Duration(rule.Window) * time.Second,
+			})
+		}
+	}
+	addPolicy(l.conf.PerIP, "ip:"+ip)
+	if route, ok := l.routes[path]; ok {
+		addPolicy(route.PerIP, fmt.Sprintf("route:%s:ip:%s", path, ip))
+		if route.PerUser.Limit > 0 {
+			if name := userName(); name != "" {
+				addPolicy(route.PerUser, fmt.Sprintf("route:%s:user:%d:%s", path, tenantID, utils.Md5String(name)))
+			}
+		}
+	}
+	return policies
+}
+
+// RateLimitMiddleWare 限流中间件，需要挂在 TenantMiddleWare 之后。
+// 所有接口共用按 IP 的限制，配置了的接口再叠加按 IP 和按用户名的限制；响应中带上 RateLimit-* 头，超限时返回 429 和 Retry-After
+func RateLimitMiddleWare() gin.HandlerFunc {
+	limiter := newRateLimiter()
+	if limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return func(c *gin.Context) {
+		// 白名单按连接地址匹配，不受 X-Forwarded-For 影响；计数按经可信代理解析后的客户端 IP
+		ip := c.ClientIP()
+		if limiter.allowlist.contains(c.RemoteIP()) {
+			c.Next()
+			return
+		}
+		path := strings.TrimPrefix(c.FullPath(), "/t/:tenant")
+		policies := limiter.policies(c.GetInt(static.TenantKey), path, ip, func() string {
+			return rateLimitUser(c)
+		})
+
+		result := service.CheckRateLimit(policies)
+		if result == nil {
+			c.Next()
+			return
+		}
+		reset := strconv.Itoa(int((result.Reset + time.Second - 1) / time.Second))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", reset)
+		if !result.Allowed {
+			log.Warnf("RateLimitMiddleWare|too many requests, ip=%s|path=%s", ip, c.Request.URL.Path)
+			c.Header("Retry-After", reset)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitUser 取出按用户限流使用的用户名：已登录时取会话中的用户，否则取 JSON 请求体中的 user_name。
+// 读取请求体后放回，不影响后续处理函数绑定参数
+func rateLimitUser(c *gin.Context) string {
+	if session := api.GetSessionCookie(c); session != "" {
+		if user, err := service.GetPrincipal(c.GetInt(static.TenantKey), session); err == nil {
+			return user.Name
+		}
+	}
+	if c.Request.Body == nil {
+		return ""
+	}
+	buf, err := io.ReadAll(io.LimitReader(c.Request.Body, rateLimitPeekSize))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return ""
+	}
+	var body struct {
+		UserName string `json:"user_name"`
+	}
+	if json.Unmarshal(buf, &body) != nil {
+		return ""
+	}
+	return strings.TrimSpace(body.UserName)
+}
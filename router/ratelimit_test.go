@@ -0,0 +1,53 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"my_user_system/conf"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	rateConf := &conf.GetGlobalConfig().RateLimit
+	saved := *rateConf
+	defer func() { *rateConf = saved }()
+	*rateConf = conf.RateLimitConf{
+		Enabled:   true,
+		Backend:   "memory",
+		Allowlist: []string{"127.0.0.1"},
+		PerIP:     conf.RateLimitRule{Limit: 1, Window: 60},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/ping", RateLimitMiddleWare(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// 伪造的 X-Forwarded-For 既不能命中白名单，也不能换一个 IP 重新计数
+	codes := make([]int, 0, 2)
+	for _, forwarded := range []string{"127.0.0.1", "1.2.3.4"} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("status codes = %v, want [200 429]", codes)
+	}
+
+	// 来自白名单地址的连接不限流
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("allowlisted request %d status = %d, want 200", i, w.Code)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	api "my_user_system/api/http/v1"
 	"my_user_system/conf"
+	"my_user_system/service"
 	"my_user_system/static"
 	"net/http"
 	"strconv"
@@ -17,23 +18,32 @@ func InitRouterAndServe() {
 
 	// 初始化 gin 实例
 	r := gin.Default()
+	// 只采信可信代理转发的 X-Forwarded-For，否则客户端可以伪造 IP 绕过限流和登录失败计数
+	if err := r.SetTrustedProxies(conf.GetGlobalConfig().AppConfig.TrustedProxies); err != nil {
+		panic("trusted proxies conf err:" + err.Error())
+	}
+	// 跨域，挂在引擎上才能处理未注册路由的预检请求
+	r.Use(CORSMiddleWare())
 
 	// 健康检查
 	// 设置 "/ping" 路由的处理函数为 api.Ping
 	r.GET("/ping", api.Ping)
+	// 依赖健康状态，Redis 不可用时报告 degraded
+	r.GET("/health", api.Health)
 
-	// 设置 "/user/login" 路由的处理函数为 api.Login
-	r.POST("/user/login", api.Login)
+	// 业务路由同时挂在根路径和 /t/:tenant 下，租户由 TenantMiddleWare 按 路径 > 请求头 > 域名 解析，经过限流，写请求需要通过 CSRF 校验
+	registerRoutes(r.Group("/", TenantMiddleWare(), RateLimitMiddleWare(), CSRFMiddleWare()))
+	registerRoutes(r.Group("/t/:tenant", TenantMiddleWare(), RateLimitMiddleWare(), CSRFMiddleWare()))
 
-	// 设置“/user/register” 路由的处理函数为 api.Register,路径要和静态文件中的对应上，否则就会找不到404
-	r.POST("/user/register", api.Register)
+	// 内部接口，供其他服务调用，通过服务凭证认证，不经过用户维度的限流和 CSRF 校验
+	registerInternalRoutes(r.Group("/internal", TenantMiddleWare()))
+	registerInternalRoutes(r.Group("/t/:tenant/internal", TenantMiddleWare()))
 
-	// 用户登出
-	r.POST("/user/logout", api.Logout)
-	// 获取用户信息
-	r.GET("/user/get_user_info", AuthMiddleWare(), api.GetUserInfo)
-	// 更新用户信息
-	r.POST("/user/update_nick_name", AuthMiddleWare(), api.UpdateNickName)
+	// OAuth 令牌接口，供第三方应用的服务端或客户端调用，通过客户端凭证或 PKCE 认证，不经过 CSRF 校验
+	if conf.GetGlobalConfig().OAuth.Enabled {
+		registerOAuthRoutes(r.Group("/oauth", TenantMiddleWare(), RateLimitMiddleWare()))
+		registerOAuthRoutes(r.Group("/t/:tenant/oauth", TenantMiddleWare(), RateLimitMiddleWare()))
+	}
 
 	// 至关重要，通过这两句把html上传到服务器，才可以响应客户端的请求，注意root（文件源地址）和relativePath（客户端中间路径）
 	r.Static("/static/", "./view/")
@@ -46,6 +56,98 @@ func InitRouterAndServe() {
 	}
 }
 
+// registerRoutes 注册需要区分租户的业务路由
+func registerRoutes(g *gin.RouterGroup) {
+	// 签发 CSRF 令牌，页面加载时调用，之后的写请求在请求头中带上令牌
+	g.GET("/user/csrf_token", api.CSRFToken)
+	// 获取人机校验挑战，注册时总是需要，登录失败次数过多后需要
+	g.GET("/user/challenge", api.GetChallenge)
+
+	// 设置 "/user/login" 路由的处理函数为 api.Login
+	g.POST("/user/login", api.Login)
+
+	// 设置“/user/register” 路由的处理函数为 api.Register,路径要和静态文件中的对应上，否则就会找不到404
+	g.POST("/user/register", api.Register)
+
+	// 修改密码，被管理员要求重置密码的用户无法登录，因此不要求登录态
+	g.POST("/user/change_password", api.ChangePassword)
+
+	// 用户登出
+	g.POST("/user/logout", api.Logout)
+	// 获取用户信息
+	g.GET("/user/get_user_info", AuthMiddleWare(), api.GetUserInfo)
+	// 更新用户信息
+	g.POST("/user/update_nick_name", AuthMiddleWare(), api.UpdateNickName)
+	// 注销账号
+	g.POST("/user/delete", AuthMiddleWare(), api.DeleteAccount)
+	// 结束模拟登录
+	g.POST("/user/impersonate/stop", AuthMiddleWare(), api.ImpersonateStop)
+	// 个人数据导出
+	g.POST("/user/export", AuthMiddleWare(), api.CreateExport)
+	g.GET("/user/export/status", AuthMiddleWare(), api.GetExportStatus)
+	g.GET("/user/export/download", AuthMiddleWare(), api.DownloadExport)
+	g.GET("/user/export/public_key", api.GetExportPublicKey)
+	// 登录记录
+	g.GET("/user/login_history", AuthMiddleWare(), api.ListLoginHistory)
+
+	// OAuth 授权地址和授权确认页调用的接口，使用用户的登录态
+	if conf.GetGlobalConfig().OAuth.Enabled {
+		g.GET("/oauth/authorize", api.OAuthAuthorize)
+		g.GET("/oauth/consent", AuthMiddleWare(), api.OAuthConsentInfo)
+		g.POST("/oauth/consent", AuthMiddleWare(), api.OAuthConsent)
+	}
+
+	// 管理接口，需要登录并拥有对应权限
+	admin := g.Group("/admin", AuthMiddleWare())
+	admin.GET("/role/list", RequirePermission(static.PermRolesRead), api.ListRoles)
+	admin.POST("/role/create", RequirePermission(static.PermRolesWrite), api.CreateRole)
+	admin.POST("/role/grant", RequirePermission(static.PermRolesWrite), api.GrantPermission)
+	admin.POST("/role/revoke", RequirePermission(static.PermRolesWrite), api.RevokePermission)
+	admin.GET("/user/roles", RequirePermission(static.PermRolesRead), api.GetUserRoles)
+	admin.POST("/user/assign_role", RequirePermission(static.PermRolesWrite), api.AssignRole)
+	admin.POST("/user/unassign_role", RequirePermission(static.PermRolesWrite), api.UnassignRole)
+	admin.GET("/user/list", RequirePermission(static.PermUsersRead), api.AdminListUsers)
+	admin.GET("/user/get", RequirePermission(static.PermUsersRead), api.AdminGetUser)
+	admin.POST("/user/disable", RequirePermission(static.PermUsersWrite), api.AdminDisableUser)
+	admin.POST("/user/enable", RequirePermission(static.PermUsersWrite), api.AdminEnableUser)
+	admin.POST("/user/force_reset_password", RequirePermission(static.PermUsersWrite), api.AdminForceResetPassword)
+	admin.POST("/user/update", RequirePermission(static.PermUsersWrite), api.AdminUpdateUser)
+	admin.GET("/user/login_history", RequirePermission(static.PermUsersRead), api.AdminListLoginHistory)
+	admin.POST("/impersonate/start", RequirePermission(static.PermUsersImpersonate), api.ImpersonateStart)
+	admin.GET("/org/list", RequirePermission(static.PermOrgsRead), api.ListOrganizations)
+	admin.POST("/org/create", RequirePermission(static.PermOrgsWrite), api.CreateOrganization)
+	admin.GET("/org/members", RequirePermission(static.PermOrgsRead), api.ListOrgMembers)
+	admin.POST("/org/set_member_role", RequirePermission(static.PermOrgsWrite), api.SetOrgMemberRole)
+	admin.POST("/org/flush_cache", RequirePermission(static.PermOrgsWrite), api.FlushTenantCache)
+	admin.GET("/invite/list", RequirePermission(static.PermInvitesRead), api.ListInvitations)
+	admin.POST("/invite/create", RequirePermission(static.PermInvitesWrite), api.CreateInvitation)
+	admin.POST("/invite/revoke", RequirePermission(static.PermInvitesWrite), api.RevokeInvitation)
+	admin.GET("/audit/list", RequirePermission(static.PermAuditRead), api.ListAuditLogs)
+	admin.GET("/audit/verify", RequirePermission(static.PermAuditRead), api.VerifyAuditChain)
+	admin.GET("/webhook/list", RequirePermission(static.PermWebhooksRead), api.ListWebhooks)
+	admin.POST("/webhook/create", RequirePermission(static.PermWebhooksWrite), api.CreateWebhook)
+	admin.POST("/webhook/update", RequirePermission(static.PermWebhooksWrite), api.UpdateWebhook)
+	admin.POST("/webhook/delete", RequirePermission(static.PermWebhooksWrite), api.DeleteWebhook)
+	admin.GET("/webhook/deliveries", RequirePermission(static.PermWebhooksRead), api.ListWebhookDeliveries)
+	admin.POST("/webhook/redeliver", RequirePermission(static.PermWebhooksWrite), api.RedeliverWebhook)
+	admin.GET("/oauth/client/list", RequirePermission(static.PermOAuthRead), api.ListOAuthClients)
+	admin.POST("/oauth/client/create", RequirePermission(static.PermOAuthWrite), api.CreateOAuthClient)
+	admin.POST("/oauth/client/update", RequirePermission(static.PermOAuthWrite), api.UpdateOAuthClient)
+}
+
+// registerInternalRoutes 注册供其他服务调用的内部接口
+func registerInternalRoutes(g *gin.RouterGroup) {
+	// 令牌内省，RFC 7662 风格
+	g.POST("/introspect", api.Introspect)
+}
+
+// registerOAuthRoutes 注册 OAuth 令牌接口
+func registerOAuthRoutes(g *gin.RouterGroup) {
+	g.POST("/token", api.OAuthToken)
+	g.POST("/revoke", api.OAuthRevoke)
+	g.GET("/userinfo", api.OAuthUserInfo)
+}
+
 // setAppRunMode 函数根据配置设置应用运行模式
 func setAppRunMode() {
 	// 如果全局配置中的应用运行模式为 "release"，则设置 gin 框架的运行模式为 release 模式
@@ -53,17 +155,30 @@ func setAppRunMode() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 }
+
+// AuthMiddleWare 校验登录态并对会话滑动续期，续期后同步刷新 Cookie 的有效期。
+// 会话已失效时直接返回 401，Redis 等依赖异常时不拦截，交给后续处理函数判断
 func AuthMiddleWare() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if session, err := c.Cookie(static.SessionKey); err == nil {
-			if session != "" {
-				c.Next()
-				return
-			}
+		session := api.GetSessionCookie(c)
+		if session == "" {
+			// 返回错误
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "err"})
+			c.Abort()
+			return
+		}
+		ttl, err := service.TouchSession(c.GetInt(static.TenantKey), session)
+		switch {
+		case err == nil:
+			api.SetSessionCookie(c, session, ttl)
+		case err == service.ErrSessionInvalid:
+			api.ClearSessionCookie(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
+			c.Abort()
+			return
+		default:
+			log.Warnf("AuthMiddleWare|renew session failed, session=%s|err=%v", session, err)
 		}
-		// 返回错误
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "err"})
-		c.Abort()
-		return
+		c.Next()
 	}
 }
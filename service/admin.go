@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+const (
+	defaultListLimit = 20  // 用户列表默认每页条数
+	maxListLimit     = 100 // 用户列表每页最大条数
+)
+
+// toAdminUserInfo 转换为管理后台展示的用户信息
+func toAdminUserInfo(user *model.User) *AdminUserInfo {
+	return &AdminUserInfo{
+		ID:               user.ID,
+		UserName:         user.Name,
+		NickName:         user.NickName,
+		Gender:           user.Gender,
+		Age:              user.Age,
+		Status:           user.Status,
+		PwdResetRequired: user.PwdResetRequired,
+		Inviter:          user.Inviter,
+		Creator:          user.Creator,
+		CreateTime:       user.CreateTime.Unix(),
+		Modifier:         user.Modifier,
+		ModifyTime:       user.ModifyTime.Unix(),
+	}
+}
+
+// AdminListUsers 按条件游标分页查询用户
+func AdminListUsers(ctx context.Context, req *ListUsersRequest) (*ListUsersResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	log.Infof("%s|AdminListUsers access from operator=%s|req=%+v", uuid, operator, req)
+
+	filter := &dao.UserFilter{
+		TenantID:   tenantFromCtx(ctx),
+		Cursor:     req.Cursor,
+		Limit:      req.Limit,
+		NamePrefix: req.NamePrefix,
+		Gender:     req.Gender,
+		MinAge:     req.MinAge,
+		MaxAge:     req.MaxAge,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if req.CreatedAfter > 0 {
+		filter.CreatedAfter = time.Unix(req.CreatedAfter, 0)
+	}
+	if req.CreatedBefore > 0 {
+		filter.CreatedBefore = time.Unix(req.CreatedBefore, 0)
+	}
+
+	users, err := dao.ListUsers(filter)
+	if err != nil {
+		return nil, fmt.Errorf("AdminListUsers|%v", err)
+	}
+	rsp := &ListUsersResponse{Users: make([]*AdminUserInfo, 0, len(users))}
+	for _, user := range users {
+		rsp.Users = append(rsp.Users, toAdminUserInfo(user))
+	}
+	if len(users) == filter.Limit {
+		rsp.NextCursor = users[len(users)-1].ID
+	}
+	return rsp, nil
+}
+
+// AdminGetUser 查询单个用户
+func AdminGetUser(ctx context.Context, req *AdminUserRequest) (*AdminUserInfo, error) {
+	if req.UserName == "" {
+		return nil, fmt.Errorf("AdminGetUser|request params invalid")
+	}
+	user, err := dao.GetUserByName(tenantFromCtx(ctx), req.UserName)
+	if err != nil {
+		return nil, fmt.Errorf("AdminGetUser|%v", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("用户尚未注册")
+	}
+	return toAdminUserInfo(user), nil
+}
+
+// adminUpdateUser 以管理员身份更新用户字段并刷新缓存
+func adminUpdateUser(ctx context.Context, userName, action string, fields map[string]interface{}) (*model.User, error) {
+	tenantID := tenantFromCtx(ctx)
+	operator := ctx.Value(static.OperatorKey).(string)
+	before, err := dao.GetUserByName(tenantID, userName)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, fmt.Errorf("用户尚未注册")
+	}
+	fields["modifier"] = operator
+	if _, err := dao.UpdateUserFields(tenantID, userName, fields, userUpdatedEvent(tenantID, userName, fields)); err != nil {
+		return nil, err
+	}
+	user, err := dao.GetUserByName(tenantID, userName)
+	if err != nil {
+		return nil, err
+	}
+	writeAuditDiff(ctx, operator, userName, action, "", userAuditFields(before), userAuditFields(user))
+	cache.InvalidateUserInfo(tenantID, userName)
+	// 会话中保存了用户信息的副本，一并刷新
+	sessions, err := cache.ListUserSessions(tenantID, userName)
+	if err != nil {
+		log.Errorf("adminUpdateUser|list sessions failed for user:%s with err:%v", userName, err)
+		return user, nil
+	}
+	for _, session := range sessions {
+		if err := cache.SetSessionInfo(user, session); err != nil {
+			log.Errorf("adminUpdateUser|update session failed:%v", err)
+			cache.DelSessionInfo(tenantID, session)
+		}
+	}
+	return user, nil
+}
+
+// AdminSetUserStatus 禁用或启用账号，禁用时踢掉该用户的全部会话
+func AdminSetUserStatus(ctx context.Context, req *AdminUserRequest, status int) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.UserName == "" {
+		return fmt.Errorf("AdminSetUserStatus|request params invalid")
+	}
+	tenantID := tenantFromCtx(ctx)
+	if _, err := adminUpdateUser(ctx, req.UserName, model.AuditAdminUserStatus, map[string]interface{}{"status": status}); err != nil {
+		return fmt.Errorf("AdminSetUserStatus|%v", err)
+	}
+	if status == model.UserStatusDisabled {
+		if err := revokeUserSessions(ctx, operator, tenantID, req.UserName, "disabled"); err != nil {
+			log.Errorf("%s|AdminSetUserStatus|del sessions failed for user:%s with err:%v", uuid, req.UserName, err)
+			return fmt.Errorf("AdminSetUserStatus|del sessions err:%v", err)
+		}
+	}
+	log.Infof("%s|AdminSetUserStatus success, user_name=%s|status=%d|operator=%s", uuid, req.UserName, status, operator)
+	return nil
+}
+
+// AdminForceResetPassword 要求用户重置密码，用户需修改密码后才能重新登录
+func AdminForceResetPassword(ctx context.Context, req *AdminUserRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.UserName == "" {
+		return fmt.Errorf("AdminForceResetPassword|request params invalid")
+	}
+	tenantID := tenantFromCtx(ctx)
+	if _, err := adminUpdateUser(ctx, req.UserName, model.AuditAdminPwdReset, map[string]interface{}{"pwd_reset_required": true}); err != nil {
+		return fmt.Errorf("AdminForceResetPassword|%v", err)
+	}
+	if err := revokeUserSessions(ctx, operator, tenantID, req.UserName, "password_reset"); err != nil {
+		log.Errorf("%s|AdminForceResetPassword|del sessions failed for user:%s with err:%v", uuid, req.UserName, err)
+		return fmt.Errorf("AdminForceResetPassword|del sessions err:%v", err)
+	}
+	log.Infof("%s|AdminForceResetPassword success, user_name=%s|operator=%s", uuid, req.UserName, operator)
+	return nil
+}
+
+// AdminUpdateUser 修改用户资料
+func AdminUpdateUser(ctx context.Context, req *AdminUpdateUserRequest) (*AdminUserInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.UserName == "" || req.Age < 0 {
+		return nil, fmt.Errorf("AdminUpdateUser|request params invalid")
+	}
+	if req.Gender != "" && !utils.Contains([]string{static.GenderMale, static.GenderFeMale}, req.Gender) {
+		return nil, fmt.Errorf("AdminUpdateUser|gender invalid")
+	}
+
+	fields := map[string]interface{}{}
+	if req.NickName != "" {
+		fields["nickname"] = req.NickName
+	}
+	if req.Age > 0 {
+		fields["age"] = req.Age
+	}
+	if req.Gender != "" {
+		fields["gender"] = req.Gender
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("AdminUpdateUser|nothing to update")
+	}
+
+	user, err := adminUpdateUser(ctx, req.UserName, model.AuditAdminUserUpdate, fields)
+	if err != nil {
+		return nil, fmt.Errorf("AdminUpdateUser|%v", err)
+	}
+	log.Infof("%s|AdminUpdateUser success, user_name=%s|fields=%v|operator=%s", uuid, req.UserName, fields, operator)
+	return toAdminUserInfo(user), nil
+}
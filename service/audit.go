@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"time"
+)
+
+// auditVerifyBatch 校验哈希链时每批读取的条数
+const auditVerifyBatch = 500
+
+func init() {
+	registerExportSection("audit_logs", collectAuditLogs)
+}
+
+// auditChange 一个字段修改前后的值
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// writeAudit 写入审计日志，写入失败只记录错误，不影响主流程
+func writeAudit(ctx context.Context, actor, target, action, detail string) {
+	writeAuditDiff(ctx, actor, target, action, detail, nil, nil)
+}
+
+// writeAuditDiff 写入带字段变更的审计日志，只记录 after 中与 before 不同的字段，before 为空表示新建
+func writeAuditDiff(ctx context.Context, actor, target, action, detail string, before, after map[string]interface{}) {
+	uuid, _ := ctx.Value(static.ReqUuid).(string)
+	entry := &model.AuditLog{
+		TenantID:  tenantFromCtx(ctx),
+		Actor:     actor,
+		Target:    target,
+		Action:    action,
+		Detail:    detail,
+		Diff:      auditDiff(before, after),
+		RequestID: uuid,
+		IP:        clientIPFromCtx(ctx),
+	}
+	if err := dao.CreateAuditLog(entry); err != nil {
+		log.Errorf("%s|writeAudit failed, action=%s|actor=%s|target=%s|err=%v", uuid, action, actor, target, err)
+	}
+}
+
+// auditDiff 计算字段变更，没有变更时返回空串
+func auditDiff(before, after map[string]interface{}) string {
+	changes := make(map[string]auditChange)
+	for field, value := range after {
+		old, ok := before[field]
+		if ok && fmt.Sprint(old) == fmt.Sprint(value) {
+			continue
+		}
+		changes[field] = auditChange{Before: old, After: value}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	// map 按键排序序列化，同样的变更得到同样的内容
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// userAuditFields 参与审计的用户字段，键与 t_user 的列名一致，密码不记录
+func userAuditFields(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"nickname":           user.NickName,
+		"age":                user.Age,
+		"gender":             user.Gender,
+		"status":             user.Status,
+		"pwd_reset_required": user.PwdResetRequired,
+	}
+}
+
+// revokeUserSessions 踢掉用户的全部会话，记录审计日志并为每个会话写入 session.revoked 事件
+func revokeUserSessions(ctx context.Context, actor string, tenantID int, userName, reason string) error {
+	sessions, err := cache.ListUserSessions(tenantID, userName)
+	if err != nil {
+		log.Warnf("revokeUserSessions|list sessions failed for user:%s with err:%v", userName, err)
+	}
+	if err := cache.DelUserSessions(tenantID, userName); err != nil {
+		return err
+	}
+	writeAudit(ctx, actor, userName, model.AuditSessionRevoke, "reason="+reason)
+	for _, session := range sessions {
+		emitSessionEvent(tenantID, model.EventSessionRevoked, userName, session, map[string]interface{}{"reason": reason})
+	}
+	return nil
+}
+
+// collectAuditLogs 收集与用户相关的审计日志
+func collectAuditLogs(user *model.User) (interface{}, error) {
+	return dao.ListAuditLogsByUser(user.TenantID, user.Name)
+}
+
+func toAuditLogInfo(entry *model.AuditLog) *AuditLogInfo {
+	info := &AuditLogInfo{
+		ID:         entry.ID,
+		Actor:      entry.Actor,
+		Target:     entry.Target,
+		Action:     entry.Action,
+		Detail:     entry.Detail,
+		RequestID:  entry.RequestID,
+		IP:         entry.IP,
+		Hash:       entry.Hash,
+		CreateTime: entry.CreateTime.Unix(),
+	}
+	if entry.Diff != "" {
+		info.Diff = json.RawMessage(entry.Diff)
+	}
+	return info
+}
+
+// ListAuditLogs 管理后台按条件查询当前租户的审计日志，最新的在前
+func ListAuditLogs(ctx context.Context, req *ListAuditLogsRequest) (*ListAuditLogsResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	log.Infof("%s|ListAuditLogs access from operator=%s|req=%+v", uuid, operator, req)
+
+	filter := &dao.AuditFilter{
+		TenantID:  tenantFromCtx(ctx),
+		Cursor:    req.Cursor,
+		Limit:     req.Limit,
+		Actor:     req.Actor,
+		Target:    req.Target,
+		Action:    req.Action,
+		RequestID: req.RequestID,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if req.CreatedAfter > 0 {
+		filter.CreatedAfter = time.Unix(req.CreatedAfter, 0)
+	}
+	if req.CreatedBefore > 0 {
+		filter.CreatedBefore = time.Unix(req.CreatedBefore, 0)
+	}
+
+	entries, err := dao.ListAuditLogs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("ListAuditLogs|%v", err)
+	}
+	rsp := &ListAuditLogsResponse{Logs: make([]*AuditLogInfo, 0, len(entries))}
+	for _, entry := range entries {
+		rsp.Logs = append(rsp.Logs, toAuditLogInfo(entry))
+	}
+	if len(entries) == filter.Limit {
+		rsp.NextCursor = entries[len(entries)-1].ID
+	}
+	return rsp, nil
+}
+
+// VerifyAuditChain 从头校验当前租户的审计日志哈希链。哈希链上线之前的记录没有哈希，跳过不校验
+func VerifyAuditChain(ctx context.Context) (*VerifyAuditChainResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	tenantID := tenantFromCtx(ctx)
+
+	rsp := &VerifyAuditChainResponse{Valid: true}
+	prevHash, afterID := "", 0
+	for rsp.Valid {
+		entries, err := dao.ScanAuditLogs(tenantID, afterID, auditVerifyBatch)
+		if err != nil {
+			return nil, fmt.Errorf("VerifyAuditChain|%v", err)
+		}
+		for _, entry := range entries {
+			afterID = entry.ID
+			if entry.Hash == "" && rsp.Checked == 0 {
+				continue
+			}
+			if entry.PrevHash != prevHash || entry.ComputeHash() != entry.Hash {
+				rsp.Valid, rsp.BrokenID = false, entry.ID
+				break
+			}
+			prevHash = entry.Hash
+			rsp.Checked++
+		}
+		if len(entries) < auditVerifyBatch {
+			break
+		}
+	}
+
+	// 链尾记录的哈希与最后一条日志不一致，说明末尾的日志被删除
+	if rsp.Valid {
+		head, err := dao.GetAuditChainHead(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("VerifyAuditChain|%v", err)
+		}
+		if head != nil && head.LastHash != prevHash {
+			rsp.Valid, rsp.TailMismatch = false, true
+		}
+	}
+	log.Infof("%s|VerifyAuditChain done, tenant_id=%d|operator=%s|result=%+v", uuid, tenantID, operator, rsp)
+	return rsp, nil
+}
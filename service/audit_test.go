@@ -0,0 +1,19 @@
+package service
+
+import "testing"
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]interface{}{"nickname": "old", "age": 18, "status": 0}
+	after := map[string]interface{}{"nickname": "new", "age": 18, "status": 1}
+	want := `{"nickname":{"before":"old","after":"new"},"status":{"before":0,"after":1}}`
+	if got := auditDiff(before, after); got != want {
+		t.Errorf("auditDiff = %s, want %s", got, want)
+	}
+	if got := auditDiff(before, before); got != "" {
+		t.Errorf("auditDiff without changes = %s, want empty", got)
+	}
+	// 新建时没有修改前的值
+	if got := auditDiff(nil, map[string]interface{}{"role": "admin"}); got != `{"role":{"before":null,"after":"admin"}}` {
+		t.Errorf("auditDiff for creation = %s", got)
+	}
+}
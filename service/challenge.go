@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"math/big"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChallengeRequired 需要先完成人机校验
+	ErrChallengeRequired = errors.New("需要完成人机校验")
+	// ErrChallengeFailed 人机校验未通过或已过期
+	ErrChallengeFailed = errors.New("人机校验未通过，请重试")
+)
+
+const (
+	defaultChallengeExpired   = 300
+	defaultLoginFailThreshold = 3
+	defaultLoginFailWindow    = 900
+	defaultPowDifficulty      = 16
+	// maxPowDifficulty 工作量证明难度上限，避免配置错误导致页面无法完成计算
+	maxPowDifficulty = 28
+	// maxPowAnswerLen 工作量证明答案的最大长度
+	maxPowAnswerLen = 32
+)
+
+// 需要人机校验的场景
+const (
+	ChallengeSceneRegister = "register"
+	ChallengeSceneLogin    = "login"
+)
+
+// ChallengeVerifier 人机校验方式。新增校验方式实现该接口并通过 registerChallengeVerifier 登记
+type ChallengeVerifier interface {
+	// Issue 生成挑战，返回下发给页面的内容和服务端保存的预期答案
+	Issue() (*ChallengeInfo, string, error)
+	// Verify 校验页面提交的答案，expected 为 Issue 时保存的内容
+	Verify(expected, answer string) bool
+}
+
+var challengeVerifiers = make(map[string]ChallengeVerifier)
+
+// registerChallengeVerifier 登记人机校验方式
+func registerChallengeVerifier(name string, verifier ChallengeVerifier) {
+	challengeVerifiers[name] = verifier
+}
+
+func init() {
+	registerChallengeVerifier(static.ChallengeCaptcha, captchaVerifier{})
+	registerChallengeVerifier(static.ChallengePow, powVerifier{})
+}
+
+// storedChallenge Redis 中保存的挑战
+type storedChallenge struct {
+	Type     string `json:"type"`
+	Expected string `json:"expected"`
+}
+
+// captchaVerifier 图片算术验证码
+type captchaVerifier struct{}
+
+func (captchaVerifier) Issue() (*ChallengeInfo, string, error) {
+	a, b := randInt(10, 50), randInt(1, 10)
+	question, answer := fmt.Sprintf("%d+%d=?", a, b), a+b
+	if randInt(0, 2) == 1 {
+		question, answer = fmt.Sprintf("%d-%d=?", a, b), a-b
+	}
+	img, err := utils.RenderCaptcha(question)
+	if err != nil {
+		return nil, "", err
+	}
+	info := &ChallengeInfo{Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(img)}
+	return info, strconv.Itoa(answer), nil
+}
+
+func (captchaVerifier) Verify(expected, answer string) bool {
+	return strings.TrimSpace(answer) == expected
+}
+
+// powVerifier 工作量证明：页面需要找到 answer，使 sha256(nonce+answer) 的前 difficulty 个比特为 0
+type powVerifier struct{}
+
+func (powVerifier) Issue() (*ChallengeInfo, string, error) {
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	difficulty := powDifficulty()
+	info := &ChallengeInfo{Nonce: nonce, Difficulty: difficulty}
+	return info, fmt.Sprintf("%s:%d", nonce, difficulty), nil
+}
+
+func (powVerifier) Verify(expected, answer string) bool {
+	nonce, difficultyStr, ok := strings.Cut(expected, ":")
+	difficulty, err := strconv.Atoi(difficultyStr)
+	if !ok || err != nil || answer == "" || len(answer) > maxPowAnswerLen {
+		return false
+	}
+	sum := sha256.Sum256([]byte(nonce + answer))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+// leadingZeroBits 计算前导零比特数
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, v := range b {
+		if v == 0 {
+			n += 8
+			continue
+		}
+		for mask := byte(0x80); mask != 0 && v&mask == 0; mask >>= 1 {
+			n++
+		}
+		break
+	}
+	return n
+}
+
+func powDifficulty() int {
+	difficulty := conf.GetGlobalConfig().Challenge.PowDifficulty
+	if difficulty <= 0 {
+		difficulty = defaultPowDifficulty
+	}
+	if difficulty > maxPowDifficulty {
+		difficulty = maxPowDifficulty
+	}
+	return difficulty
+}
+
+// randInt 返回 [min, max) 内的随机数
+func randInt(min, max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)))
+	if err != nil {
+		return min
+	}
+	return min + int(n.Int64())
+}
+
+// challengeEnabled 是否开启人机校验
+func challengeEnabled() bool {
+	return conf.GetGlobalConfig().Challenge.Enabled
+}
+
+// challengeType 配置的人机校验方式，未配置或不支持时为图片验证码
+func challengeType() string {
+	if typ := conf.GetGlobalConfig().Challenge.Type; challengeVerifiers[typ] != nil {
+		return typ
+	}
+	return static.ChallengeCaptcha
+}
+
+// loginFailThreshold 登录失败多少次后需要人机校验
+func loginFailThreshold() int {
+	if threshold := conf.GetGlobalConfig().Challenge.LoginFailThreshold; threshold > 0 {
+		return threshold
+	}
+	return defaultLoginFailThreshold
+}
+
+// IssueChallenge 按场景下发挑战。登录场景下失败次数未达到阈值时返回 Required=false
+func IssueChallenge(ctx context.Context, req *ChallengeRequest) (*ChallengeResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	switch req.Scene {
+	case ChallengeSceneRegister:
+		if !challengeEnabled() {
+			return &ChallengeResponse{}, nil
+		}
+	case ChallengeSceneLogin:
+		if !loginChallengeRequired(ctx, req.UserName) {
+			return &ChallengeResponse{}, nil
+		}
+	default:
+		return nil, fmt.Errorf("IssueChallenge|unknown scene %s", req.Scene)
+	}
+
+	typ := challengeType()
+	info, expected, err := challengeVerifiers[typ].Issue()
+	if err != nil {
+		log.Errorf("%s|IssueChallenge|issue %s challenge err:%v", uuid, typ, err)
+		return nil, fmt.Errorf("IssueChallenge|issue challenge err:%v", err)
+	}
+	id, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("IssueChallenge|generate id err:%v", err)
+	}
+	val, err := json.Marshal(&storedChallenge{Type: typ, Expected: expected})
+	if err != nil {
+		return nil, err
+	}
+	expired := conf.GetGlobalConfig().Challenge.Expired
+	if expired <= 0 {
+		expired = defaultChallengeExpired
+	}
+	if err := cache.SetChallenge(id, string(val), time.Duration(expired)*time.Second); err != nil {
+		log.Errorf("%s|IssueChallenge|save challenge err:%v", uuid, err)
+		return nil, fmt.Errorf("IssueChallenge|save challenge err:%v", err)
+	}
+	info.ID, info.Type = id, typ
+	return &ChallengeResponse{Required: true, Challenge: info}, nil
+}
+
+// verifyChallenge 校验挑战答案，挑战无论成功与否只能使用一次
+func verifyChallenge(id, answer string) error {
+	if id == "" {
+		return ErrChallengeRequired
+	}
+	val, err := cache.TakeChallenge(id)
+	if err != nil {
+		if !cache.IsMiss(err) {
+			log.Errorf("verifyChallenge|take challenge err:%v", err)
+		}
+		return ErrChallengeFailed
+	}
+	stored := &storedChallenge{}
+	if err := json.Unmarshal([]byte(val), stored); err != nil {
+		return ErrChallengeFailed
+	}
+	verifier, ok := challengeVerifiers[stored.Type]
+	if !ok || !verifier.Verify(stored.Expected, answer) {
+		return ErrChallengeFailed
+	}
+	return nil
+}
+
+// clientIPFromCtx 请求来源 IP
+func clientIPFromCtx(ctx context.Context) string {
+	ip, _ := ctx.Value(static.ClientIPKey).(string)
+	return ip
+}
+
+// loginChallengeRequired 同一账号或同一 IP 登录失败次数达到阈值后需要人机校验。Redis 不可用时不要求
+func loginChallengeRequired(ctx context.Context, userName string) bool {
+	if !challengeEnabled() {
+		return false
+	}
+	tenantID, threshold := tenantFromCtx(ctx), loginFailThreshold()
+	for _, subject := range []string{"user:" + userName, "ip:" + clientIPFromCtx(ctx)} {
+		n, err := cache.GetLoginFail(tenantID, subject)
+		if err != nil {
+			log.Warnf("loginChallengeRequired|get login fail count err:%v", err)
+			continue
+		}
+		if n >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// recordLoginFailure 记录登录失败
+func recordLoginFailure(ctx context.Context, userName string) {
+	if !challengeEnabled() {
+		return
+	}
+	window := conf.GetGlobalConfig().Challenge.LoginFailWindow
+	if window <= 0 {
+		window = defaultLoginFailWindow
+	}
+	tenantID := tenantFromCtx(ctx)
+	for _, subject := range []string{"user:" + userName, "ip:" + clientIPFromCtx(ctx)} {
+		if err := cache.IncrLoginFail(tenantID, subject, time.Duration(window)*time.Second); err != nil {
+			log.Warnf("recordLoginFailure|incr login fail count err:%v", err)
+		}
+	}
+}
+
+// resetLoginFailure 登录成功后清零账号的失败次数，IP 的失败次数保留到窗口结束
+func resetLoginFailure(ctx context.Context, userName string) {
+	if !challengeEnabled() {
+		return
+	}
+	if err := cache.ResetLoginFail(tenantFromCtx(ctx), "user:"+userName); err != nil {
+		log.Warnf("resetLoginFailure|reset login fail count err:%v", err)
+	}
+}
@@ -1,8 +1,14 @@
 package service
 
+import "encoding/json"
+
 type LoginRequest struct {
 	UserName string `json:"user_name"`
 	PassWord string `json:"pass_word"`
+	Remember bool   `json:"remember"` // 记住我，签发有效期更长的会话
+	// 登录失败次数过多后需要带上人机校验的挑战和答案
+	ChallengeID     string `json:"challenge_id"`
+	ChallengeAnswer string `json:"challenge_answer"`
 }
 type RegisterRequest struct {
 	UserName string `json:"user_name"`
@@ -10,6 +16,11 @@ type RegisterRequest struct {
 	Age      int    `json:"age"`
 	Gender   string `json:"gender"`
 	NickName string `json:"nick_name"`
+	// InviteCode 邀请码，invite_only 模式下必填
+	InviteCode string `json:"invite_code"`
+	// 人机校验的挑战和答案，开启人机校验时必填
+	ChallengeID     string `json:"challenge_id"`
+	ChallengeAnswer string `json:"challenge_answer"`
 }
 type LogoutRequest struct {
 	UserName string `json:"user_name"`
@@ -26,8 +37,34 @@ type GetUserInfoResponse struct {
 	UserName string `json:"user_name"`
 	Age      int    `json:"age"`
 	Gender   string `json:"gender"`
-	PassWord string `json:"pass_word"`
 	NickName string `json:"nick_name"`
+	// Impersonated 当前会话是否为管理员模拟登录，Impersonator 为发起模拟的管理员
+	Impersonated bool   `json:"impersonated"`
+	Impersonator string `json:"impersonator,omitempty"`
+}
+
+// ValidateSessionResponse 会话校验结果，valid 为 false 时其余字段为空
+type ValidateSessionResponse struct {
+	Valid        bool   `json:"valid"`
+	UserName     string `json:"user_name,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // 会话剩余有效期，单位秒
+	Impersonated bool   `json:"impersonated,omitempty"`
+	Impersonator string `json:"impersonator,omitempty"`
+}
+
+// IntrospectResponse 令牌内省结果，字段名参照 RFC 7662，active 为 false 时其余字段为空
+type IntrospectResponse struct {
+	Active       bool     `json:"active"`
+	TokenType    string   `json:"token_type,omitempty"` // 令牌类型，会话 session 或 OAuth 访问令牌 access_token
+	Sub          string   `json:"sub,omitempty"`        // 用户ID，客户端凭证方式签发的访问令牌为 client_id
+	UserID       int      `json:"user_id,omitempty"`
+	UserName     string   `json:"username,omitempty"`
+	TenantID     int      `json:"tenant_id,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	Exp          int64    `json:"exp,omitempty"`          // 过期时间戳，会话续期后会延后
+	Impersonator string   `json:"impersonator,omitempty"` // 模拟登录时为发起模拟的管理员
+	ClientID     string   `json:"client_id,omitempty"`    // 访问令牌所属的客户端
+	Scope        string   `json:"scope,omitempty"`        // 访问令牌的 scope，空格分隔
 }
 
 // UpdateNickNameRequest 修改用户信息返回结构
@@ -35,3 +72,487 @@ type UpdateNickNameRequest struct {
 	UserName    string `json:"user_name"`
 	NewNickName string `json:"new_nick_name"`
 }
+
+// CreateExportResponse 申请数据导出返回结构
+type CreateExportResponse struct {
+	JobID int `json:"job_id"`
+}
+
+// GetExportStatusRequest 查询导出任务状态请求
+type GetExportStatusRequest struct {
+	JobID int `json:"job_id" form:"job_id"`
+}
+
+// GetExportStatusResponse 查询导出任务状态返回结构
+type GetExportStatusResponse struct {
+	JobID       int    `json:"job_id"`
+	Status      string `json:"status"`
+	DownloadURL string `json:"download_url,omitempty"`
+	ExpireTime  int64  `json:"expire_time,omitempty"`
+	ErrMsg      string `json:"err_msg,omitempty"`
+}
+
+// GetExportPublicKeyResponse 导出包验签公钥返回结构
+type GetExportPublicKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // base64 编码的公钥
+}
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	RoleName    string `json:"role_name"`
+	Description string `json:"description"`
+}
+
+// RolePermissionRequest 角色授权/收回权限请求
+type RolePermissionRequest struct {
+	RoleName   string `json:"role_name"`
+	Permission string `json:"permission"`
+}
+
+// UserRoleRequest 给用户分配/取消角色请求
+type UserRoleRequest struct {
+	UserName string `json:"user_name"`
+	RoleName string `json:"role_name"`
+}
+
+// RoleInfo 角色信息
+type RoleInfo struct {
+	RoleName    string   `json:"role_name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// GetUserRolesRequest 查询用户角色请求
+type GetUserRolesRequest struct {
+	UserName string `json:"user_name" form:"user_name"`
+}
+
+// GetUserRolesResponse 查询用户角色返回结构
+type GetUserRolesResponse struct {
+	UserName    string   `json:"user_name"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	UserName    string `json:"user_name"`
+	PassWord    string `json:"pass_word"`
+	NewPassWord string `json:"new_pass_word"`
+	// 与登录共用失败次数，失败过多后需要带上 login 场景的挑战和答案
+	ChallengeID     string `json:"challenge_id"`
+	ChallengeAnswer string `json:"challenge_answer"`
+}
+
+// ListUsersRequest 管理后台查询用户列表请求，created_after/created_before 为秒级时间戳
+type ListUsersRequest struct {
+	Cursor        int    `json:"cursor" form:"cursor"`
+	Limit         int    `json:"limit" form:"limit"`
+	NamePrefix    string `json:"name_prefix" form:"name_prefix"`
+	Gender        string `json:"gender" form:"gender"`
+	MinAge        int    `json:"min_age" form:"min_age"`
+	MaxAge        int    `json:"max_age" form:"max_age"`
+	CreatedAfter  int64  `json:"created_after" form:"created_after"`
+	CreatedBefore int64  `json:"created_before" form:"created_before"`
+}
+
+// AdminUserInfo 管理后台展示的用户信息
+type AdminUserInfo struct {
+	ID               int    `json:"id"`
+	UserName         string `json:"user_name"`
+	NickName         string `json:"nick_name"`
+	Gender           string `json:"gender"`
+	Age              int    `json:"age"`
+	Status           int    `json:"status"`
+	PwdResetRequired bool   `json:"pwd_reset_required"`
+	Inviter          string `json:"inviter"`
+	Creator          string `json:"creator"`
+	CreateTime       int64  `json:"create_time"`
+	Modifier         string `json:"modifier"`
+	ModifyTime       int64  `json:"modify_time"`
+}
+
+// ListUsersResponse 管理后台查询用户列表返回结构，next_cursor 为 0 表示没有更多数据
+type ListUsersResponse struct {
+	Users      []*AdminUserInfo `json:"users"`
+	NextCursor int              `json:"next_cursor"`
+}
+
+// AdminUserRequest 管理后台针对单个用户的请求
+type AdminUserRequest struct {
+	UserName string `json:"user_name" form:"user_name"`
+}
+
+// AdminUpdateUserRequest 管理后台修改用户资料请求，空值字段不修改
+type AdminUpdateUserRequest struct {
+	UserName string `json:"user_name"`
+	NickName string `json:"nick_name"`
+	Age      int    `json:"age"`
+	Gender   string `json:"gender"`
+}
+
+// ImpersonateRequest 开始模拟登录请求
+type ImpersonateRequest struct {
+	UserName string `json:"user_name"`
+}
+
+// DeleteAccountRequest 注销账号请求
+type DeleteAccountRequest struct {
+	UserName string `json:"user_name"`
+	PassWord string `json:"pass_word"`
+}
+
+// CreateOrgRequest 创建组织请求
+type CreateOrgRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Host string `json:"host"`
+}
+
+// OrgInfo 组织信息
+type OrgInfo struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Host string `json:"host"`
+}
+
+// OrgRequest 针对单个组织的请求，code 为空表示当前租户
+type OrgRequest struct {
+	Code string `json:"code" form:"code"`
+}
+
+// OrgMemberInfo 组织成员信息
+type OrgMemberInfo struct {
+	UserName string `json:"user_name"`
+	Role     string `json:"role"`
+}
+
+// SetOrgMemberRequest 设置组织成员角色请求
+type SetOrgMemberRequest struct {
+	Code     string `json:"code"`
+	UserName string `json:"user_name"`
+	Role     string `json:"role"`
+}
+
+// FlushTenantCacheResponse 清理租户缓存返回结构
+type FlushTenantCacheResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// CreateInvitationRequest 生成邀请码请求，max_uses 为 0 时按单次使用处理，expire_in 为 0 表示不过期
+type CreateInvitationRequest struct {
+	MaxUses  int      `json:"max_uses"`
+	ExpireIn int      `json:"expire_in"` // 有效期，单位秒
+	Roles    []string `json:"roles"`     // 注册后自动分配的角色
+	Remark   string   `json:"remark"`
+}
+
+// InvitationInfo 邀请码信息
+type InvitationInfo struct {
+	Code       string   `json:"code"`
+	Inviter    string   `json:"inviter"`
+	MaxUses    int      `json:"max_uses"`
+	UsedCount  int      `json:"used_count"`
+	ExpireTime int64    `json:"expire_time"` // 0 表示不过期
+	Roles      []string `json:"roles"`
+	Revoked    bool     `json:"revoked"`
+	Remark     string   `json:"remark"`
+	CreateTime int64    `json:"create_time"`
+}
+
+// RevokeInvitationRequest 撤销邀请码请求
+type RevokeInvitationRequest struct {
+	Code string `json:"code"`
+}
+
+// HealthResponse 健康检查返回结构
+type HealthResponse struct {
+	Status  string `json:"status"`  // ok/degraded/down
+	Redis   string `json:"redis"`   // up/down
+	DB      string `json:"db"`      // up/down
+	Breaker string `json:"breaker"` // Redis 熔断器状态
+}
+
+// ChallengeRequest 获取人机校验挑战请求
+type ChallengeRequest struct {
+	Scene    string `json:"scene" form:"scene"`         // register/login
+	UserName string `json:"user_name" form:"user_name"` // 登录场景下的用户名，用于判断是否需要校验
+}
+
+// ChallengeInfo 下发给页面的挑战
+type ChallengeInfo struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`                 // captcha/pow
+	Image      string `json:"image,omitempty"`      // 图片验证码，data URI
+	Nonce      string `json:"nonce,omitempty"`      // 工作量证明的随机串
+	Difficulty int    `json:"difficulty,omitempty"` // 工作量证明要求的前导零比特数
+}
+
+// ChallengeResponse 获取人机校验挑战响应
+type ChallengeResponse struct {
+	Required  bool           `json:"required"` // 是否需要校验，不需要时 challenge 为空
+	Challenge *ChallengeInfo `json:"challenge,omitempty"`
+}
+
+// LoginHistoryRequest 查询当前用户登录记录请求
+type LoginHistoryRequest struct {
+	Cursor int `json:"cursor" form:"cursor"`
+	Limit  int `json:"limit" form:"limit"`
+}
+
+// AdminLoginHistoryRequest 管理后台查询用户登录记录请求
+type AdminLoginHistoryRequest struct {
+	UserName string `json:"user_name" form:"user_name"`
+	Cursor   int    `json:"cursor" form:"cursor"`
+	Limit    int    `json:"limit" form:"limit"`
+}
+
+// LoginHistoryItem 一条登录记录，result 为 success 或失败原因，create_time 为秒级时间戳
+type LoginHistoryItem struct {
+	ID         int    `json:"id"`
+	Success    bool   `json:"success"`
+	Result     string `json:"result"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	NewDevice  bool   `json:"new_device"`
+	CreateTime int64  `json:"create_time"`
+}
+
+// LoginHistoryResponse 登录记录列表，next_cursor 为 0 表示没有更多数据
+type LoginHistoryResponse struct {
+	Items      []*LoginHistoryItem `json:"items"`
+	NextCursor int                 `json:"next_cursor"`
+}
+
+// ListAuditLogsRequest 管理后台查询审计日志请求，action 按前缀匹配，created_after/created_before 为秒级时间戳
+type ListAuditLogsRequest struct {
+	Cursor        int    `json:"cursor" form:"cursor"`
+	Limit         int    `json:"limit" form:"limit"`
+	Actor         string `json:"actor" form:"actor"`
+	Target        string `json:"target" form:"target"`
+	Action        string `json:"action" form:"action"`
+	RequestID     string `json:"request_id" form:"request_id"`
+	CreatedAfter  int64  `json:"created_after" form:"created_after"`
+	CreatedBefore int64  `json:"created_before" form:"created_before"`
+}
+
+// AuditLogInfo 一条审计日志，diff 为变更字段修改前后的值
+type AuditLogInfo struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Target     string          `json:"target"`
+	Action     string          `json:"action"`
+	Detail     string          `json:"detail"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	Hash       string          `json:"hash"`
+	CreateTime int64           `json:"create_time"`
+}
+
+// ListAuditLogsResponse 审计日志列表，next_cursor 为 0 表示没有更多数据
+type ListAuditLogsResponse struct {
+	Logs       []*AuditLogInfo `json:"logs"`
+	NextCursor int             `json:"next_cursor"`
+}
+
+// VerifyAuditChainResponse 哈希链校验结果。broken_id 为第一条校验失败的记录，tail_mismatch 表示末尾的记录被删除
+type VerifyAuditChainResponse struct {
+	Valid        bool `json:"valid"`
+	Checked      int  `json:"checked"`
+	BrokenID     int  `json:"broken_id,omitempty"`
+	TailMismatch bool `json:"tail_mismatch,omitempty"`
+}
+
+// CreateWebhookRequest 创建 Webhook 订阅请求。events 支持具体的事件类型、* 和 user.* 形式的前缀，secret 为空时自动生成
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret"`
+	Description string   `json:"description"`
+}
+
+// UpdateWebhookRequest 修改 Webhook 订阅请求，空值字段不修改，rotate_secret 为 true 时生成新的签名密钥
+type UpdateWebhookRequest struct {
+	ID           int      `json:"id"`
+	URL          string   `json:"url"`
+	Events       []string `json:"events"`
+	Description  string   `json:"description"`
+	Enabled      *bool    `json:"enabled"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// DeleteWebhookRequest 删除 Webhook 订阅请求
+type DeleteWebhookRequest struct {
+	ID int `json:"id"`
+}
+
+// WebhookInfo Webhook 订阅信息，secret 只在创建和重新生成密钥时返回
+type WebhookInfo struct {
+	ID          int      `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	Description string   `json:"description"`
+	Creator     string   `json:"creator"`
+	CreateTime  int64    `json:"create_time"`
+}
+
+// ListWebhookDeliveriesRequest 查询投递日志请求
+type ListWebhookDeliveriesRequest struct {
+	SubscriptionID int    `json:"subscription_id" form:"subscription_id"`
+	Status         string `json:"status" form:"status"` // pending/succeeded/dead
+	EventID        string `json:"event_id" form:"event_id"`
+	Cursor         int    `json:"cursor" form:"cursor"`
+	Limit          int    `json:"limit" form:"limit"`
+}
+
+// WebhookDeliveryInfo 一条投递日志，response_code 为 0 表示请求没有收到响应
+type WebhookDeliveryInfo struct {
+	ID              int    `json:"id"`
+	SubscriptionID  int    `json:"subscription_id"`
+	EventID         string `json:"event_id"`
+	EventType       string `json:"event_type"`
+	Status          string `json:"status"`
+	Attempts        int    `json:"attempts"`
+	ResponseCode    int    `json:"response_code"`
+	ResponseBody    string `json:"response_body"`
+	LastError       string `json:"last_error"`
+	NextAttemptTime int64  `json:"next_attempt_time"`
+	DeliveredTime   int64  `json:"delivered_time"` // 0 表示还未投递成功
+	CreateTime      int64  `json:"create_time"`
+	ModifyTime      int64  `json:"modify_time"`
+}
+
+// ListWebhookDeliveriesResponse 投递日志列表，next_cursor 为 0 表示没有更多数据
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []*WebhookDeliveryInfo `json:"deliveries"`
+	NextCursor int                    `json:"next_cursor"`
+}
+
+// RedeliverWebhookRequest 手动重新投递请求，死信和已成功的记录都可以重新投递
+type RedeliverWebhookRequest struct {
+	DeliveryID int `json:"delivery_id"`
+}
+
+// CreateOAuthClientRequest 注册 OAuth 客户端请求，grant_types 为空时为 authorization_code 和 refresh_token，
+// scopes 为空时允许申请全部支持的 scope。公开客户端没有密钥，不能使用 client_credentials
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Public       bool     `json:"public"`
+	Description  string   `json:"description"`
+}
+
+// UpdateOAuthClientRequest 修改 OAuth 客户端请求，空值字段不修改，rotate_secret 为 true 时生成新的密钥
+type UpdateOAuthClientRequest struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Description  string   `json:"description"`
+	Enabled      *bool    `json:"enabled"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// OAuthClientInfo OAuth 客户端信息，client_secret 只在注册和重新生成密钥时返回
+type OAuthClientInfo struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Public       bool     `json:"public"`
+	Enabled      bool     `json:"enabled"`
+	Description  string   `json:"description"`
+	Creator      string   `json:"creator"`
+	CreateTime   int64    `json:"create_time"`
+}
+
+// OAuthAuthorizeRequest 授权请求参数，授权地址、确认页查询和确认授权共用
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"` // 只支持 S256
+}
+
+// OAuthAuthorizeResult 授权地址的处理结果，三个字段只有一个有效
+type OAuthAuthorizeResult struct {
+	RedirectURL string // 跳转回应用，已授权时带 code，出错时带 error
+	NeedLogin   bool   // 未登录，跳转到登录页
+	NeedConsent bool   // 需要用户确认授权，跳转到确认页
+}
+
+// OAuthScopeInfo 授权确认页展示的 scope
+type OAuthScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// OAuthConsentInfo 授权确认页需要展示的信息
+type OAuthConsentInfo struct {
+	ClientID   string            `json:"client_id"`
+	ClientName string            `json:"client_name"`
+	UserName   string            `json:"user_name"`
+	Scopes     []*OAuthScopeInfo `json:"scopes"`
+}
+
+// OAuthConsentRequest 用户在确认页同意或拒绝授权
+type OAuthConsentRequest struct {
+	OAuthAuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// OAuthConsentResponse 确认授权后页面需要跳转的地址
+type OAuthConsentResponse struct {
+	RedirectURL string `json:"redirect_url"`
+}
+
+// OAuthTokenRequest 令牌请求，客户端凭证也可以通过 HTTP Basic 认证传递
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenResponse 令牌响应，字段名与 RFC 6749 一致
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthRevokeRequest 吊销令牌请求，字段名与 RFC 7009 一致
+type OAuthRevokeRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// OAuthUserInfo 访问令牌对应的用户信息，按授权的 scope 返回字段
+type OAuthUserInfo struct {
+	Sub      string   `json:"sub"`
+	UserName string   `json:"username,omitempty"`
+	NickName string   `json:"nickname,omitempty"`
+	Gender   string   `json:"gender,omitempty"`
+	Age      int      `json:"age,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// exportJobTimeout 导出任务的最长执行时间，超过后视为失败，进程在执行中退出的任务不会一直占着名额
+const exportJobTimeout = 30 * time.Minute
+
+// exportSignKey 导出包签名私钥，为空时导出包不签名
+var exportSignKey ed25519.PrivateKey
+
+// InitExport 加载导出包的 Ed25519 签名私钥，配置不合法时拒绝启动。
+// 用户用 /user/export/public_key 公布的公钥校验 data.json.sig，服务端不需要参与校验
+func InitExport() {
+	key := conf.GetGlobalConfig().Export.SignKey
+	if key == "" {
+		log.Warnf("export sign key not configured, export archives will not be signed")
+		return
+	}
+	seed, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		panic(fmt.Sprintf("export sign_key must be a base64 encoded %d byte ed25519 seed", ed25519.SeedSize))
+	}
+	exportSignKey = ed25519.NewKeyFromSeed(seed)
+}
+
+// GetExportPublicKey 返回校验导出包签名的公钥
+func GetExportPublicKey() (*GetExportPublicKeyResponse, error) {
+	if exportSignKey == nil {
+		return nil, fmt.Errorf("导出包未启用签名")
+	}
+	return &GetExportPublicKeyResponse{
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(exportSignKey.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+// exportFile 导出包中的一个文件
+type exportFile struct {
+	name    string
+	content []byte
+}
+
+// exportSection 导出包中的一个数据分区，collect 负责收集该分区的数据
+type exportSection struct {
+	name    string
+	collect func(user *model.User) (interface{}, error)
+}
+
+var exportSections []exportSection
+
+// registerExportSection 注册导出分区，新增的用户数据需要在这里登记才会出现在导出包中
+func registerExportSection(name string, collect func(user *model.User) (interface{}, error)) {
+	exportSections = append(exportSections, exportSection{name: name, collect: collect})
+}
+
+// 头像不单独作为分区导出：系统没有用户上传的头像，页面上展示的是所有用户共用的静态图片，
+// 不属于个人数据。以后支持上传头像时需要在这里登记 avatar 分区
+func init() {
+	registerExportSection("user", collectUserProfile)
+	registerExportSection("sessions", collectSessions)
+}
+
+// collectUserProfile 收集 t_user 中的用户资料，密码不导出
+func collectUserProfile(user *model.User) (interface{}, error) {
+	return map[string]interface{}{
+		"id":          user.ID,
+		"name":        user.Name,
+		"gender":      user.Gender,
+		"age":         user.Age,
+		"nick_name":   user.NickName,
+		"creator":     user.Creator,
+		"create_time": user.CreateTime,
+		"modifier":    user.Modifier,
+		"modify_time": user.ModifyTime,
+	}, nil
+}
+
+// collectSessions 收集用户当前有效的会话，只导出会话标识的摘要，导出包泄露时不能用来登录
+func collectSessions(user *model.User) (interface{}, error) {
+	list, err := cache.ListUserSessions(user.TenantID, user.Name)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]map[string]interface{}, 0, len(list))
+	for _, session := range list {
+		ttl, err := cache.GetSessionTTL(user.TenantID, session)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, map[string]interface{}{
+			"session":     sessionDigest(session),
+			"expire_time": time.Now().Add(ttl),
+		})
+	}
+	return sessions, nil
+}
+
+// CreateExport 申请导出当前登录用户的个人数据，导出任务异步执行
+func CreateExport(ctx context.Context) (*CreateExportResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	user, err := cache.GetSessionInfo(tenantFromCtx(ctx), session)
+	if err != nil {
+		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
+		return nil, fmt.Errorf("CreateExport|GetSessionInfo err:%v", err)
+	}
+
+	latest, err := dao.GetLatestExportJob(user.TenantID, user.Name)
+	if err != nil {
+		return nil, fmt.Errorf("CreateExport|%v", err)
+	}
+	if latest != nil && !failStaleExportJob(latest) {
+		if latest.Status == model.ExportStatusPending || latest.Status == model.ExportStatusRunning {
+			return nil, fmt.Errorf("已有导出任务正在进行，请稍后查询")
+		}
+		next := latest.CreateTime.Add(time.Duration(conf.GetGlobalConfig().Export.Interval) * time.Second)
+		if time.Now().Before(next) {
+			return nil, fmt.Errorf("导出申请过于频繁，请于 %s 之后再试", next.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	job := &model.ExportJob{
+		TenantID: user.TenantID,
+		UserName: user.Name,
+		Status:   model.ExportStatusPending,
+		CreateModel: model.CreateModel{
+			Creator: user.Name,
+		},
+		ModifyModel: model.ModifyModel{
+			Modifier: user.Name,
+		},
+	}
+	if err := dao.CreateExportJob(job); err != nil {
+		return nil, fmt.Errorf("CreateExport|%v", err)
+	}
+	log.Infof("%s|CreateExport success, user_name=%s|job_id=%d", uuid, user.Name, job.ID)
+
+	go runExportJob(job)
+	return &CreateExportResponse{JobID: job.ID}, nil
+}
+
+// failStaleExportJob 未完成的任务超过 exportJobTimeout 时标记为失败，返回是否做了标记
+func failStaleExportJob(job *model.ExportJob) bool {
+	if job.Status != model.ExportStatusPending && job.Status != model.ExportStatusRunning {
+		return false
+	}
+	if time.Since(job.CreateTime) < exportJobTimeout {
+		return false
+	}
+	log.Warnf("failStaleExportJob|job timeout, job_id=%d|status=%s", job.ID, job.Status)
+	dao.UpdateExportJob(job.ID, map[string]interface{}{
+		"status":  model.ExportStatusFailed,
+		"err_msg": "导出任务超时",
+	})
+	job.Status = model.ExportStatusFailed
+	job.ErrMsg = "导出任务超时"
+	return true
+}
+
+// runExportJob 执行导出任务，生成签名后的 zip 包。收集数据时 panic 也要把任务标记为失败
+func runExportJob(job *model.ExportJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("runExportJob|job_id=%d|panic:%v\n%s", job.ID, r, debug.Stack())
+			dao.UpdateExportJob(job.ID, map[string]interface{}{
+				"status":  model.ExportStatusFailed,
+				"err_msg": "导出任务执行异常",
+			})
+		}
+	}()
+	if err := dao.UpdateExportJob(job.ID, map[string]interface{}{"status": model.ExportStatusRunning}); err != nil {
+		return
+	}
+
+	filePath, err := buildExportArchive(job)
+	if err != nil {
+		log.Errorf("runExportJob|job_id=%d|err=%v", job.ID, err)
+		dao.UpdateExportJob(job.ID, map[string]interface{}{
+			"status":  model.ExportStatusFailed,
+			"err_msg": err.Error(),
+		})
+		return
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		log.Errorf("runExportJob|generate token err=%v", err)
+		os.Remove(filePath)
+		dao.UpdateExportJob(job.ID, map[string]interface{}{
+			"status":  model.ExportStatusFailed,
+			"err_msg": err.Error(),
+		})
+		return
+	}
+	expired := time.Duration(conf.GetGlobalConfig().Export.LinkExpired) * time.Second
+	dao.UpdateExportJob(job.ID, map[string]interface{}{
+		"status":         model.ExportStatusDone,
+		"file_path":      filePath,
+		"download_token": token,
+		"expire_time":    time.Now().Add(expired),
+	})
+	log.Infof("runExportJob success, job_id=%d|file=%s", job.ID, filePath)
+}
+
+// buildExportArchive 收集各分区数据并写入 zip 包，包内 data.json.sig 为 data.json 的 HMAC-SHA256 签名
+func buildExportArchive(job *model.ExportJob) (string, error) {
+	user, err := dao.GetUserByName(job.TenantID, job.UserName)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", fmt.Errorf("用户不存在")
+	}
+
+	data := map[string]interface{}{
+		"generated_at": time.Now(),
+	}
+	for _, section := range exportSections {
+		val, err := section.collect(user)
+		if err != nil {
+			return "", fmt.Errorf("collect %s err:%v", section.name, err)
+		}
+		data[section.name] = val
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := conf.GetGlobalConfig().Export.Dir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(dir, fmt.Sprintf("export_%d_%s.zip", job.ID, utils.Md5String(job.UserName+time.Now().String())))
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	files := []exportFile{{"data.json", content}}
+	// data.json.sig 为 data.json 的 Ed25519 签名，base64 编码
+	if exportSignKey != nil {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(exportSignKey, content))
+		files = append(files, exportFile{"data.json.sig", []byte(signature)})
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			os.Remove(filePath)
+			return "", err
+		}
+		if _, err := w.Write(file.content); err != nil {
+			os.Remove(filePath)
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
+
+// GetExportStatus 查询导出任务状态，任务完成后返回一次性下载链接
+func GetExportStatus(ctx context.Context, req *GetExportStatusRequest) (*GetExportStatusResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	user, err := cache.GetSessionInfo(tenantFromCtx(ctx), session)
+	if err != nil {
+		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
+		return nil, fmt.Errorf("GetExportStatus|GetSessionInfo err:%v", err)
+	}
+
+	job, err := dao.GetExportJobByID(req.JobID)
+	if err != nil {
+		return nil, fmt.Errorf("GetExportStatus|%v", err)
+	}
+	if job == nil || job.TenantID != user.TenantID || job.UserName != user.Name {
+		return nil, fmt.Errorf("导出任务不存在")
+	}
+
+	if job.Status == model.ExportStatusDone && time.Now().After(job.ExpireTime) {
+		expireExportJob(job)
+	}
+	failStaleExportJob(job)
+
+	rsp := &GetExportStatusResponse{
+		JobID:  job.ID,
+		Status: job.Status,
+		ErrMsg: job.ErrMsg,
+	}
+	if job.Status == model.ExportStatusDone {
+		rsp.DownloadURL = "/user/export/download?token=" + job.DownloadToken
+		rsp.ExpireTime = job.ExpireTime.Unix()
+	}
+	return rsp, nil
+}
+
+// DownloadExport 通过一次性凭证下载导出包，下载后文件即被删除
+func DownloadExport(ctx context.Context, token string) ([]byte, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	user, err := cache.GetSessionInfo(tenantFromCtx(ctx), session)
+	if err != nil {
+		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
+		return nil, fmt.Errorf("DownloadExport|GetSessionInfo err:%v", err)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("DownloadExport|request params invalid")
+	}
+
+	job, err := dao.GetExportJobByToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("DownloadExport|%v", err)
+	}
+	if job == nil || job.TenantID != user.TenantID || job.UserName != user.Name || job.Status != model.ExportStatusDone {
+		return nil, fmt.Errorf("下载链接无效")
+	}
+	if time.Now().After(job.ExpireTime) {
+		expireExportJob(job)
+		return nil, fmt.Errorf("下载链接已过期")
+	}
+
+	ok, err := dao.MarkExportDownloaded(job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("DownloadExport|%v", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("下载链接无效")
+	}
+	content, err := os.ReadFile(job.FilePath)
+	if err != nil {
+		log.Errorf("%s|DownloadExport read file err=%v", uuid, err)
+		return nil, fmt.Errorf("DownloadExport|read file err")
+	}
+	os.Remove(job.FilePath)
+	log.Infof("%s|DownloadExport success, user_name=%s|job_id=%d", uuid, user.Name, job.ID)
+	return content, nil
+}
+
+// expireExportJob 下载链接过期，删除导出文件
+func expireExportJob(job *model.ExportJob) {
+	os.Remove(job.FilePath)
+	dao.UpdateExportJob(job.ID, map[string]interface{}{"status": model.ExportStatusExpired})
+	job.Status = model.ExportStatusExpired
+}
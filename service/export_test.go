@@ -0,0 +1,43 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"my_user_system/conf"
+	"testing"
+)
+
+func TestExportSignatureVerifiesWithPublicKey(t *testing.T) {
+	exportConf := &conf.GetGlobalConfig().Export
+	saved, savedKey := *exportConf, exportSignKey
+	defer func() { *exportConf, exportSignKey = saved, savedKey }()
+	exportConf.SignKey = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+	InitExport()
+
+	rsp, err := GetExportPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := base64.StdEncoding.DecodeString(rsp.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte(`{"user":{}}`)
+	if !ed25519.Verify(pub, content, ed25519.Sign(exportSignKey, content)) {
+		t.Errorf("signature does not verify with the published public key")
+	}
+}
+
+func TestInitExportRejectsInvalidKey(t *testing.T) {
+	exportConf := &conf.GetGlobalConfig().Export
+	saved, savedKey := *exportConf, exportSignKey
+	defer func() { *exportConf, exportSignKey = saved, savedKey }()
+	exportConf.SignKey = "not-a-seed"
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("InitExport with an invalid key should panic")
+		}
+	}()
+	InitExport()
+}
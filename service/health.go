@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	cache "my_user_system/controllers"
+	"my_user_system/utils"
+	"time"
+)
+
+// 健康状态
+const (
+	HealthOK       = "ok"       // 全部依赖正常
+	HealthDegraded = "degraded" // Redis 不可用，降级运行
+	HealthDown     = "down"     // 数据库不可用，无法提供服务
+)
+
+// healthCheckTimeout 健康检查探测依赖的超时时间
+const healthCheckTimeout = time.Second
+
+// Health 检查 Redis 与数据库状态，Redis 熔断或不可用时报告降级
+func Health() *HealthResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	rsp := &HealthResponse{
+		Status:  HealthOK,
+		Redis:   "up",
+		DB:      "up",
+		Breaker: cache.BreakerState(),
+	}
+	if cache.Degraded() || utils.GetRedisCli().Ping(ctx).Err() != nil {
+		rsp.Redis = "down"
+		rsp.Status = HealthDegraded
+	}
+	sqlDB, err := utils.GetDB().DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		rsp.DB = "down"
+		rsp.Status = HealthDown
+	}
+	return rsp
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"time"
+)
+
+// isImpersonating 判断会话是否为模拟登录会话
+func isImpersonating(tenantID int, session string) bool {
+	if session == "" {
+		return false
+	}
+	_, err := cache.GetImpersonation(tenantID, session)
+	// Redis 不可用时无法确认，按模拟登录处理，拒绝敏感操作
+	return err == nil || !cache.IsMiss(err)
+}
+
+// IsImpersonating 判断会话是否为模拟登录会话。模拟登录会话带有目标用户的全部权限，不能使用管理接口
+func IsImpersonating(tenantID int, session string) bool {
+	return isImpersonating(tenantID, session)
+}
+
+// ImpersonateStart 管理员以目标用户身份登录，返回新的模拟登录会话及其有效期
+func ImpersonateStart(ctx context.Context, req *ImpersonateRequest) (string, time.Duration, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	actorSession := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	log.Infof("%s|ImpersonateStart access from operator=%s|target=%s", uuid, operator, req.UserName)
+
+	if req.UserName == "" {
+		return "", 0, fmt.Errorf("ImpersonateStart|request params invalid")
+	}
+	if req.UserName == operator {
+		return "", 0, fmt.Errorf("不能模拟自己登录")
+	}
+	if isImpersonating(tenantID, actorSession) {
+		return "", 0, fmt.Errorf("模拟登录中，不能再次发起模拟")
+	}
+
+	target, err := dao.GetUserByName(tenantID, req.UserName)
+	if err != nil {
+		return "", 0, fmt.Errorf("ImpersonateStart|%v", err)
+	}
+	if target == nil {
+		return "", 0, fmt.Errorf("用户尚未注册")
+	}
+	if target.Status == model.UserStatusDisabled {
+		return "", 0, fmt.Errorf("账号已被禁用")
+	}
+
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		return "", 0, fmt.Errorf("ImpersonateStart|generate session err:%v", err)
+	}
+	session := utils.Md5String(fmt.Sprintf("%s:%s:%s", operator, target.Name, token))
+	info := &cache.ImpersonationInfo{
+		Actor:        operator,
+		ActorSession: actorSession,
+		Target:       target.Name,
+		StartTime:    time.Now(),
+	}
+	ttl, err := cache.CreateImpersonationSession(target, session, info)
+	if err != nil {
+		log.Errorf("%s|ImpersonateStart|Failed to CreateImpersonationSession, err=%v", uuid, err)
+		return "", 0, fmt.Errorf("ImpersonateStart|CreateImpersonationSession fail:%v", err)
+	}
+
+	writeAudit(ctx, operator, target.Name, model.AuditImpersonateStart, "session="+sessionDigest(session))
+	emitSessionEvent(tenantID, model.EventSessionCreated, target.Name, session,
+		map[string]interface{}{"impersonator": operator, "expire_in": int(ttl.Seconds())})
+	log.Infof("%s|ImpersonateStart success, operator=%s|target=%s", uuid, operator, target.Name)
+	return session, ttl, nil
+}
+
+// ImpersonateStop 结束模拟登录，返回管理员原来的会话及其剩余有效期，原会话已失效时返回空串
+func ImpersonateStop(ctx context.Context) (string, time.Duration, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
+
+	info, err := cache.GetImpersonation(tenantID, session)
+	if err != nil {
+		log.Errorf("%s|ImpersonateStop|not an impersonation session=%s|err=%v", uuid, session, err)
+		return "", 0, fmt.Errorf("当前不是模拟登录会话")
+	}
+	endImpersonation(ctx, session, info)
+
+	if _, err := cache.GetSessionInfo(tenantID, info.ActorSession); err != nil {
+		return "", 0, nil
+	}
+	ttl, err := cache.GetSessionTTL(tenantID, info.ActorSession)
+	if err != nil || ttl <= 0 {
+		return "", 0, nil
+	}
+	return info.ActorSession, ttl, nil
+}
+
+// endImpersonation 删除模拟登录会话并记录审计日志
+func endImpersonation(ctx context.Context, session string, info *cache.ImpersonationInfo) {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	if err := cache.DelSessionInfo(tenantID, session); err != nil {
+		log.Errorf("%s|endImpersonation|Failed to delSessionInfo :%s", uuid, session)
+	}
+	if err := cache.DelImpersonation(tenantID, session); err != nil {
+		log.Errorf("%s|endImpersonation|Failed to DelImpersonation :%s", uuid, session)
+	}
+	detail := fmt.Sprintf("session=%s|duration=%s", sessionDigest(session), time.Since(info.StartTime).Round(time.Second))
+	writeAudit(ctx, info.Actor, info.Target, model.AuditImpersonateStop, detail)
+	emitSessionEvent(tenantID, model.EventSessionRevoked, info.Target, session,
+		map[string]interface{}{"reason": "impersonate_stop", "impersonator": info.Actor})
+	log.Infof("%s|endImpersonation success, operator=%s|target=%s", uuid, info.Actor, info.Target)
+}
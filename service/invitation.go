@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"strings"
+	"time"
+)
+
+// maxInvitationUses 单个邀请码允许的最大使用次数
+const maxInvitationUses = 10000
+
+// registerMode 当前注册模式，未配置时为开放注册
+func registerMode() string {
+	mode := conf.GetGlobalConfig().Register.Mode
+	if mode == "" {
+		return static.RegisterModeOpen
+	}
+	return mode
+}
+
+// toInvitationInfo 转换为接口展示的邀请码信息
+func toInvitationInfo(invite *model.Invitation) *InvitationInfo {
+	info := &InvitationInfo{
+		Code:       invite.Code,
+		Inviter:    invite.Inviter,
+		MaxUses:    invite.MaxUses,
+		UsedCount:  invite.UsedCount,
+		Roles:      splitRoles(invite.Roles),
+		Revoked:    invite.Revoked,
+		Remark:     invite.Remark,
+		CreateTime: invite.CreateTime.Unix(),
+	}
+	if invite.ExpireTime != nil {
+		info.ExpireTime = invite.ExpireTime.Unix()
+	}
+	return info
+}
+
+// splitRoles 拆分逗号分隔的角色名
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return []string{}
+	}
+	return strings.Split(roles, ",")
+}
+
+// canGrantRoles 判断用户是否有分配角色的权限。带角色的邀请码相当于分配角色，只有 roles:write 的管理员可以生成和兑现
+func canGrantRoles(tenantID int, name string) (bool, error) {
+	user, err := dao.GetUserByName(tenantID, name)
+	if err != nil {
+		return false, err
+	}
+	if user == nil || user.Status == model.UserStatusDisabled {
+		return false, nil
+	}
+	return HasPermission(user, static.PermRolesWrite)
+}
+
+// CreateInvitation 生成邀请码，预分配的角色必须已存在，且操作人需要有分配角色的权限
+func CreateInvitation(ctx context.Context, req *CreateInvitationRequest) (*InvitationInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 || req.MaxUses > maxInvitationUses || req.ExpireIn < 0 {
+		return nil, fmt.Errorf("CreateInvitation|request params invalid")
+	}
+	if len(req.Roles) > 0 {
+		ok, err := canGrantRoles(tenantFromCtx(ctx), operator)
+		if err != nil {
+			return nil, fmt.Errorf("CreateInvitation|%v", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("没有分配角色的权限，不能生成带角色的邀请码")
+		}
+	}
+	for _, name := range req.Roles {
+		role, err := dao.GetRoleByName(tenantFromCtx(ctx), name)
+		if err != nil {
+			return nil, fmt.Errorf("CreateInvitation|%v", err)
+		}
+		if role == nil {
+			return nil, fmt.Errorf("角色 %s 不存在", name)
+		}
+	}
+
+	code, err := utils.RandomToken(8)
+	if err != nil {
+		return nil, fmt.Errorf("CreateInvitation|%v", err)
+	}
+	invite := &model.Invitation{
+		TenantID:    tenantFromCtx(ctx),
+		Code:        code,
+		Inviter:     operator,
+		MaxUses:     req.MaxUses,
+		Roles:       strings.Join(req.Roles, ","),
+		Remark:      req.Remark,
+		CreateModel: model.CreateModel{Creator: operator},
+		ModifyModel: model.ModifyModel{Modifier: operator},
+	}
+	if req.ExpireIn > 0 {
+		expireTime := time.Now().Add(time.Duration(req.ExpireIn) * time.Second)
+		invite.ExpireTime = &expireTime
+	}
+	if err := dao.CreateInvitation(invite); err != nil {
+		return nil, fmt.Errorf("CreateInvitation|%v", err)
+	}
+	writeAudit(ctx, operator, invite.Code, model.AuditInviteCreate,
+		fmt.Sprintf("max_uses=%d roles=%s", invite.MaxUses, invite.Roles))
+	log.Infof("%s|CreateInvitation success, code=%s|operator=%s", uuid, invite.Code, operator)
+	return toInvitationInfo(invite), nil
+}
+
+// ListInvitations 列出当前租户的邀请码
+func ListInvitations(ctx context.Context) ([]*InvitationInfo, error) {
+	invites, err := dao.ListInvitations(tenantFromCtx(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ListInvitations|%v", err)
+	}
+	infos := make([]*InvitationInfo, 0, len(invites))
+	for _, invite := range invites {
+		infos = append(infos, toInvitationInfo(invite))
+	}
+	return infos, nil
+}
+
+// RevokeInvitation 撤销邀请码，已使用的名额不受影响
+func RevokeInvitation(ctx context.Context, req *RevokeInvitationRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.Code == "" {
+		return fmt.Errorf("RevokeInvitation|request params invalid")
+	}
+	n, err := dao.RevokeInvitation(tenantFromCtx(ctx), req.Code, operator)
+	if err != nil {
+		return fmt.Errorf("RevokeInvitation|%v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("邀请码不存在或已撤销")
+	}
+	writeAudit(ctx, operator, req.Code, model.AuditInviteRevoke, "")
+	log.Infof("%s|RevokeInvitation success, code=%s|operator=%s", uuid, req.Code, operator)
+	return nil
+}
+
+// acquireInvitation 按注册模式校验并占用邀请码，开放注册时邀请码可选，返回 nil 表示未使用邀请码
+func acquireInvitation(tenantID int, code string) (*model.Invitation, error) {
+	switch registerMode() {
+	case static.RegisterModeClosed:
+		return nil, fmt.Errorf("暂未开放注册")
+	case static.RegisterModeInvite:
+		if code == "" {
+			return nil, fmt.Errorf("注册需要邀请码")
+		}
+	}
+	if code == "" {
+		return nil, nil
+	}
+	invite, err := dao.GetInvitationByCode(tenantID, code)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil {
+		return nil, fmt.Errorf("邀请码无效")
+	}
+	ok, err := dao.ConsumeInvitation(invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("邀请码已失效")
+	}
+	return invite, nil
+}
+
+// applyInvitationRoles 给通过邀请码注册的用户分配预设角色，失败只记录日志。
+// 邀请人已失去分配角色的权限时不分配，用户仍正常注册
+func applyInvitationRoles(user *model.User, invite *model.Invitation) {
+	roles := splitRoles(invite.Roles)
+	if len(roles) == 0 {
+		return
+	}
+	ok, err := canGrantRoles(user.TenantID, invite.Inviter)
+	if err != nil || !ok {
+		log.Errorf("applyInvitationRoles|inviter %s can not grant roles, skip, user_name=%s|err=%v", invite.Inviter, user.Name, err)
+		return
+	}
+	for _, name := range roles {
+		role, err := dao.GetRoleByName(user.TenantID, name)
+		if err != nil || role == nil {
+			log.Errorf("applyInvitationRoles|role %s not found, user_name=%s|err=%v", name, user.Name, err)
+			continue
+		}
+		if err := dao.AssignRole(user.ID, role.ID, invite.Inviter); err != nil {
+			log.Errorf("applyInvitationRoles|assign %s to %s err:%v", name, user.Name, err)
+		}
+	}
+}
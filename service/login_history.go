@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+)
+
+// maxUserAgentLen t_login_history.user_agent 的长度
+const maxUserAgentLen = 512
+
+func init() {
+	registerExportSection("login_history", collectLoginHistory)
+}
+
+// deviceFingerprint 设备指纹，目前只根据 User-Agent 区分，同一浏览器换网络登录不算新设备
+func deviceFingerprint(userAgent string) string {
+	if userAgent == "" {
+		return ""
+	}
+	return utils.Md5String(userAgent)
+}
+
+// recordLoginAttempt 记录一次登录尝试。登录成功且设备从未出现过时通知用户，账号第一次登录不通知。
+// 写入失败只记录错误，不影响登录
+func recordLoginAttempt(ctx context.Context, userName, result string) {
+	if userName == "" {
+		return
+	}
+	userAgent, _ := ctx.Value(static.UserAgentKey).(string)
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+	entry := &model.LoginHistory{
+		TenantID:  tenantFromCtx(ctx),
+		UserName:  userName,
+		Success:   result == model.LoginResultSuccess,
+		Result:    result,
+		IP:        clientIPFromCtx(ctx),
+		UserAgent: userAgent,
+		Device:    deviceFingerprint(userAgent),
+	}
+	notify := false
+	if entry.Success {
+		total, onDevice, err := dao.GetLoginDeviceStats(entry.TenantID, userName, entry.Device)
+		if err != nil {
+			log.Errorf("%s|recordLoginAttempt|get device stats err, user_name=%s|err=%v", ctx.Value(static.ReqUuid), userName, err)
+		} else {
+			entry.NewDevice = onDevice == 0
+			notify = entry.NewDevice && total > 0
+		}
+	}
+	if err := dao.CreateLoginHistory(entry); err != nil {
+		log.Errorf("%s|recordLoginAttempt|user_name=%s|result=%s|err=%v", ctx.Value(static.ReqUuid), userName, result, err)
+		return
+	}
+	if notify && conf.GetGlobalConfig().LoginHistory.NotifyNewDevice {
+		notifyNewDevice(entry)
+	}
+}
+
+// notifyNewDevice 通知用户账号在新设备上登录
+func notifyNewDevice(entry *model.LoginHistory) {
+	sendNotification(&Notification{
+		Event:    NotifyEventNewDevice,
+		TenantID: entry.TenantID,
+		UserName: entry.UserName,
+		Title:    "新设备登录提醒",
+		Content: fmt.Sprintf("您的账号于 %s 在新设备上登录，IP：%s。如非本人操作，请立即修改密码。",
+			entry.CreateTime.Format("2006-01-02 15:04:05"), entry.IP),
+		Data: map[string]interface{}{
+			"ip":         entry.IP,
+			"user_agent": entry.UserAgent,
+			"device":     entry.Device,
+		},
+		Time: entry.CreateTime,
+	})
+}
+
+// listLoginHistory 游标分页查询用户的登录记录，最新的在前
+func listLoginHistory(tenantID int, userName string, cursor, limit int) (*LoginHistoryResponse, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	entries, err := dao.ListLoginHistory(tenantID, userName, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &LoginHistoryResponse{Items: make([]*LoginHistoryItem, 0, len(entries))}
+	for _, entry := range entries {
+		rsp.Items = append(rsp.Items, toLoginHistoryItem(entry))
+	}
+	if len(entries) == limit {
+		rsp.NextCursor = entries[len(entries)-1].ID
+	}
+	return rsp, nil
+}
+
+func toLoginHistoryItem(entry *model.LoginHistory) *LoginHistoryItem {
+	return &LoginHistoryItem{
+		ID:         entry.ID,
+		Success:    entry.Success,
+		Result:     entry.Result,
+		IP:         entry.IP,
+		UserAgent:  entry.UserAgent,
+		NewDevice:  entry.NewDevice,
+		CreateTime: entry.CreateTime.Unix(),
+	}
+}
+
+// ListLoginHistory 查询当前登录用户的登录记录
+func ListLoginHistory(ctx context.Context, req *LoginHistoryRequest) (*LoginHistoryResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	user, err := cache.GetSessionInfo(tenantID, session)
+	if err != nil {
+		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
+		return nil, fmt.Errorf("ListLoginHistory|GetSessionInfo err:%v", err)
+	}
+	rsp, err := listLoginHistory(tenantID, user.Name, req.Cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListLoginHistory|%v", err)
+	}
+	return rsp, nil
+}
+
+// AdminListLoginHistory 管理后台查询指定用户的登录记录
+func AdminListLoginHistory(ctx context.Context, req *AdminLoginHistoryRequest) (*LoginHistoryResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.UserName == "" {
+		return nil, fmt.Errorf("AdminListLoginHistory|request params invalid")
+	}
+	log.Infof("%s|AdminListLoginHistory access from operator=%s|req=%+v", uuid, operator, req)
+	rsp, err := listLoginHistory(tenantFromCtx(ctx), req.UserName, req.Cursor, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("AdminListLoginHistory|%v", err)
+	}
+	return rsp, nil
+}
+
+// collectLoginHistory 收集用户的全部登录记录
+func collectLoginHistory(user *model.User) (interface{}, error) {
+	entries, err := dao.ListLoginHistory(user.TenantID, user.Name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*LoginHistoryItem, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, toLoginHistoryItem(entry))
+	}
+	return items, nil
+}
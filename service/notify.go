@@ -0,0 +1,61 @@
+package service
+
+import (
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	"time"
+)
+
+// 通知事件
+const (
+	NotifyEventNewDevice = "login.new_device" // 从未出现过的设备登录成功
+)
+
+// Notification 发给用户的一条通知
+type Notification struct {
+	Event    string                 // 事件，见 NotifyEventXXX
+	TenantID int                    // 所属租户
+	UserName string                 // 接收通知的用户
+	Title    string                 // 标题
+	Content  string                 // 正文
+	Data     map[string]interface{} // 事件相关的数据
+	Time     time.Time              // 事件发生时间
+}
+
+// Notifier 通知方式。新增通知方式实现该接口并通过 registerNotifier 登记，配置中按名字选择
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+var notifiers = make(map[string]Notifier)
+
+// registerNotifier 登记通知方式
+func registerNotifier(name string, notifier Notifier) {
+	notifiers[name] = notifier
+}
+
+func init() {
+	registerNotifier("log", logNotifier{})
+}
+
+// logNotifier 把通知写入日志，没有接入其他通知渠道时使用
+type logNotifier struct{}
+
+func (logNotifier) Notify(n *Notification) error {
+	log.Infof("notify|event=%s|tenant_id=%d|user_name=%s|title=%s|content=%s|data=%v",
+		n.Event, n.TenantID, n.UserName, n.Title, n.Content, n.Data)
+	return nil
+}
+
+// sendNotification 异步发送通知，配置的通知方式不存在时写入日志，发送失败只记录错误
+func sendNotification(n *Notification) {
+	notifier, ok := notifiers[conf.GetGlobalConfig().LoginHistory.Notifier]
+	if !ok {
+		notifier = notifiers["log"]
+	}
+	go func() {
+		if err := notifier.Notify(n); err != nil {
+			log.Errorf("sendNotification|event=%s|user_name=%s|err=%v", n.Event, n.UserName, err)
+		}
+	}()
+}
@@ -0,0 +1,779 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOAuthCodeTTL         = 60
+	defaultOAuthAccessTokenTTL  = 3600
+	defaultOAuthRefreshTokenTTL = 2592000
+	defaultOAuthLoginPage       = "/static/login.html"
+	defaultOAuthConsentPage     = "/static/consent.html"
+	// oauthTokenBytes 授权码、令牌和客户端密钥的随机字节数
+	oauthTokenBytes = 32
+	// 令牌前缀，内省接口和吊销接口据此区分访问令牌、刷新令牌和会话
+	oauthAccessTokenPrefix  = "at_"
+	oauthRefreshTokenPrefix = "rt_"
+	// PKCE 只支持 S256，code_verifier 长度按 RFC 7636 为 43 到 128 个字符
+	pkceMethodS256  = "S256"
+	minPKCEVerifier = 43
+	maxPKCEVerifier = 128
+)
+
+// 支持的 scope 中有特殊含义的两个，决定 userinfo 接口返回的字段
+const (
+	OAuthScopeProfile = "profile"
+	OAuthScopeRoles   = "roles"
+)
+
+// TokenTypeAccessToken 内省结果中 OAuth 访问令牌的令牌类型
+const TokenTypeAccessToken = "access_token"
+
+// OAuth 错误码，与 RFC 6749 一致
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrInvalidToken            = "invalid_token"
+)
+
+// OAuthError OAuth 协议错误，Code 原样返回给客户端
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// oauthCodeData 授权码对应的授权信息，保存在 Redis。redirect_uri 为授权请求中的原值，请求中没有带时为空
+type oauthCodeData struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	UserID        int    `json:"user_id"`
+	UserName      string `json:"user_name"`
+	CodeChallenge string `json:"code_challenge"`
+}
+
+// oauthAccessData 访问令牌对应的授权信息，保存在 Redis。客户端凭证方式签发的令牌没有用户
+type oauthAccessData struct {
+	ClientID string `json:"client_id"`
+	UserID   int    `json:"user_id,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+	Scope    string `json:"scope"`
+	GrantID  string `json:"grant_id,omitempty"`
+	Exp      int64  `json:"exp"`
+}
+
+func oauthTTL(seconds, def int) time.Duration {
+	if seconds <= 0 {
+		seconds = def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// OAuthLoginPage 未登录时跳转的登录页
+func OAuthLoginPage() string {
+	if page := conf.GetGlobalConfig().OAuth.LoginPage; page != "" {
+		return page
+	}
+	return defaultOAuthLoginPage
+}
+
+// OAuthConsentPage 授权确认页
+func OAuthConsentPage() string {
+	if page := conf.GetGlobalConfig().OAuth.ConsentPage; page != "" {
+		return page
+	}
+	return defaultOAuthConsentPage
+}
+
+// supportedScopes 配置中支持的 scope 及其说明
+func supportedScopes() map[string]string {
+	scopes := make(map[string]string)
+	for _, scope := range conf.GetGlobalConfig().OAuth.Scopes {
+		scopes[scope.Name] = scope.Description
+	}
+	return scopes
+}
+
+// supportedScopeNames 配置中支持的 scope，保持配置中的顺序
+func supportedScopeNames() []string {
+	var names []string
+	for _, scope := range conf.GetGlobalConfig().OAuth.Scopes {
+		names = append(names, scope.Name)
+	}
+	return names
+}
+
+// scopeCovered requested 中的 scope 都在 granted 中
+func scopeCovered(requested, granted []string) bool {
+	for _, scope := range requested {
+		if !utils.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// hashOAuthToken 授权码、令牌和客户端密钥都是高熵随机串，只保存 SHA-256
+func hashOAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newOAuthToken(prefix string) (string, error) {
+	token, err := utils.RandomToken(oauthTokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return prefix + token, nil
+}
+
+// verifyPKCE 校验 code_verifier 的 S256 摘要与授权请求中的 code_challenge 一致
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < minPKCEVerifier || len(verifier) > maxPKCEVerifier {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// oauthRedirect 在回调地址上追加参数，保留回调地址原有的参数
+func oauthRedirect(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query[key] = values
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// oauthErrorRedirect 通过回调地址把错误返回给应用
+func oauthErrorRedirect(redirectURI, state string, err *OAuthError) string {
+	return oauthRedirect(redirectURI, url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+		"state":             {state},
+	})
+}
+
+// checkAuthorizeClient 校验授权请求中的客户端和回调地址，返回本次使用的回调地址。
+// 这两项校验失败时不能跳转回应用，直接向用户展示错误。请求中没有回调地址且客户端只注册了一个时使用注册的地址
+func checkAuthorizeClient(tenantID int, req *OAuthAuthorizeRequest) (*model.OAuthClient, string, error) {
+	if req.ClientID == "" {
+		return nil, "", newOAuthError(OAuthErrInvalidRequest, "缺少 client_id")
+	}
+	client, err := dao.GetOAuthClient(tenantID, req.ClientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil || !client.Enabled {
+		return nil, "", newOAuthError(OAuthErrInvalidClient, "应用不存在或已停用")
+	}
+	registered := strings.Fields(client.RedirectURIs)
+	if req.RedirectURI == "" {
+		if len(registered) != 1 {
+			return nil, "", newOAuthError(OAuthErrInvalidRequest, "缺少 redirect_uri")
+		}
+		return client, registered[0], nil
+	}
+	if !utils.Contains(registered, req.RedirectURI) {
+		return nil, "", newOAuthError(OAuthErrInvalidRequest, "redirect_uri 与注册的回调地址不一致")
+	}
+	return client, req.RedirectURI, nil
+}
+
+// checkAuthorizeParams 校验授权请求的其余参数，返回申请的 scope，未指定时为应用允许的全部 scope。错误通过回调地址返回给应用
+func checkAuthorizeParams(client *model.OAuthClient, req *OAuthAuthorizeRequest) ([]string, *OAuthError) {
+	if req.ResponseType != "code" {
+		return nil, newOAuthError(OAuthErrUnsupportedResponseType, "只支持 response_type=code")
+	}
+	if !utils.Contains(strings.Fields(client.GrantTypes), model.OAuthGrantAuthorizationCode) {
+		return nil, newOAuthError(OAuthErrUnauthorizedClient, "应用不允许使用授权码方式")
+	}
+	if req.CodeChallengeMethod != pkceMethodS256 {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "code_challenge_method 必须为 S256")
+	}
+	if len(req.CodeChallenge) < minPKCEVerifier || len(req.CodeChallenge) > maxPKCEVerifier {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "code_challenge 无效")
+	}
+	allowed := strings.Fields(client.Scopes)
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	if !scopeCovered(scopes, allowed) {
+		return nil, newOAuthError(OAuthErrInvalidScope, "申请的 scope 超出应用允许的范围")
+	}
+	return scopes, nil
+}
+
+// currentOAuthUser 当前登录的用户，未登录时返回 nil。模拟登录的会话不能给第三方应用授权
+func currentOAuthUser(ctx context.Context) (*model.User, error) {
+	tenantID := tenantFromCtx(ctx)
+	session, _ := ctx.Value(static.SessionKey).(string)
+	user, _, err := lookupSession(tenantID, session)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if isImpersonating(tenantID, session) {
+		return nil, newOAuthError(OAuthErrAccessDenied, "模拟登录时不能给应用授权")
+	}
+	return user, nil
+}
+
+// issueAuthorizationCode 签发授权码，返回带授权码的回调地址
+func issueAuthorizationCode(tenantID int, user *model.User, req *OAuthAuthorizeRequest, redirectURI string, scopes []string) (string, error) {
+	code, err := newOAuthToken("")
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(&oauthCodeData{
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		UserID:        user.ID,
+		UserName:      user.Name,
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		return "", err
+	}
+	ttl := oauthTTL(conf.GetGlobalConfig().OAuth.CodeTTL, defaultOAuthCodeTTL)
+	if err := cache.SetOAuthCode(tenantID, hashOAuthToken(code), string(b), ttl); err != nil {
+		return "", err
+	}
+	return oauthRedirect(redirectURI, url.Values{"code": {code}, "state": {req.State}}), nil
+}
+
+// Authorize 处理授权地址：未登录时跳转登录页，申请的 scope 已全部授权过时直接签发授权码，否则跳转授权确认页。
+// 返回错误时不能跳转回应用
+func Authorize(ctx context.Context, req *OAuthAuthorizeRequest) (*OAuthAuthorizeResult, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	client, redirectURI, err := checkAuthorizeClient(tenantID, req)
+	if err != nil {
+		log.Warnf("%s|Authorize rejected, client_id=%s|redirect_uri=%s|err=%v", uuid, req.ClientID, req.RedirectURI, err)
+		return nil, err
+	}
+	scopes, oauthErr := checkAuthorizeParams(client, req)
+	if oauthErr != nil {
+		return &OAuthAuthorizeResult{RedirectURL: oauthErrorRedirect(redirectURI, req.State, oauthErr)}, nil
+	}
+	user, err := currentOAuthUser(ctx)
+	if oauthErr, ok := err.(*OAuthError); ok {
+		return &OAuthAuthorizeResult{RedirectURL: oauthErrorRedirect(redirectURI, req.State, oauthErr)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Authorize|%v", err)
+	}
+	if user == nil {
+		return &OAuthAuthorizeResult{NeedLogin: true}, nil
+	}
+
+	consent, err := dao.GetOAuthConsent(user.ID, client.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("Authorize|%v", err)
+	}
+	if consent == nil || !scopeCovered(scopes, strings.Fields(consent.Scope)) {
+		return &OAuthAuthorizeResult{NeedConsent: true}, nil
+	}
+	redirect, err := issueAuthorizationCode(tenantID, user, req, redirectURI, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("Authorize|%v", err)
+	}
+	log.Infof("%s|Authorize with existing consent, client_id=%s|user_name=%s|scope=%v", uuid, client.ClientID, user.Name, scopes)
+	return &OAuthAuthorizeResult{RedirectURL: redirect}, nil
+}
+
+// GetOAuthConsentInfo 授权确认页展示的应用和申请的 scope
+func GetOAuthConsentInfo(ctx context.Context, req *OAuthAuthorizeRequest) (*OAuthConsentInfo, error) {
+	client, _, err := checkAuthorizeClient(tenantFromCtx(ctx), req)
+	if err != nil {
+		return nil, err
+	}
+	scopes, oauthErr := checkAuthorizeParams(client, req)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+	user, err := currentOAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrSessionInvalid
+	}
+	descriptions := supportedScopes()
+	info := &OAuthConsentInfo{
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		UserName:   user.Name,
+		Scopes:     make([]*OAuthScopeInfo, 0, len(scopes)),
+	}
+	for _, scope := range scopes {
+		info.Scopes = append(info.Scopes, &OAuthScopeInfo{Name: scope, Description: descriptions[scope]})
+	}
+	return info, nil
+}
+
+// OAuthConsent 用户在确认页同意或拒绝授权，返回跳转回应用的地址。同意时记录授权，之后申请同样的 scope 不再确认
+func OAuthConsent(ctx context.Context, req *OAuthConsentRequest) (*OAuthConsentResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	client, redirectURI, err := checkAuthorizeClient(tenantID, &req.OAuthAuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+	scopes, oauthErr := checkAuthorizeParams(client, &req.OAuthAuthorizeRequest)
+	if oauthErr != nil {
+		return &OAuthConsentResponse{RedirectURL: oauthErrorRedirect(redirectURI, req.State, oauthErr)}, nil
+	}
+	user, err := currentOAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrSessionInvalid
+	}
+	if !req.Approve {
+		log.Infof("%s|OAuthConsent denied, client_id=%s|user_name=%s", uuid, client.ClientID, user.Name)
+		denied := newOAuthError(OAuthErrAccessDenied, "用户拒绝授权")
+		return &OAuthConsentResponse{RedirectURL: oauthErrorRedirect(redirectURI, req.State, denied)}, nil
+	}
+
+	granted := scopes
+	consent, err := dao.GetOAuthConsent(user.ID, client.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("OAuthConsent|%v", err)
+	}
+	if consent != nil {
+		for _, scope := range strings.Fields(consent.Scope) {
+			if !utils.Contains(granted, scope) {
+				granted = append(granted, scope)
+			}
+		}
+	}
+	err = dao.SaveOAuthConsent(&model.OAuthConsent{
+		TenantID: tenantID,
+		UserID:   user.ID,
+		ClientID: client.ClientID,
+		Scope:    strings.Join(granted, " "),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OAuthConsent|%v", err)
+	}
+	writeAudit(ctx, user.Name, oauthClientTarget(client.ClientID), model.AuditOAuthConsent, "scope="+strings.Join(scopes, " "))
+
+	redirect, err := issueAuthorizationCode(tenantID, user, &req.OAuthAuthorizeRequest, redirectURI, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("OAuthConsent|%v", err)
+	}
+	log.Infof("%s|OAuthConsent approved, client_id=%s|user_name=%s|scope=%v", uuid, client.ClientID, user.Name, scopes)
+	return &OAuthConsentResponse{RedirectURL: redirect}, nil
+}
+
+// authenticateOAuthClient 令牌接口和吊销接口的客户端认证：机密客户端必须带正确的密钥，公开客户端只需要 client_id
+func authenticateOAuthClient(tenantID int, clientID, secret string) (*model.OAuthClient, error) {
+	if clientID == "" {
+		return nil, newOAuthError(OAuthErrInvalidClient, "缺少客户端凭证")
+	}
+	client, err := dao.GetOAuthClient(tenantID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || !client.Enabled {
+		return nil, newOAuthError(OAuthErrInvalidClient, "客户端不存在或已停用")
+	}
+	if client.Public {
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(hashOAuthToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, newOAuthError(OAuthErrInvalidClient, "客户端凭证无效")
+	}
+	return client, nil
+}
+
+// oauthUser 获取令牌对应的用户，用户已注销、被禁用或被要求重置密码时返回 nil
+func oauthUser(tenantID, userID int, userName string) (*model.User, error) {
+	user, err := getUserInfo(tenantID, userName)
+	if err == errUserNotRegistered {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.ID != userID || user.Status == model.UserStatusDisabled || user.PwdResetRequired {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// issueOAuthTokens 签发访问令牌，refreshScope 不为空时同时签发刷新令牌。客户端凭证方式没有用户，user 为 nil
+func issueOAuthTokens(tenantID int, client *model.OAuthClient, user *model.User, grantID, scope, refreshScope string) (*OAuthTokenResponse, error) {
+	oauthConf := conf.GetGlobalConfig().OAuth
+	accessToken, err := newOAuthToken(oauthAccessTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	ttl := oauthTTL(oauthConf.AccessTokenTTL, defaultOAuthAccessTokenTTL)
+	data := &oauthAccessData{
+		ClientID: client.ClientID,
+		Scope:    scope,
+		GrantID:  grantID,
+		Exp:      time.Now().Add(ttl).Unix(),
+	}
+	if user != nil {
+		data.UserID, data.UserName = user.ID, user.Name
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.SetOAuthAccessToken(tenantID, hashOAuthToken(accessToken), grantID, string(b), ttl); err != nil {
+		return nil, err
+	}
+	rsp := &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	}
+	if user == nil || refreshScope == "" || !utils.Contains(strings.Fields(client.GrantTypes), model.OAuthGrantRefreshToken) {
+		return rsp, nil
+	}
+
+	refreshToken, err := newOAuthToken(oauthRefreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	err = dao.CreateOAuthRefreshToken(&model.OAuthRefreshToken{
+		TenantID:   tenantID,
+		TokenHash:  hashOAuthToken(refreshToken),
+		GrantID:    grantID,
+		ClientID:   client.ClientID,
+		UserID:     user.ID,
+		UserName:   user.Name,
+		Scope:      refreshScope,
+		ExpireTime: time.Now().Add(oauthTTL(oauthConf.RefreshTokenTTL, defaultOAuthRefreshTokenTTL)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	rsp.RefreshToken = refreshToken
+	return rsp, nil
+}
+
+// exchangeAuthorizationCode 用授权码换取令牌，授权码只能使用一次
+func exchangeAuthorizationCode(tenantID int, client *model.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 code 或 code_verifier")
+	}
+	val, err := cache.TakeOAuthCode(tenantID, hashOAuthToken(req.Code))
+	if cache.IsMiss(err) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "授权码无效、已过期或已使用")
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &oauthCodeData{}
+	if err := json.Unmarshal([]byte(val), data); err != nil {
+		return nil, err
+	}
+	if data.ClientID != client.ClientID {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "授权码不属于该客户端")
+	}
+	if data.RedirectURI != "" && data.RedirectURI != req.RedirectURI {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "redirect_uri 与授权请求不一致")
+	}
+	if !verifyPKCE(req.CodeVerifier, data.CodeChallenge) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "code_verifier 校验失败")
+	}
+	user, err := oauthUser(tenantID, data.UserID, data.UserName)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "用户不存在或已被禁用")
+	}
+	grantID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueOAuthTokens(tenantID, client, user, grantID, data.Scope, data.Scope)
+}
+
+// refreshOAuthToken 用刷新令牌换取新的令牌，旧的刷新令牌同时作废。已作废的刷新令牌再次使用时吊销整个授权
+func refreshOAuthToken(tenantID int, client *model.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 refresh_token")
+	}
+	token, err := dao.GetOAuthRefreshToken(tenantID, hashOAuthToken(req.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.ClientID != client.ClientID {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌无效")
+	}
+	if token.RevokedTime != nil {
+		log.Warnf("refreshOAuthToken|revoked refresh token reused, revoke grant, client_id=%s|user_name=%s|grant_id=%s",
+			client.ClientID, token.UserName, token.GrantID)
+		revokeOAuthGrant(tenantID, token.GrantID)
+		return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌已失效")
+	}
+	now := time.Now()
+	if now.After(token.ExpireTime) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌已过期")
+	}
+	// 可以申请更小的 scope，只作用于本次签发的访问令牌，刷新令牌保持原来的 scope
+	scope := token.Scope
+	if req.Scope != "" {
+		requested := strings.Fields(req.Scope)
+		if !scopeCovered(requested, strings.Fields(token.Scope)) {
+			return nil, newOAuthError(OAuthErrInvalidScope, "申请的 scope 超出原授权范围")
+		}
+		scope = strings.Join(requested, " ")
+	}
+	claimed, err := dao.RevokeOAuthRefreshToken(token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌已失效")
+	}
+	user, err := oauthUser(tenantID, token.UserID, token.UserName)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		revokeOAuthGrant(tenantID, token.GrantID)
+		return nil, newOAuthError(OAuthErrInvalidGrant, "用户不存在或已被禁用")
+	}
+	return issueOAuthTokens(tenantID, client, user, token.GrantID, scope, token.Scope)
+}
+
+// clientCredentialsToken 客户端以自己的身份获取访问令牌，不签发刷新令牌
+func clientCredentialsToken(tenantID int, client *model.OAuthClient, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if client.Public {
+		return nil, newOAuthError(OAuthErrUnauthorizedClient, "公开客户端不能使用 client_credentials")
+	}
+	allowed := strings.Fields(client.Scopes)
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	if !scopeCovered(scopes, allowed) {
+		return nil, newOAuthError(OAuthErrInvalidScope, "申请的 scope 超出应用允许的范围")
+	}
+	return issueOAuthTokens(tenantID, client, nil, "", strings.Join(scopes, " "), "")
+}
+
+// Token 令牌接口，支持 authorization_code、refresh_token 和 client_credentials。协议错误返回 *OAuthError
+func Token(ctx context.Context, req *OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	client, err := authenticateOAuthClient(tenantID, req.ClientID, req.ClientSecret)
+	if err != nil {
+		log.Warnf("%s|Token client authentication failed, client_id=%s|ip=%s|err=%v", uuid, req.ClientID, clientIPFromCtx(ctx), err)
+		return nil, err
+	}
+	if !utils.Contains(strings.Fields(client.GrantTypes), req.GrantType) {
+		if !utils.Contains(supportedGrantTypes, req.GrantType) {
+			return nil, newOAuthError(OAuthErrUnsupportedGrantType, "不支持的授权方式")
+		}
+		return nil, newOAuthError(OAuthErrUnauthorizedClient, "客户端不允许使用该授权方式")
+	}
+
+	var rsp *OAuthTokenResponse
+	switch req.GrantType {
+	case model.OAuthGrantAuthorizationCode:
+		rsp, err = exchangeAuthorizationCode(tenantID, client, req)
+	case model.OAuthGrantRefreshToken:
+		rsp, err = refreshOAuthToken(tenantID, client, req)
+	case model.OAuthGrantClientCredentials:
+		rsp, err = clientCredentialsToken(tenantID, client, req)
+	}
+	if err != nil {
+		log.Warnf("%s|Token failed, client_id=%s|grant_type=%s|err=%v", uuid, client.ClientID, req.GrantType, err)
+		if _, ok := err.(*OAuthError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Token|%v", err)
+	}
+	log.Infof("%s|Token issued, client_id=%s|grant_type=%s|scope=%s", uuid, client.ClientID, req.GrantType, rsp.Scope)
+	return rsp, nil
+}
+
+// revokeOAuthGrant 吊销同一次授权下的全部刷新令牌和访问令牌，失败只记录错误
+func revokeOAuthGrant(tenantID int, grantID string) {
+	if err := dao.RevokeOAuthGrant(grantID, time.Now()); err != nil {
+		log.Errorf("revokeOAuthGrant|grant_id=%s|err=%v", grantID, err)
+	}
+	if err := cache.DelOAuthGrant(tenantID, grantID); err != nil {
+		log.Errorf("revokeOAuthGrant|delete access tokens failed, grant_id=%s|err=%v", grantID, err)
+	}
+}
+
+// getOAuthAccessData 获取访问令牌对应的授权信息，令牌不存在或已过期时返回 nil
+func getOAuthAccessData(tenantID int, token string) (*oauthAccessData, error) {
+	if !strings.HasPrefix(token, oauthAccessTokenPrefix) {
+		return nil, nil
+	}
+	val, err := cache.GetOAuthAccessToken(tenantID, hashOAuthToken(token))
+	if cache.IsMiss(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &oauthAccessData{}
+	if err := json.Unmarshal([]byte(val), data); err != nil {
+		return nil, err
+	}
+	if data.Exp <= time.Now().Unix() {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// RevokeOAuthToken 吊销令牌，按 RFC 7009，令牌不存在、已失效或不属于该客户端时同样返回成功。
+// 吊销刷新令牌时同一次授权下的访问令牌一并吊销
+func RevokeOAuthToken(ctx context.Context, req *OAuthRevokeRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	client, err := authenticateOAuthClient(tenantID, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return err
+	}
+	if req.Token == "" {
+		return newOAuthError(OAuthErrInvalidRequest, "缺少 token")
+	}
+
+	if strings.HasPrefix(req.Token, oauthRefreshTokenPrefix) {
+		token, err := dao.GetOAuthRefreshToken(tenantID, hashOAuthToken(req.Token))
+		if err != nil {
+			return fmt.Errorf("RevokeOAuthToken|%v", err)
+		}
+		if token != nil && token.ClientID == client.ClientID {
+			revokeOAuthGrant(tenantID, token.GrantID)
+			log.Infof("%s|RevokeOAuthToken refresh token revoked, client_id=%s|grant_id=%s", uuid, client.ClientID, token.GrantID)
+		}
+		return nil
+	}
+	data, err := getOAuthAccessData(tenantID, req.Token)
+	if err != nil {
+		return fmt.Errorf("RevokeOAuthToken|%v", err)
+	}
+	if data != nil && data.ClientID == client.ClientID {
+		if err := cache.DelOAuthAccessToken(tenantID, hashOAuthToken(req.Token)); err != nil {
+			return fmt.Errorf("RevokeOAuthToken|%v", err)
+		}
+		log.Infof("%s|RevokeOAuthToken access token revoked, client_id=%s", uuid, client.ClientID)
+	}
+	return nil
+}
+
+// GetOAuthUserInfo 访问令牌对应的用户信息，profile 返回基本资料，roles 返回角色
+func GetOAuthUserInfo(ctx context.Context, accessToken string) (*OAuthUserInfo, error) {
+	tenantID := tenantFromCtx(ctx)
+	data, err := getOAuthAccessData(tenantID, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("GetOAuthUserInfo|%v", err)
+	}
+	if data == nil || data.UserID == 0 {
+		return nil, newOAuthError(OAuthErrInvalidToken, "访问令牌无效或已过期")
+	}
+	user, err := oauthUser(tenantID, data.UserID, data.UserName)
+	if err != nil {
+		return nil, fmt.Errorf("GetOAuthUserInfo|%v", err)
+	}
+	if user == nil {
+		return nil, newOAuthError(OAuthErrInvalidToken, "用户不存在或已被禁用")
+	}
+
+	scopes := strings.Fields(data.Scope)
+	info := &OAuthUserInfo{Sub: strconv.Itoa(user.ID)}
+	if utils.Contains(scopes, OAuthScopeProfile) {
+		info.UserName, info.NickName, info.Gender, info.Age = user.Name, user.NickName, user.Gender, user.Age
+	}
+	if utils.Contains(scopes, OAuthScopeRoles) {
+		if info.Roles, err = dao.GetUserRoleNames(user.ID); err != nil {
+			return nil, fmt.Errorf("GetOAuthUserInfo|%v", err)
+		}
+	}
+	return info, nil
+}
+
+// introspectAccessToken 内省 OAuth 访问令牌，客户端凭证方式签发的令牌 sub 为 client_id
+func introspectAccessToken(tenantID int, token string) (*IntrospectResponse, error) {
+	data, err := getOAuthAccessData(tenantID, token)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return &IntrospectResponse{}, nil
+	}
+	rsp := &IntrospectResponse{
+		Active:    true,
+		TokenType: TokenTypeAccessToken,
+		Sub:       data.ClientID,
+		ClientID:  data.ClientID,
+		Scope:     data.Scope,
+		TenantID:  tenantID,
+		Exp:       data.Exp,
+	}
+	if data.UserID == 0 {
+		return rsp, nil
+	}
+	user, err := oauthUser(tenantID, data.UserID, data.UserName)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return &IntrospectResponse{}, nil
+	}
+	if rsp.Roles, err = dao.GetUserRoleNames(user.ID); err != nil {
+		return nil, err
+	}
+	rsp.Sub, rsp.UserID, rsp.UserName = strconv.Itoa(user.ID), user.ID, user.Name
+	return rsp, nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net/url"
+	"strings"
+)
+
+const (
+	// oauthClientIDBytes 客户端标识的随机字节数
+	oauthClientIDBytes     = 12
+	maxOAuthClientName     = 100
+	maxOAuthRedirectURILen = 512
+	maxOAuthRedirectURIs   = 10
+)
+
+// supportedGrantTypes 支持的授权方式
+var supportedGrantTypes = []string{
+	model.OAuthGrantAuthorizationCode,
+	model.OAuthGrantRefreshToken,
+	model.OAuthGrantClientCredentials,
+}
+
+// checkOAuthRedirectURI 回调地址必须是不带 fragment 的绝对地址，移动端可以使用自定义 scheme
+func checkOAuthRedirectURI(raw string) error {
+	if raw == "" || len(raw) > maxOAuthRedirectURILen {
+		return fmt.Errorf("回调地址无效")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "" && u.Path == "") || u.Fragment != "" {
+		return fmt.Errorf("回调地址无效：%s", raw)
+	}
+	return nil
+}
+
+// normalizeOAuthGrantTypes 校验并去重授权方式，为空时为 authorization_code 和 refresh_token
+func normalizeOAuthGrantTypes(grantTypes []string, public bool) ([]string, error) {
+	if len(grantTypes) == 0 {
+		return []string{model.OAuthGrantAuthorizationCode, model.OAuthGrantRefreshToken}, nil
+	}
+	var result []string
+	for _, grantType := range grantTypes {
+		if !utils.Contains(supportedGrantTypes, grantType) {
+			return nil, fmt.Errorf("不支持的授权方式：%s", grantType)
+		}
+		if public && grantType == model.OAuthGrantClientCredentials {
+			return nil, fmt.Errorf("公开客户端不能使用 client_credentials")
+		}
+		if !utils.Contains(result, grantType) {
+			result = append(result, grantType)
+		}
+	}
+	return result, nil
+}
+
+// normalizeOAuthScopes 校验并去重 scope，为空时为全部支持的 scope
+func normalizeOAuthScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return supportedScopeNames(), nil
+	}
+	var result []string
+	for _, scope := range scopes {
+		if _, ok := supportedScopes()[scope]; !ok {
+			return nil, fmt.Errorf("不支持的 scope：%s", scope)
+		}
+		if !utils.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// normalizeOAuthRedirectURIs 校验并去重回调地址
+func normalizeOAuthRedirectURIs(uris []string) ([]string, error) {
+	if len(uris) > maxOAuthRedirectURIs {
+		return nil, fmt.Errorf("回调地址不能超过%d个", maxOAuthRedirectURIs)
+	}
+	var result []string
+	for _, uri := range uris {
+		if err := checkOAuthRedirectURI(uri); err != nil {
+			return nil, err
+		}
+		if !utils.Contains(result, uri) {
+			result = append(result, uri)
+		}
+	}
+	return result, nil
+}
+
+// newOAuthClientSecret 生成客户端密钥，数据库中只保存哈希
+func newOAuthClientSecret() (string, error) {
+	return newOAuthToken("cs_")
+}
+
+func oauthClientTarget(clientID string) string {
+	return "oauth_client:" + clientID
+}
+
+// oauthClientAuditFields 参与审计的客户端字段，密钥不记录
+func oauthClientAuditFields(client *model.OAuthClient) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIs,
+		"scopes":        client.Scopes,
+		"grant_types":   client.GrantTypes,
+		"public":        client.Public,
+		"enabled":       client.Enabled,
+		"description":   client.Description,
+	}
+}
+
+func toOAuthClientInfo(client *model.OAuthClient) *OAuthClientInfo {
+	return &OAuthClientInfo{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		Scopes:       strings.Fields(client.Scopes),
+		GrantTypes:   strings.Fields(client.GrantTypes),
+		Public:       client.Public,
+		Enabled:      client.Enabled,
+		Description:  client.Description,
+		Creator:      client.Creator,
+		CreateTime:   client.CreateTime.Unix(),
+	}
+}
+
+// CreateOAuthClient 注册 OAuth 客户端，机密客户端的密钥只在这里返回一次
+func CreateOAuthClient(ctx context.Context, req *CreateOAuthClientRequest) (*OAuthClientInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.Name == "" || len(req.Name) > maxOAuthClientName {
+		return nil, fmt.Errorf("应用名不能为空且不能超过%d个字符", maxOAuthClientName)
+	}
+	grantTypes, err := normalizeOAuthGrantTypes(req.GrantTypes, req.Public)
+	if err != nil {
+		return nil, err
+	}
+	redirectURIs, err := normalizeOAuthRedirectURIs(req.RedirectURIs)
+	if err != nil {
+		return nil, err
+	}
+	if utils.Contains(grantTypes, model.OAuthGrantAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, fmt.Errorf("使用授权码方式时至少需要一个回调地址")
+	}
+	scopes, err := normalizeOAuthScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := utils.RandomToken(oauthClientIDBytes)
+	if err != nil {
+		return nil, fmt.Errorf("CreateOAuthClient|%v", err)
+	}
+
+	client := &model.OAuthClient{
+		TenantID:     tenantFromCtx(ctx),
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		GrantTypes:   strings.Join(grantTypes, " "),
+		Public:       req.Public,
+		Enabled:      true,
+		Description:  req.Description,
+		CreateModel:  model.CreateModel{Creator: operator},
+		ModifyModel:  model.ModifyModel{Modifier: operator},
+	}
+	secret := ""
+	if !req.Public {
+		if secret, err = newOAuthClientSecret(); err != nil {
+			return nil, fmt.Errorf("CreateOAuthClient|%v", err)
+		}
+		client.SecretHash = hashOAuthToken(secret)
+	}
+	if err := dao.CreateOAuthClient(client); err != nil {
+		return nil, fmt.Errorf("CreateOAuthClient|%v", err)
+	}
+	writeAuditDiff(ctx, operator, oauthClientTarget(clientID), model.AuditOAuthClientCreate, "", nil, oauthClientAuditFields(client))
+	log.Infof("%s|CreateOAuthClient success, client_id=%s|name=%s|public=%v|operator=%s", uuid, clientID, client.Name, client.Public, operator)
+	info := toOAuthClientInfo(client)
+	info.ClientSecret = secret
+	return info, nil
+}
+
+// ListOAuthClients 列出当前租户的 OAuth 客户端
+func ListOAuthClients(ctx context.Context) ([]*OAuthClientInfo, error) {
+	clients, err := dao.ListOAuthClients(tenantFromCtx(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ListOAuthClients|%v", err)
+	}
+	infos := make([]*OAuthClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, toOAuthClientInfo(client))
+	}
+	return infos, nil
+}
+
+// UpdateOAuthClient 修改 OAuth 客户端，重新生成密钥时返回新的密钥，旧密钥立即失效。
+// 停用后不能再获取和刷新令牌，已签发的访问令牌在过期前仍然有效
+func UpdateOAuthClient(ctx context.Context, req *UpdateOAuthClientRequest) (*OAuthClientInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	client, err := dao.GetOAuthClient(tenantID, req.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateOAuthClient|%v", err)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("OAuth 客户端不存在")
+	}
+
+	before := oauthClientAuditFields(client)
+	fields := map[string]interface{}{"modifier": operator}
+	if req.Name != "" {
+		if len(req.Name) > maxOAuthClientName {
+			return nil, fmt.Errorf("应用名不能超过%d个字符", maxOAuthClientName)
+		}
+		client.Name = req.Name
+		fields["name"] = req.Name
+	}
+	if len(req.RedirectURIs) > 0 {
+		redirectURIs, err := normalizeOAuthRedirectURIs(req.RedirectURIs)
+		if err != nil {
+			return nil, err
+		}
+		client.RedirectURIs = strings.Join(redirectURIs, " ")
+		fields["redirect_uris"] = client.RedirectURIs
+	}
+	if len(req.Scopes) > 0 {
+		scopes, err := normalizeOAuthScopes(req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		client.Scopes = strings.Join(scopes, " ")
+		fields["scopes"] = client.Scopes
+	}
+	if req.Description != "" {
+		client.Description = req.Description
+		fields["description"] = req.Description
+	}
+	if req.Enabled != nil {
+		client.Enabled = *req.Enabled
+		fields["enabled"] = *req.Enabled
+	}
+	secret := ""
+	if req.RotateSecret {
+		if client.Public {
+			return nil, fmt.Errorf("公开客户端没有密钥")
+		}
+		if secret, err = newOAuthClientSecret(); err != nil {
+			return nil, fmt.Errorf("UpdateOAuthClient|%v", err)
+		}
+		fields["secret_hash"] = hashOAuthToken(secret)
+	}
+	if _, err := dao.UpdateOAuthClient(tenantID, client.ClientID, fields); err != nil {
+		return nil, fmt.Errorf("UpdateOAuthClient|%v", err)
+	}
+
+	detail := ""
+	if req.RotateSecret {
+		detail = "secret rotated"
+	}
+	writeAuditDiff(ctx, operator, oauthClientTarget(client.ClientID), model.AuditOAuthClientUpdate, detail, before, oauthClientAuditFields(client))
+	log.Infof("%s|UpdateOAuthClient success, client_id=%s|rotate_secret=%v|operator=%s", uuid, client.ClientID, req.RotateSecret, operator)
+	info := toOAuthClientInfo(client)
+	info.ClientSecret = secret
+	return info, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"my_user_system/model"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 附录 B 的示例
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if !verifyPKCE(verifier, challenge) {
+		t.Errorf("verifyPKCE with matching verifier = false")
+	}
+	if verifyPKCE(verifier+"x", challenge) {
+		t.Errorf("verifyPKCE with wrong verifier = true")
+	}
+	if verifyPKCE("short", challenge) {
+		t.Errorf("verifyPKCE with too short verifier = true")
+	}
+	if verifyPKCE(strings.Repeat("a", maxPKCEVerifier+1), challenge) {
+		t.Errorf("verifyPKCE with too long verifier = true")
+	}
+}
+
+func TestCheckAuthorizeParams(t *testing.T) {
+	client := &model.OAuthClient{
+		Scopes:     "profile roles",
+		GrantTypes: model.OAuthGrantAuthorizationCode + " " + model.OAuthGrantRefreshToken,
+	}
+	valid := OAuthAuthorizeRequest{
+		ResponseType:        "code",
+		CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeMethod: pkceMethodS256,
+	}
+	scopes, err := checkAuthorizeParams(client, &valid)
+	if err != nil || strings.Join(scopes, " ") != "profile roles" {
+		t.Errorf("checkAuthorizeParams without scope = %v, %v, want all client scopes", scopes, err)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(req *OAuthAuthorizeRequest)
+		code   string
+	}{
+		{"token response type", func(req *OAuthAuthorizeRequest) { req.ResponseType = "token" }, OAuthErrUnsupportedResponseType},
+		{"plain method", func(req *OAuthAuthorizeRequest) { req.CodeChallengeMethod = "plain" }, OAuthErrInvalidRequest},
+		{"missing challenge", func(req *OAuthAuthorizeRequest) { req.CodeChallenge = "" }, OAuthErrInvalidRequest},
+		{"scope out of range", func(req *OAuthAuthorizeRequest) { req.Scope = "profile admin" }, OAuthErrInvalidScope},
+	}
+	for _, c := range cases {
+		req := valid
+		c.modify(&req)
+		if _, err := checkAuthorizeParams(client, &req); err == nil || err.Code != c.code {
+			t.Errorf("%s: checkAuthorizeParams = %v, want %s", c.name, err, c.code)
+		}
+	}
+}
+
+func TestOAuthRedirect(t *testing.T) {
+	got := oauthRedirect("https://app.example.com/cb?from=login", url.Values{"code": {"abc"}, "state": {""}})
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("oauthRedirect = %s, %v", got, err)
+	}
+	query := u.Query()
+	// 保留回调地址原有的参数，空的 state 不追加
+	if query.Get("from") != "login" || query.Get("code") != "abc" || query.Has("state") {
+		t.Errorf("oauthRedirect = %s", got)
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutboxStream       = "user_events"
+	defaultOutboxPollInterval = 1000
+	defaultOutboxBatchSize    = 100
+	// outboxClaimLease 抢到的事件在这段时间内不会被其他实例投递，应远大于一次投递的耗时
+	outboxClaimLease = 30 * time.Second
+	// outboxMaxBackoff 投递失败后重试间隔的上限
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxCleanupInterval 清理已投递事件的间隔
+	outboxCleanupInterval = time.Hour
+	outboxCleanupBatch    = 1000
+	maxOutboxErrorLen     = 512
+)
+
+// Event 投递给下游的领域事件，下游按 event_id 去重
+type Event struct {
+	ID         string          `json:"event_id"`
+	Type       string          `json:"type"`
+	TenantID   int             `json:"tenant_id"`
+	Subject    string          `json:"subject"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Publisher 领域事件的投递方式。新增投递方式实现该接口并通过 registerPublisher 登记，配置中按名字选择。
+// 返回 nil 表示下游已收到，返回错误时事件会在退避后重试，因此同一事件可能被投递多次
+type Publisher interface {
+	Publish(event *Event) error
+}
+
+var publishers = make(map[string]Publisher)
+
+// registerPublisher 登记投递方式
+func registerPublisher(name string, publisher Publisher) {
+	publishers[name] = publisher
+}
+
+func init() {
+	registerPublisher("redis", redisPublisher{})
+	registerPublisher("memory", DefaultMemoryPublisher)
+}
+
+// redisPublisher 把事件追加到 Redis Stream，下游通过消费组读取
+type redisPublisher struct{}
+
+func (redisPublisher) Publish(event *Event) error {
+	outboxConf := conf.GetGlobalConfig().Outbox
+	stream := outboxConf.Stream
+	if stream == "" {
+		stream = defaultOutboxStream
+	}
+	_, err := cache.AddToStream(stream, outboxConf.StreamMaxLen, map[string]interface{}{
+		"event_id":    event.ID,
+		"type":        event.Type,
+		"tenant_id":   strconv.Itoa(event.TenantID),
+		"subject":     event.Subject,
+		"payload":     string(event.Payload),
+		"occurred_at": event.OccurredAt.Format(time.RFC3339),
+	})
+	return err
+}
+
+// MemoryPublisher 把事件保存在进程内，用于测试和本地开发
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+// DefaultMemoryPublisher 配置为 memory 时使用的实例
+var DefaultMemoryPublisher = &MemoryPublisher{}
+
+func (p *MemoryPublisher) Publish(event *Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events 返回已投递的事件
+func (p *MemoryPublisher) Events() []*Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Event(nil), p.events...)
+}
+
+// Reset 清空已投递的事件
+func (p *MemoryPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = nil
+}
+
+// outboxPublisher 配置的投递方式，未配置或不支持时为 redis
+func outboxPublisher() Publisher {
+	if publisher, ok := publishers[conf.GetGlobalConfig().Outbox.Publisher]; ok {
+		return publisher
+	}
+	return publishers["redis"]
+}
+
+// newOutboxEvent 构造待写入发件箱的事件
+func newOutboxEvent(tenantID int, eventType, subject string, payload interface{}) *model.OutboxEvent {
+	eventID, err := utils.RandomToken(16)
+	if err != nil {
+		eventID = utils.Md5String(fmt.Sprintf("%s:%s:%d", eventType, subject, time.Now().UnixNano()))
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("newOutboxEvent|marshal payload err, type=%s|err=%v", eventType, err)
+		b = []byte("{}")
+	}
+	return &model.OutboxEvent{
+		EventID:   eventID,
+		TenantID:  tenantID,
+		EventType: eventType,
+		Subject:   subject,
+		Payload:   string(b),
+	}
+}
+
+// userRegisteredEvent 用户注册事件
+func userRegisteredEvent(user *model.User) *model.OutboxEvent {
+	return newOutboxEvent(user.TenantID, model.EventUserRegistered, user.Name, map[string]interface{}{
+		"user_name": user.Name,
+		"nick_name": user.NickName,
+		"gender":    user.Gender,
+		"age":       user.Age,
+		"inviter":   user.Inviter,
+	})
+}
+
+// userUpdatedEvent 用户变更事件，changed 的键与 t_user 的列名一致，密码只标记为已修改
+func userUpdatedEvent(tenantID int, userName string, changed map[string]interface{}) *model.OutboxEvent {
+	fields := make(map[string]interface{}, len(changed))
+	for field, value := range changed {
+		switch field {
+		case "modifier":
+		case "password":
+			fields[field] = "changed"
+		default:
+			fields[field] = value
+		}
+	}
+	return newOutboxEvent(tenantID, model.EventUserUpdated, userName, map[string]interface{}{
+		"user_name": userName,
+		"changed":   fields,
+	})
+}
+
+// userDeletedEvent 用户注销事件
+func userDeletedEvent(user *model.User) *model.OutboxEvent {
+	return newOutboxEvent(user.TenantID, model.EventUserDeleted, user.Name, map[string]interface{}{
+		"user_name": user.Name,
+	})
+}
+
+// sessionDigest 会话ID的摘要，审计日志、事件和导出中只出现摘要，拿到摘要也无法冒用会话
+func sessionDigest(session string) string {
+	return utils.Md5String(session)
+}
+
+// emitSessionEvent 写入会话事件。会话保存在 Redis 中，没有可以共用的数据库事务，单独写入发件箱，失败只记录错误。
+// 事件中的会话标识是哈希后的值，不泄露可以直接使用的会话
+func emitSessionEvent(tenantID int, eventType, userName, session string, extra map[string]interface{}) {
+	payload := map[string]interface{}{
+		"user_name":  userName,
+		"session_id": sessionDigest(session),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	if err := dao.CreateOutboxEvents(newOutboxEvent(tenantID, eventType, userName, payload)); err != nil {
+		log.Errorf("emitSessionEvent|type=%s|user_name=%s|err=%v", eventType, userName, err)
+	}
+}
+
+// InitOutboxRelay 启动发件箱投递任务，至少投递一次：投递成功但标记失败时，租约到期后会再次投递
+func InitOutboxRelay() {
+	outboxConf := conf.GetGlobalConfig().Outbox
+	interval := outboxConf.PollInterval
+	if interval <= 0 {
+		interval = defaultOutboxPollInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+		defer ticker.Stop()
+		lastCleanup := time.Now()
+		for range ticker.C {
+			relayOutbox()
+			if outboxConf.SentRetention > 0 && time.Since(lastCleanup) > outboxCleanupInterval {
+				cleanupSentOutbox(time.Now().Add(-time.Duration(outboxConf.SentRetention) * time.Second))
+				lastCleanup = time.Now()
+			}
+		}
+	}()
+	log.Infof("InitOutboxRelay success, publisher=%s|poll_interval=%dms", outboxConf.Publisher, interval)
+}
+
+// relayOutbox 投递一批到期的事件，投递成功后生成 Webhook 投递记录。投递失败时停止本轮，保证下游尽量按写入顺序收到事件
+func relayOutbox() {
+	batch := conf.GetGlobalConfig().Outbox.BatchSize
+	if batch <= 0 {
+		batch = defaultOutboxBatchSize
+	}
+	now := time.Now()
+	events, err := dao.ListPendingOutbox(now, batch)
+	if err != nil {
+		log.Errorf("relayOutbox|list pending err:%v", err)
+		return
+	}
+	publisher := outboxPublisher()
+	for _, event := range events {
+		claimed, err := dao.ClaimOutboxEvent(event.ID, now, time.Now().Add(outboxClaimLease))
+		if err != nil {
+			return
+		}
+		if !claimed {
+			continue
+		}
+		attempts := event.Attempts + 1
+		published := toEvent(event)
+		err = publisher.Publish(published)
+		if err == nil {
+			err = enqueueWebhookDeliveries(published)
+		}
+		if err != nil {
+			log.Warnf("relayOutbox|relay failed, event_id=%s|attempts=%d|err=%v", event.EventID, attempts, err)
+			reason := err.Error()
+			if len(reason) > maxOutboxErrorLen {
+				reason = reason[:maxOutboxErrorLen]
+			}
+			dao.MarkOutboxFailed(event.ID, attempts, time.Now().Add(outboxBackoff(attempts)), reason)
+			return
+		}
+		dao.MarkOutboxSent(event.ID, attempts)
+	}
+}
+
+// outboxBackoff 第 attempts 次投递失败后的重试间隔，从 1 秒开始翻倍
+func outboxBackoff(attempts int) time.Duration {
+	return exponentialBackoff(attempts, time.Second, outboxMaxBackoff)
+}
+
+// exponentialBackoff 第 attempts 次失败后的重试间隔，从 base 开始翻倍，不超过 max
+func exponentialBackoff(attempts int, base, max time.Duration) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 30 {
+		return max
+	}
+	backoff := base << (attempts - 1)
+	if backoff <= 0 || backoff > max {
+		return max
+	}
+	return backoff
+}
+
+func toEvent(event *model.OutboxEvent) *Event {
+	return &Event{
+		ID:         event.EventID,
+		Type:       event.EventType,
+		TenantID:   event.TenantID,
+		Subject:    event.Subject,
+		Payload:    json.RawMessage(event.Payload),
+		OccurredAt: event.CreateTime,
+	}
+}
+
+// cleanupSentOutbox 分批删除 before 之前投递成功的事件
+func cleanupSentOutbox(before time.Time) {
+	var total int64
+	for {
+		n, err := dao.DeleteSentOutbox(before, outboxCleanupBatch)
+		if err != nil {
+			log.Errorf("cleanupSentOutbox|err:%v", err)
+			return
+		}
+		total += n
+		if n < outboxCleanupBatch {
+			break
+		}
+	}
+	log.Infof("cleanupSentOutbox|deleted %d sent events", total)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/json"
+	"my_user_system/model"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 9: 256 * time.Second, 10: outboxMaxBackoff, 64: outboxMaxBackoff}
+	for attempts, want := range cases {
+		if got := outboxBackoff(attempts); got != want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestUserUpdatedEvent(t *testing.T) {
+	event := userUpdatedEvent(1, "alice", map[string]interface{}{"password": "hash", "modifier": "alice", "nickname": "a"})
+	if event.EventType != model.EventUserUpdated || event.TenantID != 1 || event.Subject != "alice" || event.EventID == "" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	payload := struct {
+		Changed map[string]interface{} `json:"changed"`
+	}{}
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Changed) != 2 || payload.Changed["password"] != "changed" || payload.Changed["nickname"] != "a" {
+		t.Errorf("changed = %v, want password masked and modifier dropped", payload.Changed)
+	}
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := &MemoryPublisher{}
+	event := toEvent(newOutboxEvent(0, model.EventSessionCreated, "bob", map[string]interface{}{"session_id": "x"}))
+	if err := publisher.Publish(event); err != nil {
+		t.Fatal(err)
+	}
+	if events := publisher.Events(); len(events) != 1 || events[0].ID != event.ID || string(events[0].Payload) != `{"session_id":"x"}` {
+		t.Errorf("events = %+v", events)
+	}
+	publisher.Reset()
+	if len(publisher.Events()) != 0 {
+		t.Errorf("events not reset")
+	}
+}
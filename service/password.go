@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/utils"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	// maxPasswordBytes bcrypt 只使用密码的前 72 个字节
+	maxPasswordBytes = 72
+	// minUserInfoLen 用户名或昵称至少这么长才检查是否出现在密码中，避免单个字符的昵称误伤
+	minUserInfoLen = 3
+	// breachedPrefixLen 泄露密码库按 SHA-1 前 5 位分桶，与 k-匿名查询接口的分桶方式一致
+	breachedPrefixLen = 5
+)
+
+// PasswordPolicyError 密码不满足密码策略，错误信息可以直接展示给用户
+type PasswordPolicyError struct {
+	msg string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.msg
+}
+
+func newPasswordPolicyError(format string, args ...interface{}) error {
+	return &PasswordPolicyError{msg: fmt.Sprintf(format, args...)}
+}
+
+// breachedPasswords 泄露密码库，SHA-1 前缀 -> 排序后的后缀列表
+var breachedPasswords map[string][]string
+
+// InitPasswordPolicy 加载泄露密码库，启动时调用。文件每行一个 SHA-1，可带 :出现次数，无法识别的行会被跳过
+func InitPasswordPolicy() {
+	file := conf.GetGlobalConfig().Password.BreachedFile
+	if file == "" {
+		return
+	}
+	buckets, err := loadBreachedPasswords(file)
+	if err != nil {
+		panic("load breached password file err:" + err.Error())
+	}
+	breachedPasswords = buckets
+	log.Infof("InitPasswordPolicy|loaded breached passwords from %s, buckets=%d", file, len(buckets))
+}
+
+func loadBreachedPasswords(file string) (map[string][]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buckets := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			continue
+		}
+		prefix := hash[:breachedPrefixLen]
+		buckets[prefix] = append(buckets[prefix], hash[breachedPrefixLen:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, suffixes := range buckets {
+		sort.Strings(suffixes)
+	}
+	return buckets, nil
+}
+
+// isBreachedPassword 按 SHA-1 前缀找到分桶，再在桶内查找后缀
+func isBreachedPassword(password string) bool {
+	if len(breachedPasswords) == 0 {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes := breachedPasswords[hash[:breachedPrefixLen]]
+	idx := sort.SearchStrings(suffixes, hash[breachedPrefixLen:])
+	return idx < len(suffixes) && suffixes[idx] == hash[breachedPrefixLen:]
+}
+
+// passwordLengthRange 密码长度范围，最大长度不超过 bcrypt 的限制
+func passwordLengthRange() (int, int) {
+	policy := conf.GetGlobalConfig().Password
+	minLen, maxLen := policy.MinLength, policy.MaxLength
+	if minLen <= 0 {
+		minLen = defaultPasswordMinLength
+	}
+	if maxLen <= 0 || maxLen > maxPasswordBytes {
+		maxLen = maxPasswordBytes
+	}
+	return minLen, maxLen
+}
+
+// passwordClasses 统计密码包含几类字符：大写字母、小写字母、数字、特殊字符
+func passwordClasses(password string) int {
+	var upper, lower, digit, special int
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			special = 1
+		}
+	}
+	return upper + lower + digit + special
+}
+
+// checkPasswordPolicy 校验密码是否满足密码策略，userName 和 nickName 用于检查密码中是否包含用户信息
+func checkPasswordPolicy(password, userName, nickName string) error {
+	policy := conf.GetGlobalConfig().Password
+	minLen, maxLen := passwordLengthRange()
+	length := utf8.RuneCountInString(password)
+	if length < minLen {
+		return newPasswordPolicyError("密码长度不能少于%d位", minLen)
+	}
+	if length > maxLen || len(password) > maxPasswordBytes {
+		return newPasswordPolicyError("密码长度不能超过%d位", maxLen)
+	}
+	if policy.MinClasses > 0 && passwordClasses(password) < policy.MinClasses {
+		return newPasswordPolicyError("密码至少需要包含大写字母、小写字母、数字、特殊字符中的%d类", policy.MinClasses)
+	}
+	if policy.DisallowUserInfo {
+		lower := strings.ToLower(password)
+		for _, info := range []string{userName, nickName} {
+			if utf8.RuneCountInString(info) >= minUserInfoLen && strings.Contains(lower, strings.ToLower(info)) {
+				return newPasswordPolicyError("密码中不能包含用户名或昵称")
+			}
+		}
+	}
+	if isBreachedPassword(password) {
+		return newPasswordPolicyError("该密码已出现在公开泄露的密码库中，请更换其他密码")
+	}
+	return nil
+}
+
+// checkPasswordReuse 新密码不能与当前密码以及最近 history_size 次使用过的密码相同
+func checkPasswordReuse(user *model.User, password string) error {
+	if utils.CheckPassword(user.PassWord, password) {
+		return newPasswordPolicyError("新密码不能与旧密码相同")
+	}
+	size := conf.GetGlobalConfig().Password.HistorySize
+	if size <= 0 {
+		return nil
+	}
+	hashes, err := dao.ListPasswordHistory(user.ID, size)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if utils.CheckPassword(hash, password) {
+			return newPasswordPolicyError("新密码不能与最近%d次使用过的密码相同", size)
+		}
+	}
+	return nil
+}
+
+// recordPasswordHistory 记录新设置的密码哈希，失败不影响主流程
+func recordPasswordHistory(user *model.User, hash string) {
+	size := conf.GetGlobalConfig().Password.HistorySize
+	if size <= 0 {
+		return
+	}
+	history := &model.PasswordHistory{TenantID: user.TenantID, UserID: user.ID, Hash: hash}
+	if err := dao.AddPasswordHistory(history, size); err != nil {
+		log.Errorf("recordPasswordHistory|user_name=%s|err=%v", user.Name, err)
+	}
+}
+
+// upgradePasswordHash 明文保存密码的旧账号登录成功后改为保存哈希
+func upgradePasswordHash(user *model.User, password string) {
+	if utils.IsPasswordHashed(user.PassWord) {
+		return
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Errorf("upgradePasswordHash|hash password err, user_name=%s|err=%v", user.Name, err)
+		return
+	}
+	if _, err := dao.UpdateUserFields(user.TenantID, user.Name, map[string]interface{}{"password": hash}); err != nil {
+		log.Errorf("upgradePasswordHash|user_name=%s|err=%v", user.Name, err)
+		return
+	}
+	user.PassWord = hash
+	cache.InvalidateUserInfo(user.TenantID, user.Name)
+	recordPasswordHistory(user, hash)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"my_user_system/conf"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	policy := &conf.GetGlobalConfig().Password
+	saved := *policy
+	defer func() { *policy = saved }()
+	*policy = conf.PasswordConf{MinLength: 8, MaxLength: 16, MinClasses: 3, DisallowUserInfo: true}
+
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Ab1!", false},               // 太短
+		{"Abcdefgh1234567890", false}, // 太长
+		{"abcdefgh12", false},         // 只有两类字符
+		{"Abcdefgh12", true},          // 大小写和数字
+		{"xx-Alice-2024", false},      // 包含用户名
+		{"Wonder-Land-1", false},      // 包含昵称，不区分大小写
+		{"Bo-1234567", true},          // 不包含用户信息
+	}
+	for _, c := range cases {
+		err := checkPasswordPolicy(c.password, "alice", "wonder")
+		if (err == nil) != c.ok {
+			t.Errorf("checkPasswordPolicy(%q) err = %v, want ok %v", c.password, err, c.ok)
+		}
+		if err != nil {
+			if _, ok := err.(*PasswordPolicyError); !ok {
+				t.Errorf("checkPasswordPolicy(%q) err type %T, want *PasswordPolicyError", c.password, err)
+			}
+		}
+	}
+	if err := checkPasswordPolicy("Bo-1234567", "alice", "Bo"); err != nil {
+		t.Errorf("short nickname should be ignored, got %v", err)
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	defer func() { breachedPasswords = nil }()
+	// sha1("Password123!") 和 sha1("hunter2")，第二行为小写并带出现次数，最后一行无法识别
+	content := "49EFEF5F70D47ADC2DB2EB397FBEF5F7BC560E29\n" +
+		"f3bbbd66a63d4bf1747940578ec3d0103530e21d:17453\n" +
+		"not a hash\n"
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	buckets, err := loadBreachedPasswords(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breachedPasswords = buckets
+	if len(buckets) != 2 {
+		t.Errorf("buckets = %d, want 2", len(buckets))
+	}
+	if !isBreachedPassword("Password123!") || !isBreachedPassword("hunter2") {
+		t.Errorf("passwords in file should be breached")
+	}
+	if isBreachedPassword("hunter3") {
+		t.Errorf("hunter3 should not be breached")
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	cache "my_user_system/controllers"
+	"time"
+)
+
+// RateLimitPolicy 对一个请求生效的一条限流规则
+type RateLimitPolicy struct {
+	Key    string        // 计数键，如 ip:1.2.3.4
+	Limit  int           // 窗口内最大请求数
+	Window time.Duration // 窗口长度
+}
+
+// CheckRateLimit 依次检查各条规则，任一条拒绝即返回该结果；全部放行时返回剩余次数最少的结果，用于设置响应头。
+// 没有生效的规则时返回 nil
+func CheckRateLimit(policies []RateLimitPolicy) *cache.RateLimitResult {
+	var tightest *cache.RateLimitResult
+	for _, policy := range policies {
+		result := cache.AllowRequest(policy.Key, policy.Limit, policy.Window)
+		if !result.Allowed {
+			return result
+		}
+		if tightest == nil || result.Remaining < tightest.Remaining {
+			tightest = result
+		}
+	}
+	return tightest
+}
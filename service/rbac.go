@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+)
+
+// builtinPermissions 内置权限点，启动时写入 t_permission 并全部授予 admin 角色
+var builtinPermissions = map[string]string{
+	static.PermUsersRead:  "查看用户",
+	static.PermUsersWrite: "管理用户",
+	static.PermRolesRead:  "查看角色",
+	static.PermRolesWrite: "管理角色与授权",
+
+	static.PermUsersImpersonate: "模拟用户登录",
+	static.PermOrgsRead:         "查看组织",
+	static.PermOrgsWrite:        "管理组织与成员",
+	static.PermInvitesRead:      "查看邀请码",
+	static.PermInvitesWrite:     "生成与撤销邀请码",
+	static.PermAuditRead:        "查看与校验审计日志",
+	static.PermWebhooksRead:     "查看 Webhook 订阅与投递日志",
+	static.PermWebhooksWrite:    "管理 Webhook 订阅与重新投递",
+	static.PermOAuthRead:        "查看 OAuth 客户端",
+	static.PermOAuthWrite:       "注册与管理 OAuth 客户端",
+}
+
+// InitRBAC 初始化内置权限与各租户的 admin 角色，首次启动时给配置中的用户授予默认租户的 admin 角色
+func InitRBAC() {
+	orgs, err := dao.ListOrganizations()
+	if err != nil {
+		panic("init rbac err:" + err.Error())
+	}
+	var admin *model.Role
+	for _, org := range orgs {
+		role, err := ensureAdminRole(org.ID, "system")
+		if err != nil {
+			panic("init rbac err:" + err.Error())
+		}
+		if org.ID == defaultTenantID {
+			admin = role
+		}
+	}
+	if admin == nil {
+		panic("init rbac err: default tenant not found")
+	}
+
+	bootstrapAdmins(admin)
+}
+
+// ensureAdminRole 确保租户下存在 admin 角色，并拥有全部内置权限
+func ensureAdminRole(tenantID int, operator string) (*model.Role, error) {
+	admin := &model.Role{
+		TenantID:    tenantID,
+		Name:        static.RoleAdmin,
+		Description: "系统管理员",
+		CreateModel: model.CreateModel{Creator: operator},
+		ModifyModel: model.ModifyModel{Modifier: operator},
+	}
+	if err := dao.EnsureRole(admin); err != nil {
+		return nil, err
+	}
+	for code, desc := range builtinPermissions {
+		perm := &model.Permission{
+			Code:        code,
+			Description: desc,
+			CreateModel: model.CreateModel{Creator: "system"},
+		}
+		if err := dao.EnsurePermission(perm); err != nil {
+			return nil, err
+		}
+		if err := dao.GrantPermission(admin.ID, perm.ID, operator); err != nil {
+			return nil, err
+		}
+	}
+	return admin, nil
+}
+
+// bootstrapAdmins 还没有任何用户拥有 admin 角色时，把 rbac.admin_users 设为管理员。
+// 只在初始化时生效一次，之后管理员由管理后台维护，被撤销的管理员不会在重启时恢复
+func bootstrapAdmins(admin *model.Role) {
+	adminUsers := conf.GetGlobalConfig().RBAC.AdminUsers
+	if len(adminUsers) == 0 {
+		return
+	}
+	count, err := dao.CountRoleUsers(admin.ID)
+	if err != nil {
+		panic("init rbac err:" + err.Error())
+	}
+	if count > 0 {
+		log.Infof("InitRBAC|admin role already assigned, skip rbac.admin_users")
+		return
+	}
+	for _, name := range adminUsers {
+		user, err := dao.GetUserByName(defaultTenantID, name)
+		if err != nil || user == nil {
+			log.Warnf("InitRBAC|admin user %s not found, skip", name)
+			continue
+		}
+		if err := dao.AssignRole(user.ID, admin.ID, "system"); err != nil {
+			log.Errorf("InitRBAC|assign admin to %s err:%v", name, err)
+			continue
+		}
+		cache.DelPermissionCache(defaultTenantID, name)
+		log.Infof("InitRBAC|bootstrap admin user %s", name)
+	}
+}
+
+// GetPrincipal 根据会话获取当前租户下的登录用户
+func GetPrincipal(tenantID int, session string) (*model.User, error) {
+	if session == "" {
+		return nil, fmt.Errorf("session is empty")
+	}
+	return cache.GetSessionInfo(tenantID, session)
+}
+
+// GetEffectivePermissions 获取用户有效权限，优先读缓存
+func GetEffectivePermissions(user *model.User) ([]string, error) {
+	perms, err := cache.GetPermissionCache(user.TenantID, user.Name)
+	if err == nil {
+		return perms, nil
+	}
+
+	perms, err = dao.GetUserPermissionCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.SetPermissionCache(user.TenantID, user.Name, perms); err != nil {
+		log.Errorf("cache permissions failed for user:%s with err:%v", user.Name, err)
+	}
+	return perms, nil
+}
+
+// HasPermission 判断用户是否拥有某个权限
+func HasPermission(user *model.User, perm string) (bool, error) {
+	perms, err := GetEffectivePermissions(user)
+	if err != nil {
+		return false, err
+	}
+	return utils.Contains(perms, perm), nil
+}
+
+// CreateRole 在当前租户下创建角色
+func CreateRole(ctx context.Context, req *CreateRoleRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.RoleName == "" {
+		return fmt.Errorf("CreateRole|request params invalid")
+	}
+	tenantID := tenantFromCtx(ctx)
+	existed, err := dao.GetRoleByName(tenantID, req.RoleName)
+	if err != nil {
+		return fmt.Errorf("CreateRole|%v", err)
+	}
+	if existed != nil {
+		return fmt.Errorf("角色已存在")
+	}
+	role := &model.Role{
+		TenantID:    tenantID,
+		Name:        req.RoleName,
+		Description: req.Description,
+		CreateModel: model.CreateModel{Creator: operator},
+		ModifyModel: model.ModifyModel{Modifier: operator},
+	}
+	if err := dao.CreateRole(role); err != nil {
+		return fmt.Errorf("CreateRole|%v", err)
+	}
+	writeAuditDiff(ctx, operator, role.Name, model.AuditRoleCreate, "", nil, map[string]interface{}{"description": role.Description})
+	log.Infof("%s|CreateRole success, role=%s|operator=%s", uuid, req.RoleName, operator)
+	return nil
+}
+
+// ListRoles 列出当前租户的全部角色及其权限
+func ListRoles(ctx context.Context) ([]*RoleInfo, error) {
+	roles, err := dao.ListRoles(tenantFromCtx(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("ListRoles|%v", err)
+	}
+	infos := make([]*RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		perms, err := dao.GetRolePermissionCodes(role.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ListRoles|%v", err)
+		}
+		infos = append(infos, &RoleInfo{
+			RoleName:    role.Name,
+			Description: role.Description,
+			Permissions: perms,
+		})
+	}
+	return infos, nil
+}
+
+// getRoleAndPermission 校验并获取租户下的角色与权限
+func getRoleAndPermission(tenantID int, req *RolePermissionRequest) (*model.Role, *model.Permission, error) {
+	if req.RoleName == "" || req.Permission == "" {
+		return nil, nil, fmt.Errorf("request params invalid")
+	}
+	role, err := dao.GetRoleByName(tenantID, req.RoleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, fmt.Errorf("角色不存在")
+	}
+	perm, err := dao.GetPermissionByCode(req.Permission)
+	if err != nil {
+		return nil, nil, err
+	}
+	if perm == nil {
+		return nil, nil, fmt.Errorf("权限不存在")
+	}
+	return role, perm, nil
+}
+
+// invalidateRolePermissions 角色权限变更后，清理该角色下所有用户的权限缓存
+func invalidateRolePermissions(roleID int) {
+	users, err := dao.GetUsersByRole(roleID)
+	if err != nil {
+		log.Errorf("invalidateRolePermissions|role_id=%d|err=%v", roleID, err)
+		return
+	}
+	for _, user := range users {
+		if err := cache.DelPermissionCache(user.TenantID, user.Name); err != nil {
+			log.Errorf("invalidateRolePermissions|role_id=%d|user_name=%s|err=%v", roleID, user.Name, err)
+		}
+	}
+}
+
+// GrantPermission 给角色授予权限
+func GrantPermission(ctx context.Context, req *RolePermissionRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	role, perm, err := getRoleAndPermission(tenantFromCtx(ctx), req)
+	if err != nil {
+		return fmt.Errorf("GrantPermission|%v", err)
+	}
+	if err := dao.GrantPermission(role.ID, perm.ID, operator); err != nil {
+		return fmt.Errorf("GrantPermission|%v", err)
+	}
+	invalidateRolePermissions(role.ID)
+	writeAudit(ctx, operator, role.Name, model.AuditRoleGrant, "permission="+perm.Code)
+	log.Infof("%s|GrantPermission success, role=%s|permission=%s|operator=%s", uuid, role.Name, perm.Code, operator)
+	return nil
+}
+
+// RevokePermission 收回角色的权限
+func RevokePermission(ctx context.Context, req *RolePermissionRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	role, perm, err := getRoleAndPermission(tenantFromCtx(ctx), req)
+	if err != nil {
+		return fmt.Errorf("RevokePermission|%v", err)
+	}
+	if err := dao.RevokePermission(role.ID, perm.ID); err != nil {
+		return fmt.Errorf("RevokePermission|%v", err)
+	}
+	invalidateRolePermissions(role.ID)
+	writeAudit(ctx, operator, role.Name, model.AuditRoleRevoke, "permission="+perm.Code)
+	log.Infof("%s|RevokePermission success, role=%s|permission=%s|operator=%s", uuid, role.Name, perm.Code, operator)
+	return nil
+}
+
+// getUserAndRole 校验并获取租户下的用户与角色
+func getUserAndRole(tenantID int, req *UserRoleRequest) (*model.User, *model.Role, error) {
+	if req.UserName == "" || req.RoleName == "" {
+		return nil, nil, fmt.Errorf("request params invalid")
+	}
+	user, err := dao.GetUserByName(tenantID, req.UserName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("用户尚未注册")
+	}
+	role, err := dao.GetRoleByName(tenantID, req.RoleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, fmt.Errorf("角色不存在")
+	}
+	return user, role, nil
+}
+
+// AssignRole 给用户分配角色
+func AssignRole(ctx context.Context, req *UserRoleRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	user, role, err := getUserAndRole(tenantFromCtx(ctx), req)
+	if err != nil {
+		return fmt.Errorf("AssignRole|%v", err)
+	}
+	if err := dao.AssignRole(user.ID, role.ID, operator); err != nil {
+		return fmt.Errorf("AssignRole|%v", err)
+	}
+	cache.DelPermissionCache(user.TenantID, user.Name)
+	writeAudit(ctx, operator, user.Name, model.AuditRoleAssign, "role="+role.Name)
+	log.Infof("%s|AssignRole success, user_name=%s|role=%s|operator=%s", uuid, user.Name, role.Name, operator)
+	return nil
+}
+
+// UnassignRole 取消用户的角色
+func UnassignRole(ctx context.Context, req *UserRoleRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	user, role, err := getUserAndRole(tenantFromCtx(ctx), req)
+	if err != nil {
+		return fmt.Errorf("UnassignRole|%v", err)
+	}
+	if err := dao.UnassignRole(user.ID, role.ID); err != nil {
+		return fmt.Errorf("UnassignRole|%v", err)
+	}
+	cache.DelPermissionCache(user.TenantID, user.Name)
+	writeAudit(ctx, operator, user.Name, model.AuditRoleUnassign, "role="+role.Name)
+	log.Infof("%s|UnassignRole success, user_name=%s|role=%s|operator=%s", uuid, user.Name, role.Name, operator)
+	return nil
+}
+
+// GetUserRoles 查询用户的角色与有效权限
+func GetUserRoles(ctx context.Context, req *GetUserRolesRequest) (*GetUserRolesResponse, error) {
+	if req.UserName == "" {
+		return nil, fmt.Errorf("GetUserRoles|request params invalid")
+	}
+	user, err := dao.GetUserByName(tenantFromCtx(ctx), req.UserName)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRoles|%v", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("用户尚未注册")
+	}
+	roles, err := dao.GetUserRoleNames(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRoles|%v", err)
+	}
+	perms, err := dao.GetUserPermissionCodes(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRoles|%v", err)
+	}
+	return &GetUserRolesResponse{
+		UserName:    user.Name,
+		Roles:       roles,
+		Permissions: perms,
+	}, nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"my_user_system/conf"
+	cache "my_user_system/controllers"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tenantResolveTTL 租户解析结果在进程内的缓存时间
+const tenantResolveTTL = time.Minute
+
+var (
+	defaultTenantID int
+	// tenantResolved 缓存租户标识/域名到租户ID的解析结果，避免每个请求都查库
+	tenantResolved sync.Map
+)
+
+type resolvedTenant struct {
+	id       int
+	expireAt time.Time
+}
+
+// InitTenants 确保默认租户存在，并把存量用户和角色划入默认租户
+func InitTenants() {
+	org := &model.Organization{
+		Code:        model.DefaultTenantCode,
+		Name:        "默认租户",
+		CreateModel: model.CreateModel{Creator: "system"},
+		ModifyModel: model.ModifyModel{Modifier: "system"},
+	}
+	if err := dao.EnsureOrganization(org); err != nil {
+		panic("init tenants err:" + err.Error())
+	}
+	defaultTenantID = org.ID
+
+	n, err := dao.MigrateUsersToTenant(defaultTenantID)
+	if err != nil {
+		panic("init tenants err:" + err.Error())
+	}
+	if n > 0 {
+		log.Infof("InitTenants|migrate %d users to default tenant %d", n, defaultTenantID)
+	}
+	// 角色改为按租户隔离前创建的角色归属默认租户
+	n, err = dao.MigrateRolesToTenant(defaultTenantID)
+	if err != nil {
+		panic("init tenants err:" + err.Error())
+	}
+	if n > 0 {
+		log.Infof("InitTenants|migrate %d roles to default tenant %d", n, defaultTenantID)
+	}
+}
+
+// tenantFromCtx 获取上下文中的租户，未指定时为默认租户
+func tenantFromCtx(ctx context.Context) int {
+	if tenantID, ok := ctx.Value(static.TenantKey).(int); ok && tenantID > 0 {
+		return tenantID
+	}
+	return defaultTenantID
+}
+
+// ResolveTenant 解析请求所属租户，优先级为 路径 > 请求头 > 域名，都未指定时为默认租户
+func ResolveTenant(pathCode, headerCode, host string) (int, error) {
+	if pathCode != "" {
+		return resolveTenantBy("code:"+pathCode, func() (*model.Organization, error) {
+			return dao.GetOrganizationByCode(pathCode)
+		})
+	}
+	if headerCode != "" {
+		return resolveTenantBy("code:"+headerCode, func() (*model.Organization, error) {
+			return dao.GetOrganizationByCode(headerCode)
+		})
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "" {
+		return defaultTenantID, nil
+	}
+	tenantID, err := resolveTenantBy("host:"+host, func() (*model.Organization, error) {
+		org, err := dao.GetOrganizationByHost(host)
+		if err != nil || org != nil {
+			return org, err
+		}
+		baseDomain := conf.GetGlobalConfig().Tenant.BaseDomain
+		if baseDomain != "" && strings.HasSuffix(host, "."+baseDomain) {
+			return dao.GetOrganizationByCode(strings.TrimSuffix(host, "."+baseDomain))
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if tenantID == 0 {
+		// 域名未绑定租户时使用默认租户
+		return defaultTenantID, nil
+	}
+	return tenantID, nil
+}
+
+// resolveTenantBy 带进程内缓存的租户解析，租户不存在时通过标识指定返回错误，通过域名指定返回 0
+func resolveTenantBy(key string, load func() (*model.Organization, error)) (int, error) {
+	if val, ok := tenantResolved.Load(key); ok {
+		resolved := val.(*resolvedTenant)
+		if time.Now().Before(resolved.expireAt) {
+			return resolved.id, nil
+		}
+	}
+	org, err := load()
+	if err != nil {
+		return 0, err
+	}
+	id := 0
+	if org != nil {
+		id = org.ID
+	}
+	tenantResolved.Store(key, &resolvedTenant{id: id, expireAt: time.Now().Add(tenantResolveTTL)})
+	if id == 0 && strings.HasPrefix(key, "code:") {
+		return 0, fmt.Errorf("租户不存在")
+	}
+	return id, nil
+}
+
+// resolveManagedOrg 获取要管理的组织，code 为空时为当前租户，管理其他租户只能在默认租户下进行
+func resolveManagedOrg(ctx context.Context, code string) (*model.Organization, error) {
+	tenantID := tenantFromCtx(ctx)
+	if code == "" {
+		org, err := dao.GetOrganizationByID(tenantID)
+		if err == nil && org == nil {
+			err = fmt.Errorf("租户不存在")
+		}
+		return org, err
+	}
+	org, err := dao.GetOrganizationByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, fmt.Errorf("租户不存在")
+	}
+	if org.ID != tenantID && tenantID != defaultTenantID {
+		return nil, fmt.Errorf("只能在默认租户下管理其他租户")
+	}
+	return org, nil
+}
+
+// CreateOrganization 创建组织，只能在默认租户下操作
+func CreateOrganization(ctx context.Context, req *CreateOrgRequest) (*OrgInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if tenantFromCtx(ctx) != defaultTenantID {
+		return nil, fmt.Errorf("只能在默认租户下创建组织")
+	}
+	if req.Code == "" || req.Name == "" || strings.ContainsAny(req.Code, "/.: ") {
+		return nil, fmt.Errorf("CreateOrganization|request params invalid")
+	}
+	existed, err := dao.GetOrganizationByCode(req.Code)
+	if err != nil {
+		return nil, fmt.Errorf("CreateOrganization|%v", err)
+	}
+	if existed != nil {
+		return nil, fmt.Errorf("租户标识已存在")
+	}
+	org := &model.Organization{
+		Code:        req.Code,
+		Name:        req.Name,
+		Host:        strings.ToLower(req.Host),
+		CreateModel: model.CreateModel{Creator: operator},
+		ModifyModel: model.ModifyModel{Modifier: operator},
+	}
+	if err := dao.CreateOrganization(org); err != nil {
+		return nil, fmt.Errorf("CreateOrganization|%v", err)
+	}
+	if _, err := ensureAdminRole(org.ID, operator); err != nil {
+		return nil, fmt.Errorf("CreateOrganization|%v", err)
+	}
+	// 之前可能缓存过“不存在”的解析结果
+	tenantResolved.Delete("code:" + org.Code)
+	tenantResolved.Delete("host:" + org.Host)
+	writeAuditDiff(ctx, operator, org.Code, model.AuditOrgCreate, "", nil,
+		map[string]interface{}{"name": org.Name, "host": org.Host})
+	log.Infof("%s|CreateOrganization success, code=%s|operator=%s", uuid, org.Code, operator)
+	return &OrgInfo{ID: org.ID, Code: org.Code, Name: org.Name, Host: org.Host}, nil
+}
+
+// ListOrganizations 列出全部组织，只能在默认租户下操作
+func ListOrganizations(ctx context.Context) ([]*OrgInfo, error) {
+	if tenantFromCtx(ctx) != defaultTenantID {
+		return nil, fmt.Errorf("只能在默认租户下查看组织列表")
+	}
+	orgs, err := dao.ListOrganizations()
+	if err != nil {
+		return nil, fmt.Errorf("ListOrganizations|%v", err)
+	}
+	infos := make([]*OrgInfo, 0, len(orgs))
+	for _, org := range orgs {
+		infos = append(infos, &OrgInfo{ID: org.ID, Code: org.Code, Name: org.Name, Host: org.Host})
+	}
+	return infos, nil
+}
+
+// ListOrgMembers 列出组织成员
+func ListOrgMembers(ctx context.Context, req *OrgRequest) ([]*OrgMemberInfo, error) {
+	org, err := resolveManagedOrg(ctx, req.Code)
+	if err != nil {
+		return nil, fmt.Errorf("ListOrgMembers|%v", err)
+	}
+	members, err := dao.ListOrgMembers(org.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ListOrgMembers|%v", err)
+	}
+	infos := make([]*OrgMemberInfo, 0, len(members))
+	for _, member := range members {
+		infos = append(infos, &OrgMemberInfo{UserName: member.UserName, Role: member.Role})
+	}
+	return infos, nil
+}
+
+// SetOrgMemberRole 设置组织成员角色，用户必须属于该租户
+func SetOrgMemberRole(ctx context.Context, req *SetOrgMemberRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if req.UserName == "" || !utils.Contains([]string{model.MemberRoleOwner, model.MemberRoleAdmin, model.MemberRoleMember}, req.Role) {
+		return fmt.Errorf("SetOrgMemberRole|request params invalid")
+	}
+	org, err := resolveManagedOrg(ctx, req.Code)
+	if err != nil {
+		return fmt.Errorf("SetOrgMemberRole|%v", err)
+	}
+	user, err := dao.GetUserByName(org.ID, req.UserName)
+	if err != nil {
+		return fmt.Errorf("SetOrgMemberRole|%v", err)
+	}
+	if user == nil {
+		return fmt.Errorf("用户不属于该租户")
+	}
+	oldRole, err := dao.GetOrgMemberRole(org.ID, user.ID)
+	if err != nil {
+		return fmt.Errorf("SetOrgMemberRole|%v", err)
+	}
+	if err := dao.SetOrgMember(org.ID, user.ID, req.Role, operator); err != nil {
+		return fmt.Errorf("SetOrgMemberRole|%v", err)
+	}
+	writeAuditDiff(ctx, operator, req.UserName, model.AuditOrgMemberRole, "org="+org.Code,
+		map[string]interface{}{"role": oldRole}, map[string]interface{}{"role": req.Role})
+	log.Infof("%s|SetOrgMemberRole success, org=%s|user_name=%s|role=%s|operator=%s", uuid, org.Code, req.UserName, req.Role, operator)
+	return nil
+}
+
+// FlushTenantCache 清理租户的全部缓存，该租户下的用户需要重新登录
+func FlushTenantCache(ctx context.Context, req *OrgRequest) (*FlushTenantCacheResponse, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	org, err := resolveManagedOrg(ctx, req.Code)
+	if err != nil {
+		return nil, fmt.Errorf("FlushTenantCache|%v", err)
+	}
+	deleted, err := cache.FlushTenantCache(org.ID)
+	if err != nil {
+		return nil, fmt.Errorf("FlushTenantCache|%v", err)
+	}
+	writeAudit(ctx, operator, org.Code, model.AuditTenantFlush, fmt.Sprintf("deleted=%d", deleted))
+	log.Infof("%s|FlushTenantCache success, org=%s|deleted=%d|operator=%s", uuid, org.Code, deleted, operator)
+	return &FlushTenantCacheResponse{Deleted: deleted}, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus" // 当包名和包的目录名不一样就需要起别名，否则不需要
 	cache "my_user_system/controllers"
@@ -9,14 +10,29 @@ import (
 	"my_user_system/model"
 	"my_user_system/static"
 	"my_user_system/utils"
+	"time"
 )
 
-func Register(req *RegisterRequest) error {
+// ErrSessionInvalid 会话不存在或已过期，需要重新登录
+var ErrSessionInvalid = errors.New("会话已失效，请重新登录")
+
+// errUserNotRegistered 用户不存在
+var errUserNotRegistered = errors.New("用户尚未注册")
+
+func Register(ctx context.Context, req *RegisterRequest) error {
+	tenantID := tenantFromCtx(ctx)
 	if req.UserName == "" || req.Password == "" || req.Age <= 0 || !utils.Contains([]string{static.GenderMale, static.GenderFeMale}, req.Gender) {
 		log.Errorf("register param invalid")
 		return fmt.Errorf("register param invalid")
 	}
-	existedUser, err := dao.GetUserByName(req.UserName)
+	// 注册总是需要人机校验，放在查库之前，避免被用来探测用户名
+	if challengeEnabled() {
+		if err := verifyChallenge(req.ChallengeID, req.ChallengeAnswer); err != nil {
+			log.Warnf("Register|challenge failed, user_name=%s|err=%v", req.UserName, err)
+			return err
+		}
+	}
+	existedUser, err := dao.GetUserByName(tenantID, req.UserName)
 	if err != nil {
 		log.Errorf("Register|%v", err)
 		return fmt.Errorf("register|%v", err)
@@ -25,8 +41,23 @@ func Register(req *RegisterRequest) error {
 		log.Errorf("user is existed, user_name == %s", req.UserName)
 		return fmt.Errorf("用户已注册，不能重复注册！")
 	}
+	if err := checkPasswordPolicy(req.Password, req.UserName, req.NickName); err != nil {
+		log.Warnf("Register|password rejected by policy, user_name=%s|err=%v", req.UserName, err)
+		return err
+	}
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return fmt.Errorf("register|hash password err:%v", err)
+	}
+	invite, err := acquireInvitation(tenantID, req.InviteCode)
+	if err != nil {
+		log.Errorf("Register|invitation check failed, user_name=%s|err=%v", req.UserName, err)
+		return err
+	}
 	user := &model.User{
+		TenantID: tenantID,
 		Name:     req.UserName,
+		PassWord: hash,
 		Age:      req.Age,
 		Gender:   req.Gender,
 		NickName: req.NickName,
@@ -37,92 +68,204 @@ func Register(req *RegisterRequest) error {
 			Modifier: req.UserName,
 		},
 	}
+	if invite != nil {
+		user.Inviter = invite.Inviter
+	}
 	log.Infof("user ====== %+v", user)
-	if err := dao.CreateUser(user); err != nil {
+	if err := dao.CreateUser(user, userRegisteredEvent(user)); err != nil {
 		log.Errorf("Register|%v", err)
+		if invite != nil {
+			dao.ReleaseInvitation(invite.ID)
+		}
 		return fmt.Errorf("register|%v", err)
 	}
+	// 清掉注册前可能留下的负缓存
+	cache.InvalidateUserInfo(tenantID, req.UserName)
+	recordPasswordHistory(user, hash)
+	detail := ""
+	if invite != nil {
+		detail = "invite=" + invite.Code
+	}
+	writeAuditDiff(ctx, req.UserName, req.UserName, model.AuditUserRegister, detail, nil, userAuditFields(user))
+	if err := dao.SetOrgMember(tenantID, user.ID, model.MemberRoleMember, req.UserName); err != nil {
+		log.Errorf("Register|add org member failed, user_name=%s|err=%v", req.UserName, err)
+	}
+	if invite != nil {
+		applyInvitationRoles(user, invite)
+	}
 	return nil
 }
 
-// Login 函数处理用户登录逻辑
-func Login(ctx context.Context, req *LoginRequest) (string, error) {
+// Login 函数处理用户登录逻辑，返回会话标识符和会话有效期
+func Login(ctx context.Context, req *LoginRequest) (string, time.Duration, error) {
 	// 从上下文中获取唯一标识符
 	uuid := ctx.Value(static.ReqUuid)
 
 	// 记录登录请求的详细信息
-	log.Debugf("%s| Login access from:%s,@,%s", uuid, req.UserName, req.PassWord)
+	log.Debugf("%s| Login access from:%s", uuid, req.UserName)
+
+	// 每次登录尝试都记录结果，提前返回时 result 为对应的失败原因
+	result := model.LoginResultError
+	defer func() { recordLoginAttempt(ctx, req.UserName, result) }()
+
+	// 登录失败次数过多时需要先通过人机校验
+	if loginChallengeRequired(ctx, req.UserName) {
+		if err := verifyChallenge(req.ChallengeID, req.ChallengeAnswer); err != nil {
+			log.Warnf("%s|Login|challenge failed, user_name=%s|err=%v", uuid, req.UserName, err)
+			result = model.LoginResultChallenge
+			return "", 0, err
+		}
+	}
 
 	// 获取用户信息
-	user, err := getUserInfo(req.UserName)
+	tenantID := tenantFromCtx(ctx)
+	user, err := getUserInfo(tenantID, req.UserName)
 	if err != nil {
 		log.Errorf("Login|%v1", err)
-		return "", fmt.Errorf("Login|%v1", err)
+		if err == errUserNotRegistered {
+			result = model.LoginResultUserNotFound
+		}
+		recordLoginFailure(ctx, req.UserName)
+		return "", 0, fmt.Errorf("Login|%v1", err)
 	}
 
 	// 检查密码是否正确
-	if req.PassWord != user.PassWord {
-		log.Errorf("Login|password err: req.password=%s|user.password=%s", req.PassWord, user.PassWord)
-		return "", fmt.Errorf("password is not correct")
+	if !utils.CheckPassword(user.PassWord, req.PassWord) {
+		log.Errorf("Login|password err, user_name=%s", req.UserName)
+		result = model.LoginResultWrongPassword
+		recordLoginFailure(ctx, req.UserName)
+		return "", 0, fmt.Errorf("password is not correct")
 	}
 
+	// 检查账号状态
+	if user.Status == model.UserStatusDisabled {
+		log.Errorf("Login|user is disabled, user_name=%s", user.Name)
+		result = model.LoginResultDisabled
+		return "", 0, fmt.Errorf("账号已被禁用")
+	}
+	if user.PwdResetRequired {
+		log.Errorf("Login|password reset required, user_name=%s", user.Name)
+		result = model.LoginResultPwdReset
+		return "", 0, fmt.Errorf("密码已被管理员重置，请先修改密码")
+	}
+
+	upgradePasswordHash(user, req.PassWord)
+
 	// 生成用户会话标识符
-	session := utils.GenerateSession(user.Name)
+	session, err := utils.GenerateSession(user.Name)
+	if err != nil {
+		return "", 0, fmt.Errorf("Login|generate session err:%v", err)
+	}
 
 	// 将用户信息和会话标识符存储到缓存中
-	err = cache.SetSessionInfo(user, session)
+	ttl, err := cache.CreateSession(user, session, req.Remember)
 	if err != nil {
 		log.Errorf(" Login|Failed to SetSessionInfo, uuid=%s|user_name=%s|session=%s|err=%v1", uuid, user.Name, session, err)
-		return "", fmt.Errorf("Login|SetSessionInfo fail:%v1", err)
+		return "", 0, fmt.Errorf("Login|SetSessionInfo fail:%v1", err)
 	}
 
+	resetLoginFailure(ctx, req.UserName)
+	result = model.LoginResultSuccess
+	emitSessionEvent(tenantID, model.EventSessionCreated, user.Name, session,
+		map[string]interface{}{"remember": req.Remember, "expire_in": int(ttl.Seconds())})
+
 	// 记录登录成功信息
-	log.Infof("Login successfully, %s@%s with redis_session session_%s", req.UserName, req.PassWord, session)
+	log.Infof("Login successfully, %s with redis_session session_%s", req.UserName, session)
 
 	// 返回会话标识符和空错误表示登录成功
-	return session, nil
+	return session, ttl, nil
 }
 
 // Logout 退出登陆
 func Logout(ctx context.Context, req *LogoutRequest) error {
 	uuid := ctx.Value(static.ReqUuid)
 	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
 	log.Infof("%s|Logout access from,user_name=%s|session=%s", uuid, req.UserName, session)
 	// 要退出登录，必须要是在登录态
-	_, err := cache.GetSessionInfo(session)
+	user, err := cache.GetSessionInfo(tenantID, session)
 	if err != nil {
 		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
 		return fmt.Errorf("Logout|GetSessionInfo err:%v", err)
 	}
 
-	err = cache.DelSessionInfo(session)
+	// 模拟登录会话登出即结束模拟
+	if info, err := cache.GetImpersonation(tenantID, session); err == nil {
+		endImpersonation(ctx, session, info)
+		return nil
+	}
+
+	err = cache.DelSessionInfo(tenantID, session)
 	if err != nil {
 		log.Errorf("%s|Failed to delSessionInfo :%s", uuid, session)
 		return fmt.Errorf("del session err:%v", err)
 	}
+	writeAudit(ctx, user.Name, user.Name, model.AuditSessionLogout, "")
+	emitSessionEvent(tenantID, model.EventSessionRevoked, user.Name, session, map[string]interface{}{"reason": "logout"})
 	log.Infof("%s|Success to delSessionInfo :%s", uuid, session)
 	return nil
 }
 
-func getUserInfo(userName string) (*model.User, error) {
-	user, err := cache.GetUserInfoFromCache(userName)
-	if err == nil && user.Name == userName {
-		log.Infof("cache_user ======= %v", user)
-		return user, nil
+// TouchSession 已登录的请求对会话滑动续期，返回会话剩余有效期。会话不存在或已过期时返回 ErrSessionInvalid
+func TouchSession(tenantID int, session string) (time.Duration, error) {
+	ttl, err := cache.TouchSession(tenantID, session)
+	if cache.IsMiss(err) || err == cache.ErrSessionExpired {
+		return 0, ErrSessionInvalid
 	}
+	return ttl, err
+}
 
-	user, err = dao.GetUserByName(userName)
+// ValidateSession 校验会话是否有效，供后端服务调用，不对会话续期。会话不存在或已过期时返回 Valid=false
+func ValidateSession(ctx context.Context, session string) (*ValidateSessionResponse, error) {
+	tenantID := tenantFromCtx(ctx)
+	rsp := &ValidateSessionResponse{}
+	user, ttl, err := lookupSession(tenantID, session)
 	if err != nil {
-		return user, err
+		return nil, fmt.Errorf("ValidateSession|%v", err)
 	}
-
 	if user == nil {
-		return nil, fmt.Errorf("用户尚未注册")
+		return rsp, nil
+	}
+	rsp.Valid, rsp.UserName, rsp.ExpiresIn = true, user.Name, int64(ttl.Seconds())
+	if info, err := cache.GetImpersonation(tenantID, session); err == nil {
+		rsp.Impersonated = true
+		rsp.Impersonator = info.Actor
+	}
+	return rsp, nil
+}
+
+// lookupSession 查询会话对应的用户和剩余有效期，不续期。会话不存在或已过期时返回 nil
+func lookupSession(tenantID int, session string) (*model.User, time.Duration, error) {
+	if session == "" {
+		return nil, 0, nil
+	}
+	user, err := cache.GetSessionInfo(tenantID, session)
+	if cache.IsMiss(err) || err == cache.ErrSessionExpired {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSessionInfo err:%v", err)
+	}
+	ttl, err := cache.GetSessionTTL(tenantID, session)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSessionTTL err:%v", err)
+	}
+	if ttl <= 0 {
+		return nil, 0, nil
+	}
+	return user, ttl, nil
+}
+
+// getUserInfo 通过读穿透缓存获取用户信息，并发未命中时只回源一次
+func getUserInfo(tenantID int, userName string) (*model.User, error) {
+	user, err := cache.LoadUserInfo(tenantID, userName, func() (*model.User, error) {
+		return dao.GetUserByName(tenantID, userName)
+	})
+	if err == cache.ErrUserNotFound {
+		return nil, errUserNotRegistered
 	}
-	log.Infof("user === %+v", user)
-	err = cache.SetUserCacheInfo(user)
 	if err != nil {
-		log.Error("cache userinfo failed for user:", user.Name, " with err:", err.Error())
+		return nil, err
 	}
 	log.Infof("getUserInfo successfully, with key userinfo_%s", user.Name)
 	return user, nil
@@ -132,13 +275,14 @@ func getUserInfo(userName string) (*model.User, error) {
 func GetUserInfo(ctx context.Context, req *GetUserInfoRequest) (*GetUserInfoResponse, error) {
 	uuid := ctx.Value(static.ReqUuid)
 	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
 	log.Infof("%s|GetUserInfo access from,user_name=%s|session=%s", uuid, req.UserName, session)
 
 	if session == "" || req.UserName == "" {
 		return nil, fmt.Errorf("GetUserInfo|request params invalid")
 	}
 
-	user, err := cache.GetSessionInfo(session)
+	user, err := cache.GetSessionInfo(tenantID, session)
 	if err != nil {
 		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
 		return nil, fmt.Errorf("getUserInfo|GetSessionInfo err:%v", err)
@@ -148,33 +292,52 @@ func GetUserInfo(ctx context.Context, req *GetUserInfoRequest) (*GetUserInfoResp
 		log.Errorf("%s|session info not match with username=%s", uuid, req.UserName)
 	}
 	log.Infof("%s|Succ to GetUserInfo|user_name=%s|session=%s", uuid, req.UserName, session)
-	return &GetUserInfoResponse{
+	rsp := &GetUserInfoResponse{
 		UserName: user.Name,
 		Age:      user.Age,
 		Gender:   user.Gender,
-		PassWord: user.PassWord,
 		NickName: user.NickName,
-	}, nil
+	}
+	if info, err := cache.GetImpersonation(tenantID, session); err == nil {
+		rsp.Impersonated = true
+		rsp.Impersonator = info.Actor
+	}
+	return rsp, nil
 }
 
-func updateUserInfo(user *model.User, userName, session string) error {
-	affectedRows := dao.UpdateUserInfo(userName, user)
-
-	// db更新成功
-	if affectedRows == 1 {
-		user, err := dao.GetUserByName(userName)
-		if err == nil {
-			cache.UpdateCachedUserInfo(user)
-			if session != "" {
-				err = cache.SetSessionInfo(user, session)
-				if err != nil {
-					log.Error("update session failed:", err.Error())
-					cache.DelSessionInfo(session)
-				}
-			}
-		} else {
-			log.Error("Failed to get dbUserInfo for cache, username=%s with err:", userName, err.Error())
-		}
+// checkSessionUser 请求中的用户名必须是会话所属的用户，不能借自己的会话修改其他用户
+func checkSessionUser(sessionUser *model.User, userName string) error {
+	if sessionUser == nil || sessionUser.Name != userName {
+		return fmt.Errorf("session info not match")
+	}
+	return nil
+}
+
+// updateUserInfo 更新用户信息，更新后删除用户缓存而不是覆盖写，由下一次读请求回源，
+// 避免与并发读请求回填的旧数据互相覆盖
+func updateUserInfo(tenantID int, user *model.User, userName, session string, changed map[string]interface{}) error {
+	affectedRows, err := dao.UpdateUserInfo(tenantID, userName, user, userUpdatedEvent(tenantID, userName, changed))
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return fmt.Errorf("用户尚未注册")
+	}
+	cache.InvalidateUserInfo(tenantID, userName)
+
+	// 会话中保存了用户信息的副本，以数据库最新数据刷新，只刷新属于该用户的会话
+	if session == "" {
+		return nil
+	}
+	user, err = dao.GetUserByName(tenantID, userName)
+	if err != nil || user == nil {
+		log.Errorf("Failed to get dbUserInfo for session, username=%s with err:%v", userName, err)
+		cache.DelSessionInfo(tenantID, session)
+		return nil
+	}
+	if err := cache.SetSessionInfo(user, session); err != nil {
+		log.Error("update session failed:", err.Error())
+		cache.DelSessionInfo(tenantID, session)
 	}
 	return nil
 }
@@ -182,6 +345,7 @@ func updateUserInfo(user *model.User, userName, session string) error {
 func UpdateUserNickName(ctx context.Context, req *UpdateNickNameRequest) error {
 	uuid := ctx.Value(static.ReqUuid)
 	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
 	log.Infof("%s|UpdateUserNickName access from,user_name=%s|session=%s", uuid, req.UserName, session)
 	log.Infof("UpdateUserNickName|req==%v", req)
 
@@ -189,19 +353,148 @@ func UpdateUserNickName(ctx context.Context, req *UpdateNickNameRequest) error {
 		return fmt.Errorf("UpdateUserNickName|request params invalid")
 	}
 
-	user, err := cache.GetSessionInfo(session)
+	user, err := cache.GetSessionInfo(tenantID, session)
 	if err != nil {
 		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
 		return fmt.Errorf("UpdateUserNickName|GetSessionInfo err:%v", err)
 	}
 
-	if user.Name != req.UserName {
+	if err := checkSessionUser(user, req.UserName); err != nil {
 		log.Errorf("UpdateUserNickName|%s|session info not match with username=%s", uuid, req.UserName)
+		return fmt.Errorf("UpdateUserNickName|%v", err)
 	}
 
 	updateUser := &model.User{
 		NickName: req.NewNickName,
 	}
 
-	return updateUserInfo(updateUser, req.UserName, session)
+	changed := map[string]interface{}{"nickname": req.NewNickName}
+	if err := updateUserInfo(tenantID, updateUser, req.UserName, session, changed); err != nil {
+		return err
+	}
+	writeAuditDiff(ctx, user.Name, req.UserName, model.AuditUserUpdate, "",
+		map[string]interface{}{"nickname": user.NickName}, changed)
+	return nil
+}
+
+// ChangePassword 修改密码，校验旧密码后生效，修改后该用户的全部会话失效。
+// 旧密码校验与登录共用失败次数和人机校验，不能借修改密码接口绕过登录的防爆破
+func ChangePassword(ctx context.Context, req *ChangePasswordRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	tenantID := tenantFromCtx(ctx)
+	log.Infof("%s|ChangePassword access from,user_name=%s", uuid, req.UserName)
+
+	if req.UserName == "" || req.PassWord == "" || req.NewPassWord == "" {
+		return fmt.Errorf("ChangePassword|request params invalid")
+	}
+	if session, _ := ctx.Value(static.SessionKey).(string); isImpersonating(tenantID, session) {
+		return fmt.Errorf("模拟登录中，不允许修改密码")
+	}
+	if loginChallengeRequired(ctx, req.UserName) {
+		if err := verifyChallenge(req.ChallengeID, req.ChallengeAnswer); err != nil {
+			log.Warnf("%s|ChangePassword|challenge failed, user_name=%s|err=%v", uuid, req.UserName, err)
+			return err
+		}
+	}
+	user, err := dao.GetUserByName(tenantID, req.UserName)
+	if err != nil {
+		return fmt.Errorf("ChangePassword|%v", err)
+	}
+	if user == nil {
+		recordLoginFailure(ctx, req.UserName)
+		return fmt.Errorf("用户尚未注册")
+	}
+	if !utils.CheckPassword(user.PassWord, req.PassWord) {
+		log.Errorf("%s|ChangePassword|password err, user_name=%s", uuid, req.UserName)
+		recordLoginFailure(ctx, req.UserName)
+		return fmt.Errorf("password is not correct")
+	}
+	resetLoginFailure(ctx, req.UserName)
+	if user.Status == model.UserStatusDisabled {
+		return fmt.Errorf("账号已被禁用")
+	}
+	// 被管理员要求重置密码的用户也通过这里设置新密码，同样需要满足密码策略
+	if err := checkPasswordPolicy(req.NewPassWord, user.Name, user.NickName); err != nil {
+		log.Warnf("%s|ChangePassword|password rejected by policy, user_name=%s|err=%v", uuid, req.UserName, err)
+		return err
+	}
+	if err := checkPasswordReuse(user, req.NewPassWord); err != nil {
+		log.Warnf("%s|ChangePassword|password reused, user_name=%s|err=%v", uuid, req.UserName, err)
+		return err
+	}
+	hash, err := utils.HashPassword(req.NewPassWord)
+	if err != nil {
+		return fmt.Errorf("ChangePassword|hash password err:%v", err)
+	}
+
+	fields := map[string]interface{}{
+		"password":           hash,
+		"pwd_reset_required": false,
+		"modifier":           req.UserName,
+	}
+	_, err = dao.UpdateUserFields(tenantID, req.UserName, fields, userUpdatedEvent(tenantID, req.UserName, fields))
+	if err != nil {
+		return fmt.Errorf("ChangePassword|%v", err)
+	}
+	recordPasswordHistory(user, hash)
+	cache.InvalidateUserInfo(tenantID, req.UserName)
+	detail := ""
+	if user.PwdResetRequired {
+		detail = "reset_required"
+	}
+	writeAudit(ctx, user.Name, user.Name, model.AuditUserPassword, detail)
+	if err := revokeUserSessions(ctx, user.Name, tenantID, req.UserName, "password_changed"); err != nil {
+		log.Errorf("%s|ChangePassword|del sessions failed for user:%s with err:%v", uuid, req.UserName, err)
+	}
+	log.Infof("%s|ChangePassword success, user_name=%s", uuid, req.UserName)
+	return nil
+}
+
+// DeleteAccount 注销账号，需在登录态下校验密码，模拟登录时不允许
+func DeleteAccount(ctx context.Context, req *DeleteAccountRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	session := ctx.Value(static.SessionKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	log.Infof("%s|DeleteAccount access from,user_name=%s|session=%s", uuid, req.UserName, session)
+
+	if session == "" || req.UserName == "" || req.PassWord == "" {
+		return fmt.Errorf("DeleteAccount|request params invalid")
+	}
+	if isImpersonating(tenantID, session) {
+		return fmt.Errorf("模拟登录中，不允许注销账号")
+	}
+
+	sessionUser, err := cache.GetSessionInfo(tenantID, session)
+	if err != nil {
+		log.Errorf("%s|Failed to get with session=%s|err =%v", uuid, session, err)
+		return fmt.Errorf("DeleteAccount|GetSessionInfo err:%v", err)
+	}
+	if err := checkSessionUser(sessionUser, req.UserName); err != nil {
+		log.Errorf("DeleteAccount|%s|session info not match with username=%s", uuid, req.UserName)
+		return fmt.Errorf("DeleteAccount|%v", err)
+	}
+
+	user, err := dao.GetUserByName(tenantID, req.UserName)
+	if err != nil {
+		return fmt.Errorf("DeleteAccount|%v", err)
+	}
+	if user == nil {
+		return fmt.Errorf("用户尚未注册")
+	}
+	if !utils.CheckPassword(user.PassWord, req.PassWord) {
+		log.Errorf("%s|DeleteAccount|password err, user_name=%s", uuid, req.UserName)
+		return fmt.Errorf("password is not correct")
+	}
+
+	if err := dao.DeleteUser(user, userDeletedEvent(user)); err != nil {
+		return fmt.Errorf("DeleteAccount|%v", err)
+	}
+	if err := revokeUserSessions(ctx, user.Name, tenantID, user.Name, "account_deleted"); err != nil {
+		log.Errorf("%s|DeleteAccount|del sessions failed for user:%s with err:%v", uuid, user.Name, err)
+	}
+	cache.InvalidateUserInfo(tenantID, user.Name)
+	cache.DelPermissionCache(tenantID, user.Name)
+	writeAudit(ctx, user.Name, user.Name, model.AuditUserDelete, "")
+	log.Infof("%s|DeleteAccount success, user_name=%s", uuid, user.Name)
+	return nil
 }
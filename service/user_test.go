@@ -0,0 +1,20 @@
+package service
+
+import (
+	"my_user_system/model"
+	"testing"
+)
+
+func TestCheckSessionUser(t *testing.T) {
+	sessionUser := &model.User{ID: 2, Name: "alice"}
+	if err := checkSessionUser(sessionUser, "alice"); err != nil {
+		t.Errorf("checkSessionUser with own user_name = %v", err)
+	}
+	// 借自己的会话修改 admin 必须被拒绝
+	if err := checkSessionUser(sessionUser, "admin"); err == nil {
+		t.Errorf("checkSessionUser with mismatched user_name = nil, want error")
+	}
+	if err := checkSessionUser(nil, "alice"); err == nil {
+		t.Errorf("checkSessionUser without session user = nil, want error")
+	}
+}
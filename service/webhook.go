@@ -0,0 +1,656 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"my_user_system/conf"
+	"my_user_system/dao"
+	"my_user_system/model"
+	"my_user_system/static"
+	"my_user_system/utils"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout      = 5000
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookPollInterval = 1000
+	defaultWebhookBatchSize    = 100
+	// webhookBaseBackoff 第一次回调失败后的重试间隔，之后翻倍
+	webhookBaseBackoff = 10 * time.Second
+	// webhookMaxBackoff 回调失败后重试间隔的上限
+	webhookMaxBackoff = time.Hour
+	// webhookLeaseMargin 抢到的记录在回调超时之后还要保留的时间
+	webhookLeaseMargin = 30 * time.Second
+	// webhookSecretBytes 自动生成的签名密钥的字节数
+	webhookSecretBytes  = 24
+	minWebhookSecretLen = 16
+	maxWebhookSecretLen = 128
+	maxWebhookURLLen    = 512
+	maxWebhookEventsLen = 512
+	// maxWebhookResponseLen 投递日志中保存的响应内容和错误信息的长度
+	maxWebhookResponseLen = 512
+	webhookUserAgent      = "my_user_system-webhook/1.0"
+)
+
+// 回调请求头
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"     // 事件类型
+	WebhookHeaderEventID   = "X-Webhook-Event-Id"  // 事件唯一标识，接收方据此去重
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"  // 投递记录ID，重新投递时不变
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp" // 签名时的秒级时间戳
+	WebhookHeaderSignature = "X-Webhook-Signature" // v1=hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+var (
+	// ErrWebhookSignature 回调签名无效或时间戳超出允许范围
+	ErrWebhookSignature = errors.New("webhook signature invalid")
+	// errWebhookInactive 订阅已删除或已停用，投递记录直接进入死信状态
+	errWebhookInactive = errors.New("订阅已删除或已停用")
+)
+
+// webhookEventTypes 可以订阅的事件类型
+var webhookEventTypes = []string{
+	model.EventUserRegistered,
+	model.EventUserUpdated,
+	model.EventUserDeleted,
+	model.EventSessionCreated,
+	model.EventSessionRevoked,
+}
+
+// webhookClient 回调使用的 HTTP 客户端，InitWebhookDispatcher 时按配置创建
+var webhookClient = newWebhookClient(defaultWebhookTimeout*time.Millisecond, nil)
+
+// errWebhookAddrBlocked 回调地址解析到内网、本机或链路本地地址
+var errWebhookAddrBlocked = errors.New("webhook address not allowed")
+
+// newWebhookClient 重定向视为失败，避免回调被转发到订阅之外的地址。
+// 在建立连接时按 DNS 解析后的地址拒绝内网、本机和链路本地地址，域名解析到内网或在两次解析之间变化都无法绕过；
+// 不使用代理，否则只能校验到代理的地址。allowed 中的网段不受限制，用于本地测试
+func newWebhookClient(timeout time.Duration, allowed []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip, allowed) {
+				return fmt.Errorf("%w: %s", errWebhookAddrBlocked, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookIPAllowed 判断回调能否连接该地址，只允许公网地址和配置中放行的网段
+func webhookIPAllowed(ip net.IP, allowed []*net.IPNet) bool {
+	for _, ipNet := range allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// webhookAllowedNetworks 解析配置中允许回调的内网地址，支持 IP 和 CIDR，无效的配置跳过
+func webhookAllowedNetworks() []*net.IPNet {
+	entries := conf.GetGlobalConfig().Webhook.AllowedNetworks
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Errorf("webhook allowed network %s invalid, skip, err:%v", entry, err)
+			continue
+		}
+		networks = append(networks, ipNet)
+	}
+	return networks
+}
+
+// SignWebhook 计算回调签名，签名内容带上时间戳，接收方据此拒绝重放的请求
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature 供接收方校验回调签名，timestamp 与 signature 为请求头中的值，
+// 时间戳与当前时间相差超过 tolerance 时视为重放
+func VerifyWebhookSignature(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrWebhookSignature
+	}
+	if !hmac.Equal([]byte(SignWebhook(secret, ts, body)), []byte(signature)) {
+		return ErrWebhookSignature
+	}
+	return nil
+}
+
+// matchWebhookEvent 判断事件是否匹配订阅中的一项，支持 *、具体的事件类型和 user.* 形式的前缀
+func matchWebhookEvent(filter, eventType string) bool {
+	if filter == "*" || filter == eventType {
+		return true
+	}
+	return strings.HasSuffix(filter, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(filter, "*"))
+}
+
+// webhookSubscribed 判断订阅是否包含该事件，events 为逗号分隔的内容
+func webhookSubscribed(events, eventType string) bool {
+	for _, filter := range strings.Split(events, ",") {
+		if matchWebhookEvent(filter, eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebhookEvents 校验并去重订阅的事件，每一项至少要匹配一种事件类型，返回逗号分隔的内容
+func normalizeWebhookEvents(events []string) (string, error) {
+	seen := make(map[string]bool, len(events))
+	filters := make([]string, 0, len(events))
+	for _, filter := range events {
+		filter = strings.TrimSpace(filter)
+		if seen[filter] {
+			continue
+		}
+		matched := false
+		for _, eventType := range webhookEventTypes {
+			if matchWebhookEvent(filter, eventType) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", fmt.Errorf("不支持订阅事件 %q", filter)
+		}
+		seen[filter] = true
+		filters = append(filters, filter)
+	}
+	if len(filters) == 0 {
+		return "", fmt.Errorf("至少需要订阅一个事件")
+	}
+	joined := strings.Join(filters, ",")
+	if len(joined) > maxWebhookEventsLen {
+		return "", fmt.Errorf("订阅的事件过多")
+	}
+	return joined, nil
+}
+
+// checkWebhookURL 回调地址只支持 http 和 https
+func checkWebhookURL(raw string) error {
+	if raw == "" || len(raw) > maxWebhookURLLen {
+		return fmt.Errorf("回调地址无效")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("回调地址无效，只支持 http 和 https")
+	}
+	return nil
+}
+
+// newWebhookSecret 生成签名密钥
+func newWebhookSecret() (string, error) {
+	token, err := utils.RandomToken(webhookSecretBytes)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+func webhookTimeout() time.Duration {
+	timeout := conf.GetGlobalConfig().Webhook.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+func webhookMaxAttempts() int {
+	if attempts := conf.GetGlobalConfig().Webhook.MaxAttempts; attempts > 0 {
+		return attempts
+	}
+	return defaultWebhookMaxAttempts
+}
+
+// webhookBackoff 第 attempts 次回调失败后的重试间隔
+func webhookBackoff(attempts int) time.Duration {
+	return exponentialBackoff(attempts, webhookBaseBackoff, webhookMaxBackoff)
+}
+
+// truncateText 截断保存到投递日志中的内容，并去掉截断产生的非法 UTF-8
+func truncateText(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.ToValidUTF8(s, "")
+}
+
+// enqueueWebhookDeliveries 事件投递给下游后按租户的订阅生成投递记录。
+// 失败时事件会连同下游一起重试，同一事件对同一订阅已生成的记录不会重复写入
+func enqueueWebhookDeliveries(event *Event) error {
+	if !conf.GetGlobalConfig().Webhook.Enabled {
+		return nil
+	}
+	subs, err := dao.ListWebhookSubscriptions(event.TenantID, true)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	now := time.Now()
+	deliveries := make([]*model.WebhookDelivery, 0, len(subs))
+	for _, sub := range subs {
+		if !webhookSubscribed(sub.Events, event.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			TenantID:        event.TenantID,
+			SubscriptionID:  sub.ID,
+			EventID:         event.ID,
+			EventType:       event.Type,
+			Payload:         string(body),
+			Status:          model.WebhookStatusPending,
+			NextAttemptTime: now,
+		})
+	}
+	return dao.CreateWebhookDeliveries(deliveries)
+}
+
+// InitWebhookDispatcher 启动 Webhook 回调任务，未开启时不启动
+func InitWebhookDispatcher() {
+	webhookConf := conf.GetGlobalConfig().Webhook
+	if !webhookConf.Enabled {
+		return
+	}
+	interval := webhookConf.PollInterval
+	if interval <= 0 {
+		interval = defaultWebhookPollInterval
+	}
+	webhookClient = newWebhookClient(webhookTimeout(), webhookAllowedNetworks())
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			dispatchWebhooks()
+		}
+	}()
+	log.Infof("InitWebhookDispatcher success, timeout=%v|max_attempts=%d|poll_interval=%dms",
+		webhookTimeout(), webhookMaxAttempts(), interval)
+}
+
+// dispatchWebhooks 投递一批到期的记录。不同订阅并发投递，一个接收方响应慢不影响其他订阅
+func dispatchWebhooks() {
+	batch := conf.GetGlobalConfig().Webhook.BatchSize
+	if batch <= 0 {
+		batch = defaultWebhookBatchSize
+	}
+	now := time.Now()
+	deliveries, err := dao.ListPendingWebhookDeliveries(now, batch)
+	if err != nil {
+		log.Errorf("dispatchWebhooks|list pending err:%v", err)
+		return
+	}
+	groups := make(map[int][]*model.WebhookDelivery)
+	for _, delivery := range deliveries {
+		groups[delivery.SubscriptionID] = append(groups[delivery.SubscriptionID], delivery)
+	}
+
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []*model.WebhookDelivery) {
+			defer wg.Done()
+			sub, err := dao.GetWebhookSubscription(group[0].TenantID, group[0].SubscriptionID)
+			if err != nil {
+				return
+			}
+			for _, delivery := range group {
+				claimed, err := dao.ClaimWebhookDelivery(delivery.ID, now, time.Now().Add(webhookTimeout()+webhookLeaseMargin))
+				if err != nil {
+					return
+				}
+				if claimed {
+					attemptWebhook(sub, delivery)
+				}
+			}
+		}(group)
+	}
+	wg.Wait()
+}
+
+// attemptWebhook 投递一次并记录结果。2xx 视为成功，失败按指数退避重试，次数用完后进入死信状态
+func attemptWebhook(sub *model.WebhookSubscription, delivery *model.WebhookDelivery) {
+	attempts := delivery.Attempts + 1
+	code, body, err := 0, "", errWebhookInactive
+	if sub != nil && sub.Enabled {
+		code, body, err = postWebhook(webhookClient, sub, delivery)
+	}
+	fields := map[string]interface{}{
+		"attempts":      attempts,
+		"response_code": code,
+		"response_body": truncateText(body, maxWebhookResponseLen),
+	}
+	switch {
+	case err == nil:
+		fields["status"] = model.WebhookStatusSucceeded
+		fields["delivered_time"] = time.Now()
+		fields["last_error"] = ""
+	case err == errWebhookInactive || attempts >= webhookMaxAttempts():
+		log.Warnf("attemptWebhook|delivery dead, id=%d|event_id=%s|attempts=%d|err=%v", delivery.ID, delivery.EventID, attempts, err)
+		fields["status"] = model.WebhookStatusDead
+		fields["last_error"] = truncateText(err.Error(), maxWebhookResponseLen)
+	default:
+		log.Warnf("attemptWebhook|delivery failed, id=%d|event_id=%s|attempts=%d|err=%v", delivery.ID, delivery.EventID, attempts, err)
+		fields["next_attempt_time"] = time.Now().Add(webhookBackoff(attempts))
+		fields["last_error"] = truncateText(err.Error(), maxWebhookResponseLen)
+	}
+	dao.SaveWebhookAttempt(delivery.ID, fields)
+}
+
+// postWebhook 发送一次签名后的回调，返回响应的状态码和响应内容，非 2xx 的响应也返回错误
+func postWebhook(client *http.Client, sub *model.WebhookSubscription, delivery *model.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhook(sub.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseLen))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, string(b), fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(b), nil
+}
+
+// webhookTarget 审计日志中 Webhook 订阅的操作对象
+func webhookTarget(id int) string {
+	return "webhook:" + strconv.Itoa(id)
+}
+
+// webhookAuditFields 参与审计的订阅字段，签名密钥不记录
+func webhookAuditFields(sub *model.WebhookSubscription) map[string]interface{} {
+	return map[string]interface{}{
+		"url":         sub.URL,
+		"events":      sub.Events,
+		"enabled":     sub.Enabled,
+		"description": sub.Description,
+	}
+}
+
+func toWebhookInfo(sub *model.WebhookSubscription) *WebhookInfo {
+	return &WebhookInfo{
+		ID:          sub.ID,
+		URL:         sub.URL,
+		Events:      strings.Split(sub.Events, ","),
+		Enabled:     sub.Enabled,
+		Description: sub.Description,
+		Creator:     sub.Creator,
+		CreateTime:  sub.CreateTime.Unix(),
+	}
+}
+
+// CreateWebhook 创建 Webhook 订阅，返回中包含签名密钥，之后不再返回
+func CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*WebhookInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	if err := checkWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("CreateWebhook|%v", err)
+		}
+	}
+	if len(secret) < minWebhookSecretLen || len(secret) > maxWebhookSecretLen {
+		return nil, fmt.Errorf("签名密钥长度需要在%d到%d位之间", minWebhookSecretLen, maxWebhookSecretLen)
+	}
+
+	sub := &model.WebhookSubscription{
+		TenantID:    tenantFromCtx(ctx),
+		URL:         req.URL,
+		Events:      events,
+		Secret:      secret,
+		Enabled:     true,
+		Description: req.Description,
+		CreateModel: model.CreateModel{Creator: operator},
+		ModifyModel: model.ModifyModel{Modifier: operator},
+	}
+	if err := dao.CreateWebhookSubscription(sub); err != nil {
+		return nil, fmt.Errorf("CreateWebhook|%v", err)
+	}
+	writeAuditDiff(ctx, operator, webhookTarget(sub.ID), model.AuditWebhookCreate, "", nil, webhookAuditFields(sub))
+	log.Infof("%s|CreateWebhook success, id=%d|url=%s|events=%s|operator=%s", uuid, sub.ID, sub.URL, sub.Events, operator)
+	info := toWebhookInfo(sub)
+	info.Secret = secret
+	return info, nil
+}
+
+// ListWebhooks 列出当前租户的 Webhook 订阅
+func ListWebhooks(ctx context.Context) ([]*WebhookInfo, error) {
+	subs, err := dao.ListWebhookSubscriptions(tenantFromCtx(ctx), false)
+	if err != nil {
+		return nil, fmt.Errorf("ListWebhooks|%v", err)
+	}
+	infos := make([]*WebhookInfo, 0, len(subs))
+	for _, sub := range subs {
+		infos = append(infos, toWebhookInfo(sub))
+	}
+	return infos, nil
+}
+
+// UpdateWebhook 修改 Webhook 订阅，重新生成密钥时返回新的密钥
+func UpdateWebhook(ctx context.Context, req *UpdateWebhookRequest) (*WebhookInfo, error) {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	sub, err := dao.GetWebhookSubscription(tenantID, req.ID)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateWebhook|%v", err)
+	}
+	if sub == nil {
+		return nil, fmt.Errorf("Webhook 订阅不存在")
+	}
+
+	before := webhookAuditFields(sub)
+	fields := map[string]interface{}{"modifier": operator}
+	if req.URL != "" {
+		if err := checkWebhookURL(req.URL); err != nil {
+			return nil, err
+		}
+		sub.URL = req.URL
+		fields["url"] = req.URL
+	}
+	if len(req.Events) > 0 {
+		events, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		sub.Events = events
+		fields["events"] = events
+	}
+	if req.Description != "" {
+		sub.Description = req.Description
+		fields["description"] = req.Description
+	}
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+		fields["enabled"] = *req.Enabled
+	}
+	if req.RotateSecret {
+		if sub.Secret, err = newWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("UpdateWebhook|%v", err)
+		}
+		fields["secret"] = sub.Secret
+	}
+	if _, err := dao.UpdateWebhookSubscription(tenantID, sub.ID, fields); err != nil {
+		return nil, fmt.Errorf("UpdateWebhook|%v", err)
+	}
+
+	detail := ""
+	if req.RotateSecret {
+		detail = "secret rotated"
+	}
+	writeAuditDiff(ctx, operator, webhookTarget(sub.ID), model.AuditWebhookUpdate, detail, before, webhookAuditFields(sub))
+	log.Infof("%s|UpdateWebhook success, id=%d|rotate_secret=%v|operator=%s", uuid, sub.ID, req.RotateSecret, operator)
+	info := toWebhookInfo(sub)
+	if req.RotateSecret {
+		info.Secret = sub.Secret
+	}
+	return info, nil
+}
+
+// DeleteWebhook 删除 Webhook 订阅，还未投递成功的记录一并删除，投递日志保留
+func DeleteWebhook(ctx context.Context, req *DeleteWebhookRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	n, err := dao.DeleteWebhookSubscription(tenantFromCtx(ctx), req.ID)
+	if err != nil {
+		return fmt.Errorf("DeleteWebhook|%v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("Webhook 订阅不存在")
+	}
+	writeAudit(ctx, operator, webhookTarget(req.ID), model.AuditWebhookDelete, "")
+	log.Infof("%s|DeleteWebhook success, id=%d|operator=%s", uuid, req.ID, operator)
+	return nil
+}
+
+func toWebhookDeliveryInfo(delivery *model.WebhookDelivery) *WebhookDeliveryInfo {
+	info := &WebhookDeliveryInfo{
+		ID:              delivery.ID,
+		SubscriptionID:  delivery.SubscriptionID,
+		EventID:         delivery.EventID,
+		EventType:       delivery.EventType,
+		Status:          delivery.Status,
+		Attempts:        delivery.Attempts,
+		ResponseCode:    delivery.ResponseCode,
+		ResponseBody:    delivery.ResponseBody,
+		LastError:       delivery.LastError,
+		NextAttemptTime: delivery.NextAttemptTime.Unix(),
+		CreateTime:      delivery.CreateTime.Unix(),
+		ModifyTime:      delivery.ModifyTime.Unix(),
+	}
+	if delivery.DeliveredTime != nil {
+		info.DeliveredTime = delivery.DeliveredTime.Unix()
+	}
+	return info
+}
+
+// ListWebhookDeliveries 按条件查询当前租户的投递日志，最新的在前
+func ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	filter := &dao.WebhookDeliveryFilter{
+		TenantID:       tenantFromCtx(ctx),
+		SubscriptionID: req.SubscriptionID,
+		Status:         req.Status,
+		EventID:        req.EventID,
+		Cursor:         req.Cursor,
+		Limit:          req.Limit,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	deliveries, err := dao.ListWebhookDeliveries(filter)
+	if err != nil {
+		return nil, fmt.Errorf("ListWebhookDeliveries|%v", err)
+	}
+	rsp := &ListWebhookDeliveriesResponse{Deliveries: make([]*WebhookDeliveryInfo, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		rsp.Deliveries = append(rsp.Deliveries, toWebhookDeliveryInfo(delivery))
+	}
+	if len(deliveries) == filter.Limit {
+		rsp.NextCursor = deliveries[len(deliveries)-1].ID
+	}
+	return rsp, nil
+}
+
+// RedeliverWebhook 手动重新投递，清零重试次数后由后台任务立即投递，请求体与第一次投递相同
+func RedeliverWebhook(ctx context.Context, req *RedeliverWebhookRequest) error {
+	uuid := ctx.Value(static.ReqUuid)
+	operator := ctx.Value(static.OperatorKey).(string)
+	tenantID := tenantFromCtx(ctx)
+	delivery, err := dao.GetWebhookDelivery(tenantID, req.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("RedeliverWebhook|%v", err)
+	}
+	if delivery == nil {
+		return fmt.Errorf("投递记录不存在")
+	}
+	sub, err := dao.GetWebhookSubscription(tenantID, delivery.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("RedeliverWebhook|%v", err)
+	}
+	if sub == nil || !sub.Enabled {
+		return errWebhookInactive
+	}
+	if _, err := dao.ResetWebhookDelivery(tenantID, delivery.ID, time.Now()); err != nil {
+		return fmt.Errorf("RedeliverWebhook|%v", err)
+	}
+	writeAudit(ctx, operator, webhookTarget(sub.ID), model.AuditWebhookRedeliver,
+		fmt.Sprintf("delivery_id=%d event_id=%s", delivery.ID, delivery.EventID))
+	log.Infof("%s|RedeliverWebhook success, delivery_id=%d|operator=%s", uuid, delivery.ID, operator)
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"my_user_system/model"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostWebhook(t *testing.T) {
+	const secret = "whsec_test_secret_0123456789"
+	var received http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = r.Header.Clone()
+		err := VerifyWebhookSignature(secret, r.Header.Get(WebhookHeaderTimestamp), r.Header.Get(WebhookHeaderSignature), body, time.Minute)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	sub := &model.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: secret, Enabled: true}
+	delivery := &model.WebhookDelivery{ID: 7, EventID: "evt1", EventType: model.EventUserRegistered, Payload: `{"event_id":"evt1"}`}
+	code, body, err := postWebhook(receiver.Client(), sub, delivery)
+	if err != nil || code != http.StatusOK || body != "ok" {
+		t.Fatalf("postWebhook = %d %q %v, want 200 ok", code, body, err)
+	}
+	if received.Get(WebhookHeaderEventID) != "evt1" || received.Get(WebhookHeaderDelivery) != "7" ||
+		received.Get(WebhookHeaderEvent) != model.EventUserRegistered {
+		t.Errorf("unexpected headers %v", received)
+	}
+
+	sub.Secret = "whsec_another_secret_987654"
+	code, _, err = postWebhook(receiver.Client(), sub, delivery)
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("postWebhook with wrong secret = %d %v, want 401 and error", code, err)
+	}
+}
+
+func TestWebhookClientBlocksPrivateAddress(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	sub := &model.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "whsec_test_secret_0123456789", Enabled: true}
+	delivery := &model.WebhookDelivery{ID: 7, EventID: "evt1", EventType: model.EventUserRegistered, Payload: `{}`}
+	// 本机地址默认拒绝，域名解析到本机同样拒绝
+	if _, _, err := postWebhook(newWebhookClient(time.Second, nil), sub, delivery); !errors.Is(err, errWebhookAddrBlocked) {
+		t.Errorf("postWebhook to loopback err = %v, want errWebhookAddrBlocked", err)
+	}
+	sub.URL = strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	if _, _, err := postWebhook(newWebhookClient(time.Second, nil), sub, delivery); !errors.Is(err, errWebhookAddrBlocked) {
+		t.Errorf("postWebhook to localhost err = %v, want errWebhookAddrBlocked", err)
+	}
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	sub.URL = receiver.URL
+	if code, _, err := postWebhook(newWebhookClient(time.Second, []*net.IPNet{loopback}), sub, delivery); err != nil || code != http.StatusOK {
+		t.Errorf("postWebhook to allowed network = %d %v, want 200", code, err)
+	}
+	for _, ip := range []string{"10.1.2.3", "169.254.169.254", "::1", "fe80::1", "0.0.0.0"} {
+		if webhookIPAllowed(net.ParseIP(ip), nil) {
+			t.Errorf("webhookIPAllowed(%s) = true, want false", ip)
+		}
+	}
+	if !webhookIPAllowed(net.ParseIP("93.184.216.34"), nil) {
+		t.Errorf("webhookIPAllowed(public) = false, want true")
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	old := time.Now().Add(-10 * time.Minute).Unix()
+	if err := VerifyWebhookSignature("secret", "1", SignWebhook("secret", old, body), body, time.Minute); err != ErrWebhookSignature {
+		t.Errorf("mismatched timestamp should be rejected")
+	}
+	ts := strconv.FormatInt(old, 10)
+	if err := VerifyWebhookSignature("secret", ts, SignWebhook("secret", old, body), body, time.Minute); err != ErrWebhookSignature {
+		t.Errorf("expired timestamp should be rejected")
+	}
+	if err := VerifyWebhookSignature("secret", ts, SignWebhook("secret", old, body), body, time.Hour); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	events, err := normalizeWebhookEvents([]string{"user.*", " session.created ", "user.*"})
+	if err != nil || events != "user.*,session.created" {
+		t.Fatalf("normalizeWebhookEvents = %q %v", events, err)
+	}
+	if !webhookSubscribed(events, model.EventUserDeleted) || webhookSubscribed(events, model.EventSessionRevoked) {
+		t.Errorf("webhookSubscribed mismatch for %q", events)
+	}
+	for _, invalid := range [][]string{nil, {"users.*"}, {"user.login"}, {""}} {
+		if _, err := normalizeWebhookEvents(invalid); err == nil {
+			t.Errorf("normalizeWebhookEvents(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: 10 * time.Second, 3: 40 * time.Second, 9: 2560 * time.Second, 10: webhookMaxBackoff, 100: webhookMaxBackoff}
+	for attempts, want := range cases {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
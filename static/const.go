@@ -5,14 +5,72 @@ const (
 	ReqUuid          = "uuid"
 	UserInfoPrefix   = "userinfo"
 	SessionKeyPrefix = "session"
+	PermissionPrefix = "permission"
+	// UserSessionsPrefix 用户会话索引，记录某个用户名下的全部会话
+	UserSessionsPrefix = "usersessions"
+	// ImpersonatePrefix 模拟登录会话的附加信息，记录发起模拟的管理员
+	ImpersonatePrefix = "impersonate"
+	// RateLimitPrefix 限流计数
+	RateLimitPrefix = "ratelimit"
+	// ChallengePrefix 人机校验挑战的答案
+	ChallengePrefix = "challenge"
+	// LoginFailPrefix 登录失败次数
+	LoginFailPrefix = "loginfail"
+	// OAuthCodePrefix OAuth 授权码，使用一次后删除
+	OAuthCodePrefix = "oauthcode"
+	// OAuthTokenPrefix OAuth 访问令牌
+	OAuthTokenPrefix = "oauthtoken"
+	// OAuthGrantPrefix 同一次授权签发的访问令牌索引，吊销授权时据此删除访问令牌
+	OAuthGrantPrefix = "oauthgrant"
 )
 const (
 	GenderMale   = "male"
 	GenderFeMale = "female"
 )
 const (
-	// SessionKey 是用于存储用户会话标识符的 Cookie 键名
+	// SessionKey 是用于存储用户会话标识符的 Cookie 键名，Cookie 的有效期与会话一致，见 conf.Cache.SessionExpired
 	SessionKey = "user_session"
-	// CookieExpire 是用户会话标识符的过期时间，单位为秒
-	CookieExpire = 3600
+)
+const (
+	// PrincipalKey 是鉴权中间件在 gin.Context 中存放当前登录用户的键名
+	PrincipalKey = "principal"
+	// OperatorKey 是在上下文中存放操作人用户名的键名
+	OperatorKey = "operator"
+	// ClientIPKey 是在上下文中存放请求来源IP的键名
+	ClientIPKey = "client_ip"
+	// TenantKey 是在 gin.Context 和上下文中存放当前租户ID的键名
+	TenantKey = "tenant_id"
+	// UserAgentKey 是在上下文中存放请求 User-Agent 的键名
+	UserAgentKey = "user_agent"
+)
+const (
+	// RoleAdmin 内置管理员角色，启动时自动拥有全部内置权限
+	RoleAdmin = "admin"
+
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+	PermRolesRead  = "roles:read"
+	PermRolesWrite = "roles:write"
+
+	PermUsersImpersonate = "users:impersonate"
+	PermOrgsRead         = "orgs:read"
+	PermOrgsWrite        = "orgs:write"
+	PermInvitesRead      = "invites:read"
+	PermInvitesWrite     = "invites:write"
+	PermAuditRead        = "audit:read"
+	PermWebhooksRead     = "webhooks:read"
+	PermWebhooksWrite    = "webhooks:write"
+	PermOAuthRead        = "oauth:read"
+	PermOAuthWrite       = "oauth:write"
+)
+const (
+	// 人机校验方式
+	ChallengeCaptcha = "captcha" // 图片算术验证码
+	ChallengePow     = "pow"     // 工作量证明
+)
+const (
+	// 注册模式
+	RegisterModeOpen   = "open"        // 开放注册
+	RegisterModeInvite = "invite_only" // 凭邀请码注册
+	RegisterModeClosed = "closed"      // 关闭注册
 )
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// HashPassword 使用 bcrypt 计算密码哈希，bcrypt 只使用前 72 个字节，调用方需先限制密码长度
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHashed 判断库中保存的是否为哈希，早期的账号保存的是明文
+func IsPasswordHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword 校验密码，兼容明文保存的旧账号
+func CheckPassword(stored, password string) bool {
+	if stored == "" || password == "" {
+		return false
+	}
+	if !IsPasswordHashed(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+}
@@ -1,48 +1,162 @@
 package utils
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/context"
 	"my_user_system/conf"
+	"os"
 	"sync"
+	"time"
+)
+
+// Redis 部署模式
+const (
+	RedisModeSingle   = "single"   // 单节点
+	RedisModeSentinel = "sentinel" // 哨兵
+	RedisModeCluster  = "cluster"  // 集群
+)
+
+const (
+	defaultRedisStartupRetries = 5                // 未配置时启动阶段连接 Redis 的最大重试次数
+	redisStartupBackoff        = time.Second      // 启动阶段首次重试的等待时间，之后每次翻倍
+	redisStartupMaxBackoff     = 30 * time.Second // 启动阶段单次重试的最长等待时间
 )
 
 var (
 	//对于redisConn变量，可能会在代码的其他部分中使用它来执行与Redis相关的操作。
 	//而redisOnce变量可能会在初始化Redis连接的函数中使用，以确保初始化只发生一次。
-	redisConn *redis.Client
+	redisConn redis.UniversalClient
 	// 这里不能用引用类型
 	redisOnce sync.Once
 )
 
-// openDB 连接db
+// initRedis 按配置的部署模式创建 Redis 客户端
 func initRedis() {
 	redisConfig := conf.GetGlobalConfig().RedisConfig
-	log.Infof("redisConfig=======%+v", redisConfig)
-	addr := fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port)
-	redisConn = redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: redisConfig.PassWord,
-		DB:       redisConfig.DB,
-		PoolSize: redisConfig.PoolSize,
-	})
-	if redisConn == nil {
-		panic("failed to call redis.NewClient")
-	}
-	res, err := redisConn.Set(context.Background(), "abc", 100, 60).Result()
-	log.Infof("res=======%v,err======%v", res, err)
-	_, err = redisConn.Ping(context.Background()).Result()
+	log.Infof("redis mode=%s|addrs=%v|master=%s", redisMode(redisConfig), redisAddrs(redisConfig), redisConfig.MasterName)
+	opts, err := redisOptions(redisConfig)
 	if err != nil {
-		panic("Failed to ping redis, err:%s")
+		panic("redis conf err:" + err.Error())
+	}
+	switch redisMode(redisConfig) {
+	case RedisModeSentinel:
+		redisConn = redis.NewFailoverClient(opts.Failover())
+	case RedisModeCluster:
+		redisConn = redis.NewClusterClient(opts.Cluster())
+	default:
+		redisConn = redis.NewClient(opts.Simple())
+	}
+	waitForRedis(redisConfig.StartupRetries)
+}
+
+// redisMode 部署模式，未配置时为单节点
+func redisMode(redisConfig conf.RedisConf) string {
+	if redisConfig.Mode == "" {
+		return RedisModeSingle
+	}
+	return redisConfig.Mode
+}
+
+// redisAddrs 节点地址，单节点模式下兼容原有的 rhost/rport 配置
+func redisAddrs(redisConfig conf.RedisConf) []string {
+	if len(redisConfig.Addrs) > 0 {
+		return redisConfig.Addrs
+	}
+	return []string{fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port)}
+}
+
+// redisOptions 把配置转换为 go-redis 的通用参数
+func redisOptions(redisConfig conf.RedisConf) (*redis.UniversalOptions, error) {
+	mode := redisMode(redisConfig)
+	if mode != RedisModeSingle && mode != RedisModeSentinel && mode != RedisModeCluster {
+		return nil, fmt.Errorf("unknown redis mode %s", mode)
+	}
+	if mode == RedisModeSentinel && redisConfig.MasterName == "" {
+		return nil, fmt.Errorf("master_name is required in sentinel mode")
+	}
+	opts := &redis.UniversalOptions{
+		Addrs:            redisAddrs(redisConfig),
+		DB:               redisConfig.DB,
+		Password:         redisConfig.PassWord,
+		PoolSize:         redisConfig.PoolSize,
+		MasterName:       redisConfig.MasterName,
+		SentinelPassword: redisConfig.SentinelPassword,
+		MaxRetries:       redisConfig.MaxRetries,
+		DialTimeout:      time.Duration(redisConfig.DialTimeout) * time.Millisecond,
+		ReadTimeout:      time.Duration(redisConfig.ReadTimeout) * time.Millisecond,
+		WriteTimeout:     time.Duration(redisConfig.WriteTimeout) * time.Millisecond,
+	}
+	if redisConfig.TLS.Enabled {
+		tlsConfig, err := redisTLSConfig(redisConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return opts, nil
+}
+
+// redisTLSConfig 构造 TLS 配置，可选自定义 CA 证书
+func redisTLSConfig(tlsConf conf.RedisTLSConf) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tlsConf.ServerName,
+		InsecureSkipVerify: tlsConf.InsecureSkipVerify,
+	}
+	if tlsConf.CAFile != "" {
+		pem, err := os.ReadFile(tlsConf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis ca file err:%v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate in %s", tlsConf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if tlsConf.CertFile != "" && tlsConf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConf.CertFile, tlsConf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client cert err:%v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// waitForRedis 启动时按指数退避探测 Redis，重试耗尽后只记录错误，客户端会在后续请求时自动重连
+func waitForRedis(retries int) {
+	if retries <= 0 {
+		retries = defaultRedisStartupRetries
+	}
+	backoff := redisStartupBackoff
+	for attempt := 1; ; attempt++ {
+		err := redisConn.Ping(context.Background()).Err()
+		if err == nil {
+			return
+		}
+		if attempt > retries {
+			log.Errorf("redis still unavailable after %d attempts, continue without it, err:%v", attempt, err)
+			return
+		}
+		log.Warnf("ping redis failed, attempt=%d|retry in %v|err=%v", attempt, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > redisStartupMaxBackoff {
+			backoff = redisStartupMaxBackoff
+		}
 	}
 }
 
 func CloseRedis() {
 	redisConn.Close()
 }
-func GetRedisCli() *redis.Client {
+
+// GetRedisCli 获取 Redis 客户端，根据配置可能是单节点、哨兵或集群客户端
+func GetRedisCli() redis.UniversalClient {
 	// 调用sync.Once初始化Reids连接，保证只初始化一次
 	redisOnce.Do(initRedis)
 	return redisConn
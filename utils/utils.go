@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 )
@@ -34,6 +35,21 @@ func Contains(source []string, tg string) bool {
 //		// 对组合后的字符串进行 MD5 加密
 //		return Md5String(source)
 //	}
-func GenerateSession(userName string) string {
-	return Md5String(fmt.Sprintf("%s:%s", userName, "session"))
+//
+// 会话标识中加入随机数，同一用户每次登录得到不同的会话，各设备的会话有效期互不影响
+func GenerateSession(userName string) (string, error) {
+	token, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return Md5String(fmt.Sprintf("%s:%s:%s", userName, "session", token)), nil
+}
+
+// RandomToken 生成指定字节长度的随机串，以十六进制返回
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -1 +1,131 @@
-let urlPrefix = "http://localhost:8080"
\ No newline at end of file
+let urlPrefix = "http://localhost:8080"
+
+// CSRF 令牌，页面加载后从服务端获取，写请求时放在请求头中
+let csrf = {header: "X-CSRF-Token", token: ""}
+
+window.addEventListener("DOMContentLoaded", function () {
+    if (!window.jQuery) {
+        return;
+    }
+    $.ajaxSetup({
+        beforeSend: function (xhr, settings) {
+            if (settings.type !== "GET" && csrf.token !== "") {
+                xhr.setRequestHeader(csrf.header, csrf.token);
+            }
+        }
+    });
+    $.ajax({
+        type: "GET",
+        dataType: "json",
+        url: urlPrefix + "/user/csrf_token",
+        success: function (result) {
+            if (result.code == 0) {
+                csrf.header = result.data.header_name;
+                csrf.token = result.data.token;
+            }
+        }
+    });
+});
+
+// safeRedirect 登录后跳转的地址只能是本站的路径，避免被用来跳转到其他站点，不合法时返回空串
+function safeRedirect(target) {
+    if (!target || target.charAt(0) !== "/" || target.charAt(1) === "/" || target.charAt(1) === "\\") {
+        return ""
+    }
+    return target
+}
+
+// tenantPrefix 取出跳转地址中的 /t/:tenant 前缀，从租户的授权地址跳转到登录页时，登录等接口也要调用该租户的
+function tenantPrefix(target) {
+    let match = /^\/t\/[^\/?#]+(?=[\/?#]|$)/.exec(target || "")
+    return match ? match[0] : ""
+}
+
+// apiPrefix 调用用户接口的地址前缀，页面按需带上租户前缀
+let apiPrefix = urlPrefix
+
+// 人机校验。页面中放一个 id 为 challenge 的元素，图片验证码展示在其中，工作量证明在提交时自动计算
+let challenge = null
+let challengeScene = ""
+let challengeUser = ""
+
+// loadChallenge 获取挑战，登录场景下只有失败次数过多才会下发，callback(required) 可选
+function loadChallenge(scene, userName, callback) {
+    challengeScene = scene
+    challengeUser = userName || ""
+    $.ajax({
+        type: "GET",
+        dataType: "json",
+        url: apiPrefix + "/user/challenge?scene=" + scene + "&user_name=" + encodeURIComponent(challengeUser),
+        success: function (result) {
+            challenge = (result.code == 0 && result.data.required) ? result.data.challenge : null
+            renderChallenge()
+            if (callback) {
+                callback(challenge !== null)
+            }
+        }
+    });
+}
+
+function renderChallenge() {
+    var box = document.getElementById("challenge")
+    if (!box) {
+        return
+    }
+    if (!challenge || challenge.type !== "captcha") {
+        box.innerHTML = ""
+        box.style.display = "none"
+        return
+    }
+    box.style.display = ""
+    box.innerHTML = '<label for="challenge_answer"><b>验证码</b></label><br>' +
+        '<img id="challenge_image" alt="captcha" title="看不清，换一张" style="cursor:pointer">' +
+        '<input id="challenge_answer" type="text" placeholder="Enter Result" autocomplete="off">'
+    var img = document.getElementById("challenge_image")
+    img.src = challenge.image
+    img.onclick = function () {
+        loadChallenge(challengeScene, challengeUser)
+    }
+}
+
+// solveChallenge 生成提交时需要带上的 challenge_id 和 challenge_answer，没有挑战时为空对象
+function solveChallenge(callback) {
+    if (!challenge) {
+        callback({})
+        return
+    }
+    if (challenge.type === "pow") {
+        solvePow(challenge.nonce, challenge.difficulty).then(function (answer) {
+            callback({"challenge_id": challenge.id, "challenge_answer": answer})
+        })
+        return
+    }
+    var input = document.getElementById("challenge_answer")
+    callback({"challenge_id": challenge.id, "challenge_answer": input ? input.value.trim() : ""})
+}
+
+// solvePow 找到 answer 使 sha256(nonce + answer) 的前 difficulty 个比特为 0
+async function solvePow(nonce, difficulty) {
+    var encoder = new TextEncoder()
+    for (var i = 0; ; i++) {
+        var digest = new Uint8Array(await crypto.subtle.digest("SHA-256", encoder.encode(nonce + i)))
+        if (leadingZeroBits(digest) >= difficulty) {
+            return String(i)
+        }
+    }
+}
+
+function leadingZeroBits(bytes) {
+    var n = 0
+    for (var i = 0; i < bytes.length; i++) {
+        if (bytes[i] === 0) {
+            n += 8
+            continue
+        }
+        for (var mask = 0x80; mask && (bytes[i] & mask) === 0; mask >>= 1) {
+            n++
+        }
+        break
+    }
+    return n
+}